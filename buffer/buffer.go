@@ -3,23 +3,43 @@ package buffer
 import (
 	"gorel/file"
 	"gorel/log"
+	"sync"
 )
 
+// formattingTransactionNumber marks a buffer as modified by a page formatter, outside any transaction.
+const formattingTransactionNumber = 0
+
+// NoLogSequenceNumber marks a modification which is not logged. The log sequence numbers start at 1.
+const NoLogSequenceNumber uint = 0
+
+// PageFormatter initializes the page of a newly appended block.
+type PageFormatter func(page *Page)
+
 type Buffer struct {
 	fileManager       *file.BlockFileManager
 	logManager        *log.BlockLogManager
+	overflowStore     *overflowStore
 	page              *Page
 	blockId           file.BlockId
 	pins              int
 	transactionNumber int
 	logSequenceNumber uint
+	// recoveryLogSequenceNumber is the log sequence number of the earliest modification which has not been written.
+	recoveryLogSequenceNumber uint
+	latch                     sync.RWMutex
 }
 
 func NewBuffer(fileManager *file.BlockFileManager, logManager *log.BlockLogManager) *Buffer {
+	return newBuffer(fileManager, logManager, overflowStoreOf(fileManager))
+}
+
+// newBuffer creates a buffer which shares the overflowStore of the file manager with the other buffers.
+func newBuffer(fileManager *file.BlockFileManager, logManager *log.BlockLogManager, overflowStore *overflowStore) *Buffer {
 	return &Buffer{
 		fileManager:       fileManager,
 		logManager:        logManager,
-		page:              NewPage(fileManager.BlockSize()),
+		overflowStore:     overflowStore,
+		page:              newPooledPage(fileManager.PageBuffers()),
 		blockId:           file.MissingBlockId,
 		pins:              0,
 		transactionNumber: -1,
@@ -30,9 +50,17 @@ func (buffer *Buffer) Page() *Page {
 	return buffer.page
 }
 
+// SetModified marks the buffer as modified by the transaction, and stamps the log sequence number of the log record
+// of the modification on the page. NoLogSequenceNumber keeps the log sequence number of the buffer and of the page.
 func (buffer *Buffer) SetModified(transactionNumber int, logSequenceNumber uint) {
 	buffer.transactionNumber = transactionNumber
-	buffer.logSequenceNumber = logSequenceNumber
+	if logSequenceNumber != NoLogSequenceNumber {
+		buffer.logSequenceNumber = logSequenceNumber
+		buffer.page.SetLogSequenceNumber(logSequenceNumber)
+		if buffer.recoveryLogSequenceNumber == NoLogSequenceNumber {
+			buffer.recoveryLogSequenceNumber = logSequenceNumber
+		}
+	}
 }
 
 func (buffer *Buffer) AssignToBlock(blockId file.BlockId) error {
@@ -44,9 +72,74 @@ func (buffer *Buffer) AssignToBlock(blockId file.BlockId) error {
 	}
 	buffer.blockId = blockId
 	buffer.pins = 0
+	buffer.bindOverflowFile()
+	return nil
+}
+
+func (buffer *Buffer) AssignToNewBlock(fileName string, formatter PageFormatter) error {
+	if err := buffer.flush(); err != nil {
+		return err
+	}
+	blockId, err := buffer.fileManager.AppendEmptyBlock(fileName)
+	if err != nil {
+		return err
+	}
+	buffer.page.reset()
+	buffer.blockId = blockId
+	buffer.pins = 0
+	buffer.bindOverflowFile()
+	if formatter != nil {
+		formatter(buffer.page)
+	}
+	buffer.transactionNumber = formattingTransactionNumber
+	return nil
+}
+
+func (buffer *Buffer) assignToPrefetchedBlock(blockId file.BlockId, page *Page) {
+	buffer.page.releaseBuffer()
+	buffer.page = page
+	buffer.blockId = blockId
+	buffer.pins = 0
+	buffer.bindOverflowFile()
+}
+
+// unassign writes the page if it is modified, and detaches the buffer from its block.
+func (buffer *Buffer) unassign() error {
+	if err := buffer.flush(); err != nil {
+		return err
+	}
+	buffer.blockId = file.MissingBlockId
 	return nil
 }
 
+// bindOverflowFile lets the page store its large values in the overflow file of the file of its block.
+func (buffer *Buffer) bindOverflowFile() {
+	buffer.page.overflow = &overflowFile{store: buffer.overflowStore, fileName: buffer.blockId.FileName()}
+}
+
+// Latch acquires the latch of the buffer exclusively. The latch guards the page for the duration of a short read or
+// modification against the goroutines which access the page without holding a lock on its block.
+func (buffer *Buffer) Latch() {
+	buffer.latch.Lock()
+}
+
+func (buffer *Buffer) Unlatch() {
+	buffer.latch.Unlock()
+}
+
+// LatchShared acquires the latch of the buffer along with the other readers of the page.
+func (buffer *Buffer) LatchShared() {
+	buffer.latch.RLock()
+}
+
+func (buffer *Buffer) UnlatchShared() {
+	buffer.latch.RUnlock()
+}
+
+func (buffer *Buffer) BlockId() file.BlockId {
+	return buffer.blockId
+}
+
 func (buffer *Buffer) pin() {
 	buffer.pins += 1
 }
@@ -59,8 +152,12 @@ func (buffer *Buffer) isPinned() bool {
 	return buffer.pins > 0
 }
 
+func (buffer *Buffer) isModified() bool {
+	return buffer.transactionNumber >= 0
+}
+
 func (buffer *Buffer) flush() error {
-	if buffer.transactionNumber >= 0 {
+	if buffer.isModified() {
 		if err := buffer.logManager.Flush(buffer.logSequenceNumber); err != nil {
 			return err
 		}
@@ -69,6 +166,7 @@ func (buffer *Buffer) flush() error {
 			return err
 		}
 		buffer.transactionNumber = -1
+		buffer.recoveryLogSequenceNumber = NoLogSequenceNumber
 	}
 	return nil
 }
@@ -2,55 +2,392 @@ package buffer
 
 import (
 	"errors"
+	"expvar"
+	"fmt"
 	"gorel/file"
 	"gorel/log"
+	"strings"
+	"sync"
+	"time"
 )
 
 var NoBufferAvailableForPinningError = errors.New("no buffer available for pinning")
 
-// BufferManager TODO: concurrency + notify other goroutines on unpin
 type BufferManager struct {
-	bufferPool []*Buffer
-	available  uint
+	bufferPool  []*Buffer
+	available   uint
+	maxWaitTime time.Duration
+	fileManager *file.BlockFileManager
+	replacer    replacer
+	prefetcher  *prefetcher
+	pinTracker  *pinTracker
+	// overflowStore holds the overflow chains of the values of the pages in the buffer pool.
+	overflowStore *overflowStore
+	lock          sync.Mutex
+	unpinned      chan struct{}
+	statistics    statistics
 }
 
 func NewBufferManager(
 	capacity uint,
 	fileManager *file.BlockFileManager,
 	logManager *log.BlockLogManager,
+) *BufferManager {
+	return NewBufferManagerWithMaxWaitTime(capacity, 0, fileManager, logManager)
+}
+
+// NewBufferManagerWithMaxWaitTime creates a BufferManager where Pin and PinNew wait up to maxWaitTime
+// for a buffer to get unpinned, before returning NoBufferAvailableForPinningError.
+func NewBufferManagerWithMaxWaitTime(
+	capacity uint,
+	maxWaitTime time.Duration,
+	fileManager *file.BlockFileManager,
+	logManager *log.BlockLogManager,
+) *BufferManager {
+	return NewBufferManagerWithPolicy(capacity, maxWaitTime, ReplacementNaive, fileManager, logManager)
+}
+
+// NewBufferManagerWithPolicy creates a BufferManager which uses the replacement policy to choose the buffer
+// for a block that is not present in the buffer pool.
+func NewBufferManagerWithPolicy(
+	capacity uint,
+	maxWaitTime time.Duration,
+	policy ReplacementPolicy,
+	fileManager *file.BlockFileManager,
+	logManager *log.BlockLogManager,
 ) *BufferManager {
 	bufferPool := make([]*Buffer, capacity)
+	overflowStore := overflowStoreOf(fileManager)
 	for index := uint(0); index < capacity; index++ {
-		bufferPool[index] = NewBuffer(fileManager, logManager)
+		bufferPool[index] = newBuffer(fileManager, logManager, overflowStore)
 	}
 	return &BufferManager{
-		bufferPool: bufferPool,
-		available:  capacity,
+		bufferPool:    bufferPool,
+		available:     capacity,
+		maxWaitTime:   maxWaitTime,
+		fileManager:   fileManager,
+		replacer:      newReplacer(policy, bufferPool),
+		overflowStore: overflowStore,
+		unpinned:      make(chan struct{}),
 	}
 }
 
-func (bufferManager *BufferManager) Pin(blockId file.BlockId) (*Buffer, error) {
-	buffer, err := bufferManager.tryPin(blockId)
+// FreeOverflowChain frees the overflow chain of an image of a field of a page of the file, as returned by
+// Page.TryGetStored or Page.TryStore, if the image is an encoded pointer to a chain.
+func (bufferManager *BufferManager) FreeOverflowChain(fileName string, typeDescription TypeDescription, stored []byte) error {
+	if !typeDescription.IsOverflow() {
+		return nil
+	}
+	return bufferManager.overflowStore.free(fileName, decodeOverflowPointer(stored))
+}
+
+// EnablePrefetching starts the workers which read up to prefetchDepth blocks following a pinned block into
+// unpinned buffers. Close stops the workers.
+func (bufferManager *BufferManager) EnablePrefetching(prefetchDepth uint, workers uint) {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	if bufferManager.prefetcher != nil || prefetchDepth == 0 || workers == 0 {
+		return
+	}
+	bufferManager.prefetcher = newPrefetcher(prefetchDepth, workers)
+	bufferManager.prefetcher.start(workers, bufferManager.prefetch)
+}
+
+// EnablePinTracking records the caller stack of each subsequent pin, which is used for reporting the pins held for
+// too long. onLeak, if not nil, is invoked for each BufferHandle that is garbage collected without being released.
+func (bufferManager *BufferManager) EnablePinTracking(onLeak func(PinLeak)) {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	if bufferManager.pinTracker == nil {
+		bufferManager.pinTracker = newPinTracker(onLeak)
+	}
+}
+
+// PinsHeldLongerThan returns the tracked pins which are held for longer than the threshold.
+func (bufferManager *BufferManager) PinsHeldLongerThan(threshold time.Duration) []PinLeak {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	if bufferManager.pinTracker == nil {
+		return nil
+	}
+	return bufferManager.pinTracker.pinsHeldLongerThan(threshold)
+}
+
+// AssertNoPins returns an error describing every pinned buffer, along with the caller stacks of the pins if pin
+// tracking is enabled.
+func (bufferManager *BufferManager) AssertNoPins() error {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	var description strings.Builder
+	for _, buffer := range bufferManager.bufferPool {
+		if !buffer.isPinned() {
+			continue
+		}
+		description.WriteString(fmt.Sprintf(
+			"block %v:%v has %v pin(s)\n", buffer.blockId.FileName(), buffer.blockId.BlockNumber(), buffer.pins,
+		))
+		if bufferManager.pinTracker != nil {
+			for _, record := range bufferManager.pinTracker.pins[buffer] {
+				description.WriteString(fmt.Sprintf("pinned at %v by\n%v", record.pinnedAt, record.stack))
+			}
+		}
+	}
+	if description.Len() > 0 {
+		return fmt.Errorf("buffers are still pinned:\n%v", description.String())
+	}
+	return nil
+}
+
+// PinHandle pins the block and returns a BufferHandle which unpins the buffer on Release. The handle releases its
+// own pin, so the pin tracking reports the remaining pins of the buffer with their own caller stacks.
+func (bufferManager *BufferManager) PinHandle(blockId file.BlockId) (*BufferHandle, error) {
+	var record *pinRecord
+	buffer, err := bufferManager.pinWaitingForAvailability(func() (*Buffer, error) {
+		buffer, err := bufferManager.tryPinWithHint(blockId, PinDefault)
+		if buffer != nil && bufferManager.pinTracker != nil {
+			record = bufferManager.pinTracker.own(buffer)
+		}
+		return buffer, err
+	})
 	if err != nil {
 		return nil, err
 	}
-	if buffer == nil {
-		return nil, NoBufferAvailableForPinningError
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	var onLeak func(PinLeak)
+	leak := PinLeak{BlockId: blockId, PinnedAt: time.Now()}
+	if record != nil {
+		onLeak = bufferManager.pinTracker.onLeak
+		leak.PinnedAt, leak.Stack = record.pinnedAt, record.stack
 	}
-	return buffer, nil
+	return newBufferHandle(buffer, bufferManager, record, leak, onLeak), nil
+}
+
+func (bufferManager *BufferManager) Pin(blockId file.BlockId) (*Buffer, error) {
+	return bufferManager.PinWithHint(blockId, PinDefault)
+}
+
+// PinWithHint pins the block and, if prefetching is enabled, uses the hint to decide whether the blocks following
+// it should be prefetched.
+func (bufferManager *BufferManager) PinWithHint(blockId file.BlockId, hint PinHint) (*Buffer, error) {
+	return bufferManager.pinWaitingForAvailability(func() (*Buffer, error) {
+		return bufferManager.tryPinWithHint(blockId, hint)
+	})
+}
+
+// PinNew appends a new block to the file, assigns it to an unpinned buffer without reading it from disk,
+// formats its page using the formatter and pins the buffer.
+func (bufferManager *BufferManager) PinNew(fileName string, formatter PageFormatter) (*Buffer, error) {
+	return bufferManager.pinWaitingForAvailability(func() (*Buffer, error) {
+		return bufferManager.tryPinNew(fileName, formatter)
+	})
 }
 
 func (bufferManager *BufferManager) Unpin(buffer *Buffer) {
+	bufferManager.unpin(buffer, nil)
+}
+
+// unpin releases a pin of the buffer, and the pin record of a BufferHandle if it is not nil.
+func (bufferManager *BufferManager) unpin(buffer *Buffer, record *pinRecord) {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
 	buffer.unpin()
+	if bufferManager.pinTracker != nil {
+		bufferManager.pinTracker.unpinned(buffer, record)
+	}
 	if !buffer.isPinned() {
+		bufferManager.replacer.unpinned(buffer)
 		bufferManager.available += 1
+		close(bufferManager.unpinned)
+		bufferManager.unpinned = make(chan struct{})
+	}
+}
+
+// FlushAll writes the pages of the buffers modified by the transaction, after flushing the log up to the log records
+// of their modifications.
+func (bufferManager *BufferManager) FlushAll(transactionNumber int) error {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	for _, buffer := range bufferManager.bufferPool {
+		if buffer.transactionNumber == transactionNumber {
+			if err := buffer.flush(); err != nil {
+				return err
+			}
+		}
 	}
+	return nil
+}
+
+// FlushAllModified writes the pages of all the modified buffers, after flushing the log up to the log records of
+// their modifications.
+func (bufferManager *BufferManager) FlushAllModified() error {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	for _, buffer := range bufferManager.bufferPool {
+		if err := buffer.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DirtyPageTable returns the recovery log sequence number of each block whose buffer has logged modifications which
+// have not been written: the log sequence number of the earliest of those modifications.
+func (bufferManager *BufferManager) DirtyPageTable() map[file.BlockId]uint {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	dirtyPages := make(map[file.BlockId]uint)
+	for _, buffer := range bufferManager.bufferPool {
+		if buffer.isModified() && buffer.recoveryLogSequenceNumber != NoLogSequenceNumber {
+			dirtyPages[buffer.blockId] = buffer.recoveryLogSequenceNumber
+		}
+	}
+	return dirtyPages
 }
 
 func (bufferManager *BufferManager) Available() int {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
 	return int(bufferManager.available)
 }
 
+// Stats returns the counters of the BufferManager, without acquiring its lock.
+func (bufferManager *BufferManager) Stats() Statistics {
+	return bufferManager.statistics.snapshot()
+}
+
+// Snapshot returns the state of each buffer in the buffer pool.
+func (bufferManager *BufferManager) Snapshot() []FrameSnapshot {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	frames := make([]FrameSnapshot, 0, len(bufferManager.bufferPool))
+	for _, buffer := range bufferManager.bufferPool {
+		frames = append(frames, FrameSnapshot{
+			BlockId:           buffer.blockId,
+			Pins:              buffer.pins,
+			Modified:          buffer.isModified(),
+			TransactionNumber: buffer.transactionNumber,
+			LogSequenceNumber: buffer.logSequenceNumber,
+		})
+	}
+	return frames
+}
+
+// PublishStats exposes the statistics and the snapshot of the BufferManager through expvar under the given name.
+func (bufferManager *BufferManager) PublishStats(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %v is already published", name)
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return map[string]any{
+			"stats":  bufferManager.Stats(),
+			"frames": bufferManager.Snapshot(),
+		}
+	}))
+	return nil
+}
+
+// Close stops the prefetching workers, if prefetching is enabled.
+func (bufferManager *BufferManager) Close() {
+	bufferManager.lock.Lock()
+	prefetcher := bufferManager.prefetcher
+	bufferManager.prefetcher = nil
+	bufferManager.lock.Unlock()
+
+	if prefetcher != nil {
+		prefetcher.stop()
+	}
+}
+
+// evictFiles writes the buffers of the blocks of the matching files, and unassigns them so that the blocks are read
+// again when they are pinned. It returns ReroutingPinnedFileError, and evicts no block, if one of them is pinned.
+func (bufferManager *BufferManager) evictFiles(matches func(fileName string) bool) error {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	var evicted []*Buffer
+	for _, buffer := range bufferManager.bufferPool {
+		if buffer.blockId.IsMissing() || !matches(buffer.blockId.FileName()) {
+			continue
+		}
+		if buffer.isPinned() {
+			return fmt.Errorf("%w: %v:%v", ReroutingPinnedFileError, buffer.blockId.FileName(), buffer.blockId.BlockNumber())
+		}
+		evicted = append(evicted, buffer)
+	}
+	if bufferManager.prefetcher != nil {
+		for blockId := range bufferManager.prefetcher.inFlight {
+			if matches(blockId.FileName()) {
+				bufferManager.prefetcher.invalidate(blockId)
+			}
+		}
+	}
+	for _, buffer := range evicted {
+		if err := buffer.unassign(); err != nil {
+			return err
+		}
+		bufferManager.statistics.recordEviction()
+	}
+	return nil
+}
+
+func (bufferManager *BufferManager) pinWaitingForAvailability(tryPin func() (*Buffer, error)) (*Buffer, error) {
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	buffer, err := tryPin()
+	if err != nil {
+		return nil, err
+	}
+	if buffer == nil && bufferManager.maxWaitTime > 0 {
+		startTime := time.Now()
+		deadline := startTime.Add(bufferManager.maxWaitTime)
+		for buffer == nil && bufferManager.waitForUnpin(deadline) {
+			if buffer, err = tryPin(); err != nil {
+				return nil, err
+			}
+		}
+		bufferManager.statistics.recordPinWait(time.Since(startTime))
+	}
+	if buffer == nil {
+		return nil, NoBufferAvailableForPinningError
+	}
+	return buffer, nil
+}
+
+// waitForUnpin releases the lock until a buffer is unpinned or the deadline passes, and returns false if the
+// deadline has passed.
+func (bufferManager *BufferManager) waitForUnpin(deadline time.Time) bool {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return false
+	}
+	unpinned := bufferManager.unpinned
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+
+	bufferManager.lock.Unlock()
+	defer bufferManager.lock.Lock()
+
+	select {
+	case <-unpinned:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
 func (bufferManager *BufferManager) tryPin(blockId file.BlockId) (*Buffer, error) {
 	buffer := bufferManager.findAnExistingBuffer(blockId)
 	if buffer == nil {
@@ -58,17 +395,104 @@ func (bufferManager *BufferManager) tryPin(blockId file.BlockId) (*Buffer, error
 		if buffer == nil {
 			return nil, nil
 		}
-		if err := buffer.AssignToBlock(blockId); err != nil {
+		if err := bufferManager.assign(buffer, func() error {
+			return buffer.AssignToBlock(blockId)
+		}); err != nil {
 			return nil, err
 		}
+		bufferManager.statistics.recordMiss()
+	} else {
+		bufferManager.statistics.recordHit()
 	}
 	if !buffer.isPinned() {
 		bufferManager.available -= 1
 	}
-	buffer.pin()
+	bufferManager.pinBuffer(buffer)
 	return buffer, nil
 }
 
+// tryPinWithHint pins the block like tryPin and, if prefetching is enabled, schedules the blocks to prefetch.
+func (bufferManager *BufferManager) tryPinWithHint(blockId file.BlockId, hint PinHint) (*Buffer, error) {
+	buffer, err := bufferManager.tryPin(blockId)
+	if buffer != nil && bufferManager.prefetcher != nil {
+		for _, nextBlockId := range bufferManager.prefetcher.blocksToPrefetch(blockId, hint) {
+			if bufferManager.findAnExistingBuffer(nextBlockId) == nil {
+				bufferManager.prefetcher.schedule(nextBlockId)
+			}
+		}
+	}
+	return buffer, err
+}
+
+func (bufferManager *BufferManager) tryPinNew(fileName string, formatter PageFormatter) (*Buffer, error) {
+	buffer := bufferManager.chooseUnpinnedBuffer()
+	if buffer == nil {
+		return nil, nil
+	}
+	if err := bufferManager.assign(buffer, func() error {
+		return buffer.AssignToNewBlock(fileName, formatter)
+	}); err != nil {
+		return nil, err
+	}
+	bufferManager.available -= 1
+	bufferManager.pinBuffer(buffer)
+	return buffer, nil
+}
+
+// prefetch reads the block without holding the lock and assigns it to an unpinned and unmodified buffer, if the
+// block is not already present in the buffer pool.
+func (bufferManager *BufferManager) prefetch(blockId file.BlockId) {
+	page := newUnbufferedPooledPage(bufferManager.fileManager.PageBuffers())
+	err := bufferManager.fileManager.ReadInto(blockId, page)
+
+	bufferManager.lock.Lock()
+	defer bufferManager.lock.Unlock()
+
+	if bufferManager.prefetcher == nil || !bufferManager.prefetcher.done(blockId) {
+		page.releaseBuffer()
+		return
+	}
+	if err != nil || bufferManager.findAnExistingBuffer(blockId) != nil {
+		page.releaseBuffer()
+		return
+	}
+	buffer := bufferManager.chooseBufferForPrefetching()
+	if buffer == nil {
+		page.releaseBuffer()
+		return
+	}
+	if !buffer.blockId.IsMissing() {
+		bufferManager.statistics.recordEviction()
+	}
+	buffer.assignToPrefetchedBlock(blockId, page)
+	bufferManager.statistics.recordPrefetch()
+}
+
+func (bufferManager *BufferManager) pinBuffer(buffer *Buffer) {
+	buffer.pin()
+	bufferManager.replacer.pinned(buffer)
+	if bufferManager.pinTracker != nil {
+		bufferManager.pinTracker.pinned(buffer)
+	}
+}
+
+func (bufferManager *BufferManager) assign(buffer *Buffer, assignFn func() error) error {
+	evicting, flushing := !buffer.blockId.IsMissing(), buffer.isModified()
+	if flushing && bufferManager.prefetcher != nil {
+		bufferManager.prefetcher.invalidate(buffer.blockId)
+	}
+	if err := assignFn(); err != nil {
+		return err
+	}
+	if evicting {
+		bufferManager.statistics.recordEviction()
+	}
+	if flushing {
+		bufferManager.statistics.recordDirtyWrite()
+	}
+	return nil
+}
+
 func (bufferManager *BufferManager) findAnExistingBuffer(blockId file.BlockId) *Buffer {
 	for _, buffer := range bufferManager.bufferPool {
 		if buffer.blockId == blockId {
@@ -78,11 +502,22 @@ func (bufferManager *BufferManager) findAnExistingBuffer(blockId file.BlockId) *
 	return nil
 }
 
-func (bufferManager *BufferManager) chooseUnpinnedBuffer() *Buffer {
+func (bufferManager *BufferManager) chooseBufferForPrefetching() *Buffer {
+	var candidate *Buffer
 	for _, buffer := range bufferManager.bufferPool {
-		if !buffer.isPinned() {
+		if buffer.isPinned() || buffer.isModified() {
+			continue
+		}
+		if buffer.blockId.IsMissing() {
 			return buffer
 		}
+		if candidate == nil {
+			candidate = buffer
+		}
 	}
-	return nil
+	return candidate
+}
+
+func (bufferManager *BufferManager) chooseUnpinnedBuffer() *Buffer {
+	return bufferManager.replacer.chooseUnpinnedBuffer()
 }
@@ -91,3 +91,244 @@ func TestPinABuffer(t *testing.T) {
 	assert.Equal(t, "RocksDB is an LSM based storage engine", buffer.page.GetString(0))
 	assert.Equal(t, uint32(32), buffer.page.GetUint32(1))
 }
+
+func TestPinANewBuffer(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+
+	buffer, err := bufferManager.PinNew(fileName, func(page *Page) {
+		page.AddUint32(0)
+		page.AddString("heap")
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, file.NewBlockId(fileName, 0), buffer.BlockId())
+	assert.True(t, buffer.isModified())
+	assert.Equal(t, 0, bufferManager.Available())
+	assert.Equal(t, uint32(0), buffer.Page().GetUint32(0))
+	assert.Equal(t, "heap", buffer.Page().GetString(1))
+}
+
+func TestPinANewBufferAfterAnExistingBlock(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+
+	buffer, err := bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	buffer.Page().AddString("RocksDB is an LSM based storage engine")
+
+	newBuffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, file.NewBlockId(fileName, 1), newBuffer.BlockId())
+	assert.Equal(t, 0, bufferManager.Available())
+}
+
+func TestPinANewBufferAndFlushIt(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+
+	buffer, err := bufferManager.PinNew(fileName, func(page *Page) {
+		page.AddUint64(64)
+	})
+	assert.Nil(t, err)
+	bufferManager.Unpin(buffer)
+
+	anotherBlockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	buffer, err = bufferManager.Pin(anotherBlockId)
+	assert.Nil(t, err)
+	bufferManager.Unpin(buffer)
+
+	buffer, err = bufferManager.Pin(file.NewBlockId(fileName, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(64), buffer.Page().GetUint64(0))
+}
+
+func TestFailsToPinANewBuffer(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	bufferManager.bufferPool[0].pin()
+
+	_, err = bufferManager.PinNew(fileName, nil)
+	assert.EqualError(t, err, NoBufferAvailableForPinningError.Error())
+}
+
+func TestFlushAllTheBuffersModifiedByATransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+
+	buffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	buffer.Page().AddUint32(32)
+	buffer.SetModified(5, 1)
+
+	otherBuffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	otherBuffer.Page().AddUint32(64)
+	otherBuffer.SetModified(6, 2)
+
+	assert.Nil(t, bufferManager.FlushAll(5))
+	assert.False(t, buffer.isModified())
+	assert.True(t, otherBuffer.isModified())
+
+	page := NewPage(blockSize)
+	assert.Nil(t, fileManager.ReadInto(buffer.BlockId(), page))
+	assert.Equal(t, uint32(32), page.GetUint32(0))
+}
+
+func TestFlushAllTheModifiedBuffers(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+
+	buffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	buffer.Page().AddUint32(32)
+	buffer.SetModified(5, 1)
+
+	otherBuffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	otherBuffer.Page().AddUint32(64)
+	otherBuffer.SetModified(6, 2)
+
+	assert.Nil(t, bufferManager.FlushAllModified())
+	assert.False(t, buffer.isModified())
+	assert.False(t, otherBuffer.isModified())
+
+	page := NewPage(blockSize)
+	assert.Nil(t, fileManager.ReadInto(otherBuffer.BlockId(), page))
+	assert.Equal(t, uint32(64), page.GetUint32(0))
+}
+
+func TestDirtyPageTableOfTheBufferManager(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(3, fileManager, logManager)
+
+	buffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	buffer.Page().AddUint32(32)
+	buffer.SetModified(5, 1)
+	buffer.Page().AddUint32(64)
+	buffer.SetModified(5, 3)
+
+	otherBuffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	otherBuffer.Page().AddUint32(64)
+	otherBuffer.SetModified(6, 2)
+
+	unloggedBuffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	unloggedBuffer.Page().AddUint32(128)
+	unloggedBuffer.SetModified(6, NoLogSequenceNumber)
+
+	assert.Equal(t, map[file.BlockId]uint{buffer.BlockId(): 1, otherBuffer.BlockId(): 2}, bufferManager.DirtyPageTable())
+
+	assert.Nil(t, bufferManager.FlushAll(5))
+	assert.Equal(t, map[file.BlockId]uint{otherBuffer.BlockId(): 2}, bufferManager.DirtyPageTable())
+
+	buffer.Page().AddUint32(256)
+	buffer.SetModified(7, 4)
+	assert.Equal(t, map[file.BlockId]uint{buffer.BlockId(): 4, otherBuffer.BlockId(): 2}, bufferManager.DirtyPageTable())
+}
@@ -0,0 +1,204 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"gorel/file"
+	"gorel/log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	UnknownBufferPoolError   = errors.New("unknown buffer pool")
+	ReroutingPinnedFileError = errors.New("cannot route a file whose blocks are pinned in another buffer pool")
+)
+
+// BufferPinner is implemented by BufferManager and by BufferPools.
+type BufferPinner interface {
+	Pin(blockId file.BlockId) (*Buffer, error)
+	PinWithHint(blockId file.BlockId, hint PinHint) (*Buffer, error)
+	PinNew(fileName string, formatter PageFormatter) (*Buffer, error)
+	Unpin(buffer *Buffer)
+	Available() int
+}
+
+var (
+	_ BufferPinner = (*BufferManager)(nil)
+	_ BufferPinner = (*BufferPools)(nil)
+)
+
+type PoolConfiguration struct {
+	Name        string
+	Capacity    uint
+	MaxWaitTime time.Duration
+	Policy      ReplacementPolicy
+}
+
+type poolRoute struct {
+	fileNamePrefix string
+	pool           *BufferManager
+}
+
+// BufferPools is a set of named buffer pools, each with its own BufferManager. Files are routed to the pool
+// with the longest matching file name prefix, and to the default pool if no prefix matches. The routes are read
+// locked for the duration of a pin, so that Route does not move a file while one of its blocks is being pinned in
+// its previous pool. The owners have their own lock, so that Unpin does not wait for a pending Route.
+type BufferPools struct {
+	fileManager *file.BlockFileManager
+	logManager  *log.BlockLogManager
+	defaultPool *BufferManager
+	pools       map[string]*BufferManager
+	owners      map[*Buffer]*BufferManager
+	routes      []poolRoute
+	lock        sync.RWMutex
+	ownersLock  sync.RWMutex
+}
+
+func NewBufferPools(
+	defaultPool PoolConfiguration,
+	fileManager *file.BlockFileManager,
+	logManager *log.BlockLogManager,
+) *BufferPools {
+	pools := &BufferPools{
+		fileManager: fileManager,
+		logManager:  logManager,
+		pools:       make(map[string]*BufferManager),
+		owners:      make(map[*Buffer]*BufferManager),
+	}
+	pools.defaultPool = pools.addPool(defaultPool)
+	return pools
+}
+
+func (pools *BufferPools) AddPool(configuration PoolConfiguration) error {
+	pools.lock.Lock()
+	defer pools.lock.Unlock()
+
+	if _, ok := pools.pools[configuration.Name]; ok {
+		return fmt.Errorf("buffer pool %v already exists", configuration.Name)
+	}
+	pools.addPool(configuration)
+	return nil
+}
+
+// Route maps the files whose names start with the fileNamePrefix to the named pool. The blocks of the files which
+// move to another pool are written and evicted from their previous pool, so that a block is never cached in two
+// pools. It returns ReroutingPinnedFileError, and keeps the routes, if one of those blocks is pinned.
+func (pools *BufferPools) Route(fileNamePrefix string, poolName string) error {
+	pools.lock.Lock()
+	defer pools.lock.Unlock()
+
+	pool, ok := pools.pools[poolName]
+	if !ok {
+		return UnknownBufferPoolError
+	}
+	routes := append(slices.Clone(pools.routes), poolRoute{fileNamePrefix: fileNamePrefix, pool: pool})
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].fileNamePrefix) > len(routes[j].fileNamePrefix)
+	})
+	for _, cachingPool := range pools.pools {
+		err := cachingPool.evictFiles(func(fileName string) bool {
+			return pools.poolFor(fileName, routes) != cachingPool
+		})
+		if err != nil {
+			return err
+		}
+	}
+	pools.routes = routes
+	return nil
+}
+
+func (pools *BufferPools) Pool(name string) (*BufferManager, bool) {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	pool, ok := pools.pools[name]
+	return pool, ok
+}
+
+func (pools *BufferPools) PoolFor(fileName string) *BufferManager {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	return pools.poolFor(fileName, pools.routes)
+}
+
+func (pools *BufferPools) Pin(blockId file.BlockId) (*Buffer, error) {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	return pools.poolFor(blockId.FileName(), pools.routes).Pin(blockId)
+}
+
+func (pools *BufferPools) PinWithHint(blockId file.BlockId, hint PinHint) (*Buffer, error) {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	return pools.poolFor(blockId.FileName(), pools.routes).PinWithHint(blockId, hint)
+}
+
+func (pools *BufferPools) PinNew(fileName string, formatter PageFormatter) (*Buffer, error) {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	return pools.poolFor(fileName, pools.routes).PinNew(fileName, formatter)
+}
+
+// Unpin unpins the buffer in the pool which owns it, even if the routes have changed after the buffer was pinned.
+func (pools *BufferPools) Unpin(buffer *Buffer) {
+	pools.ownersLock.RLock()
+	pool := pools.owners[buffer]
+	pools.ownersLock.RUnlock()
+
+	pool.Unpin(buffer)
+}
+
+func (pools *BufferPools) Available() int {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	available := 0
+	for _, pool := range pools.pools {
+		available += pool.Available()
+	}
+	return available
+}
+
+func (pools *BufferPools) Close() {
+	pools.lock.RLock()
+	defer pools.lock.RUnlock()
+
+	for _, pool := range pools.pools {
+		pool.Close()
+	}
+}
+
+// poolFor returns the pool of the longest file name prefix in the routes which matches the file name.
+func (pools *BufferPools) poolFor(fileName string, routes []poolRoute) *BufferManager {
+	for _, route := range routes {
+		if strings.HasPrefix(fileName, route.fileNamePrefix) {
+			return route.pool
+		}
+	}
+	return pools.defaultPool
+}
+
+func (pools *BufferPools) addPool(configuration PoolConfiguration) *BufferManager {
+	pool := NewBufferManagerWithPolicy(
+		configuration.Capacity,
+		configuration.MaxWaitTime,
+		configuration.Policy,
+		pools.fileManager,
+		pools.logManager,
+	)
+	pools.ownersLock.Lock()
+	for _, buffer := range pool.bufferPool {
+		pools.owners[buffer] = pool
+	}
+	pools.ownersLock.Unlock()
+	pools.pools[configuration.Name] = pool
+	return pool
+}
@@ -0,0 +1,193 @@
+package buffer
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRouteFilesToBufferPools(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	pools := NewBufferPools(PoolConfiguration{Name: "default", Capacity: 2}, fileManager, logManager)
+	assert.Nil(t, pools.AddPool(PoolConfiguration{Name: "index", Capacity: 1, Policy: ReplacementLRU}))
+	assert.Nil(t, pools.AddPool(PoolConfiguration{Name: "primary", Capacity: 1, Policy: ReplacementClock}))
+	assert.Nil(t, pools.Route("idx_", "index"))
+	assert.Nil(t, pools.Route("idx_primary_", "primary"))
+
+	index, _ := pools.Pool("index")
+	primary, _ := pools.Pool("primary")
+	defaultPool, _ := pools.Pool("default")
+
+	assert.Same(t, index, pools.PoolFor("idx_students"))
+	assert.Same(t, primary, pools.PoolFor("idx_primary_students"))
+	assert.Same(t, defaultPool, pools.PoolFor("students"))
+}
+
+func TestRouteToAnUnknownBufferPool(t *testing.T) {
+	pools := NewBufferPools(PoolConfiguration{Name: "default"}, nil, nil)
+	assert.Equal(t, UnknownBufferPoolError, pools.Route("idx_", "index"))
+}
+
+func TestAddADuplicateBufferPool(t *testing.T) {
+	pools := NewBufferPools(PoolConfiguration{Name: "default"}, nil, nil)
+	assert.NotNil(t, pools.AddPool(PoolConfiguration{Name: "default"}))
+}
+
+func TestPinAndUnpinThroughBufferPools(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	tableFileName := t.Name()
+	indexFileName := fmt.Sprintf("idx_%v", t.Name())
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(tableFileName)
+		_ = os.Remove(indexFileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	tableBlockId, err := fileManager.AppendEmptyBlock(tableFileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	pools := NewBufferPools(PoolConfiguration{Name: "default", Capacity: 1}, fileManager, logManager)
+	assert.Nil(t, pools.AddPool(PoolConfiguration{Name: "index", Capacity: 1}))
+	assert.Nil(t, pools.Route("idx_", "index"))
+	defer pools.Close()
+
+	tableBuffer, err := pools.Pin(tableBlockId)
+	assert.Nil(t, err)
+
+	indexBuffer, err := pools.PinNew(indexFileName, func(page *Page) {
+		page.AddString("index")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, pools.Available())
+
+	_, err = pools.PinNew(tableFileName, nil)
+	assert.EqualError(t, err, NoBufferAvailableForPinningError.Error())
+
+	pools.Unpin(indexBuffer)
+	index, _ := pools.Pool("index")
+	assert.Equal(t, 1, index.Available())
+
+	pools.Unpin(tableBuffer)
+	assert.Equal(t, 2, pools.Available())
+}
+
+func TestRouteAFileCachedInAnotherBufferPool(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	indexFileName := fmt.Sprintf("idx_%v", t.Name())
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(indexFileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	pools := NewBufferPools(PoolConfiguration{Name: "default", Capacity: 1}, fileManager, logManager)
+	assert.Nil(t, pools.AddPool(PoolConfiguration{Name: "index", Capacity: 1}))
+	defer pools.Close()
+
+	buffer, err := pools.PinNew(indexFileName, func(page *Page) {
+		page.AddString("index")
+	})
+	assert.Nil(t, err)
+	blockId := buffer.BlockId()
+
+	assert.ErrorIs(t, pools.Route("idx_", "index"), ReroutingPinnedFileError)
+	defaultPool, _ := pools.Pool("default")
+	assert.Same(t, defaultPool, pools.PoolFor(indexFileName))
+
+	pools.Unpin(buffer)
+	assert.Nil(t, pools.Route("idx_", "index"))
+	assert.True(t, defaultPool.Snapshot()[0].BlockId.IsMissing())
+
+	buffer, err = pools.Pin(blockId)
+	assert.Nil(t, err)
+	index, _ := pools.Pool("index")
+	assert.Same(t, index, pools.owners[buffer])
+	assert.Equal(t, "index", buffer.Page().GetString(0))
+	pools.Unpin(buffer)
+}
+
+func TestRouteAFileWhileABlockOfItWaitsToBePinned(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	tableFileName := t.Name()
+	indexFileName := fmt.Sprintf("idx_%v", t.Name())
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(tableFileName)
+		_ = os.Remove(indexFileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	tableBlockId, err := fileManager.AppendEmptyBlock(tableFileName)
+	assert.Nil(t, err)
+	indexBlockId, err := fileManager.AppendEmptyBlock(indexFileName)
+	assert.Nil(t, err)
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	pools := NewBufferPools(PoolConfiguration{Name: "default", Capacity: 1, MaxWaitTime: time.Second}, fileManager, logManager)
+	assert.Nil(t, pools.AddPool(PoolConfiguration{Name: "index", Capacity: 1}))
+	defer pools.Close()
+
+	tableBuffer, err := pools.Pin(tableBlockId)
+	assert.Nil(t, err)
+
+	pinned := make(chan error)
+	go func() {
+		buffer, err := pools.Pin(indexBlockId)
+		if err == nil {
+			pools.Unpin(buffer)
+		}
+		pinned <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	routed := make(chan error)
+	go func() {
+		routed <- pools.Route("idx_", "index")
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	pools.Unpin(tableBuffer)
+	assert.Nil(t, <-pinned)
+	if err := <-routed; err != nil {
+		assert.ErrorIs(t, err, ReroutingPinnedFileError)
+		assert.Nil(t, pools.Route("idx_", "index"))
+	}
+
+	defaultPool, _ := pools.Pool("default")
+	for _, frame := range defaultPool.Snapshot() {
+		assert.NotEqual(t, indexBlockId, frame.BlockId)
+	}
+}
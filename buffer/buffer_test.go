@@ -7,6 +7,7 @@ import (
 	"gorel/log"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestBufferIsPinned(t *testing.T) {
@@ -157,4 +158,83 @@ func TestFlushABuffer(t *testing.T) {
 
 	assert.Equal(t, uint32(32), reAssignedBufferPage.GetUint32(0))
 	assert.Equal(t, "BoltDB is a B+Tree based storage engine", reAssignedBufferPage.GetString(1))
+	assert.Equal(t, anyLogSequenceNumber, reAssignedBufferPage.LogSequenceNumber())
+}
+
+func TestAttemptToAssignABufferToALogBlock(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	logFileName := t.Name()
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	assert.Nil(t, logManager.Flush(1))
+
+	buffer := NewBuffer(fileManager, logManager)
+	err = buffer.AssignToBlock(file.NewBlockId(logFileName, 0))
+
+	assert.ErrorIs(t, err, file.InvalidPageHeaderError)
+	assert.True(t, buffer.BlockId().IsMissing())
+}
+
+func TestSetModifiedWithoutALogSequenceNumber(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	buffer := NewBuffer(fileManager, logManager)
+	buffer.SetModified(10, 20)
+	buffer.SetModified(11, NoLogSequenceNumber)
+
+	assert.True(t, buffer.isModified())
+	assert.Equal(t, 11, buffer.transactionNumber)
+	assert.Equal(t, uint(20), buffer.logSequenceNumber)
+	assert.Equal(t, uint(20), buffer.Page().LogSequenceNumber())
+}
+
+func TestLatchABufferExclusively(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	buffer := NewBuffer(fileManager, logManager)
+	buffer.LatchShared()
+	buffer.LatchShared()
+
+	latched := make(chan struct{})
+	go func() {
+		buffer.Latch()
+		close(latched)
+		buffer.Unlatch()
+	}()
+
+	buffer.UnlatchShared()
+	select {
+	case <-latched:
+		t.Fatal("the buffer was latched exclusively while it was latched by a reader")
+	case <-time.After(10 * time.Millisecond):
+	}
+	buffer.UnlatchShared()
+	<-latched
 }
@@ -0,0 +1,85 @@
+package buffer
+
+import "sort"
+
+// hole is a range of bytes, between the fields of a page, which is not used by any field.
+type hole struct {
+	offset uint
+	size   uint
+}
+
+func (hole hole) endOffset() uint {
+	return hole.offset + hole.size
+}
+
+// freeSpaceMap keeps the holes of a page sorted by their offsets, merging the adjacent ones.
+type freeSpaceMap struct {
+	holes []hole
+}
+
+func newFreeSpaceMap() *freeSpaceMap {
+	return &freeSpaceMap{}
+}
+
+func (freeSpaceMap *freeSpaceMap) release(offset uint, size uint) {
+	if size == 0 {
+		return
+	}
+	position := sort.Search(len(freeSpaceMap.holes), func(index int) bool {
+		return freeSpaceMap.holes[index].offset > offset
+	})
+	freeSpaceMap.holes = append(freeSpaceMap.holes, hole{})
+	copy(freeSpaceMap.holes[position+1:], freeSpaceMap.holes[position:])
+	freeSpaceMap.holes[position] = hole{offset: offset, size: size}
+
+	if position+1 < len(freeSpaceMap.holes) && freeSpaceMap.holes[position].endOffset() == freeSpaceMap.holes[position+1].offset {
+		freeSpaceMap.holes[position].size += freeSpaceMap.holes[position+1].size
+		freeSpaceMap.holes = append(freeSpaceMap.holes[:position+1], freeSpaceMap.holes[position+2:]...)
+	}
+	if position > 0 && freeSpaceMap.holes[position-1].endOffset() == freeSpaceMap.holes[position].offset {
+		freeSpaceMap.holes[position-1].size += freeSpaceMap.holes[position].size
+		freeSpaceMap.holes = append(freeSpaceMap.holes[:position], freeSpaceMap.holes[position+1:]...)
+	}
+}
+
+// allocate returns the offset of the smallest hole which can hold size bytes, and shrinks the hole.
+func (freeSpaceMap *freeSpaceMap) allocate(size uint) (uint, bool) {
+	bestFit := -1
+	for index, hole := range freeSpaceMap.holes {
+		if hole.size >= size && (bestFit == -1 || hole.size < freeSpaceMap.holes[bestFit].size) {
+			bestFit = index
+		}
+	}
+	if bestFit == -1 {
+		return 0, false
+	}
+	offset := freeSpaceMap.holes[bestFit].offset
+	freeSpaceMap.holes[bestFit].offset += size
+	freeSpaceMap.holes[bestFit].size -= size
+	if freeSpaceMap.holes[bestFit].size == 0 {
+		freeSpaceMap.holes = append(freeSpaceMap.holes[:bestFit], freeSpaceMap.holes[bestFit+1:]...)
+	}
+	return offset, true
+}
+
+// removeHoleEndingAt removes the last hole if it ends at the offset, and returns the offset at which it starts.
+func (freeSpaceMap *freeSpaceMap) removeHoleEndingAt(offset uint) (uint, bool) {
+	if len(freeSpaceMap.holes) == 0 || freeSpaceMap.holes[len(freeSpaceMap.holes)-1].endOffset() != offset {
+		return 0, false
+	}
+	lastHole := freeSpaceMap.holes[len(freeSpaceMap.holes)-1]
+	freeSpaceMap.holes = freeSpaceMap.holes[:len(freeSpaceMap.holes)-1]
+	return lastHole.offset, true
+}
+
+func (freeSpaceMap *freeSpaceMap) size() uint {
+	size := uint(0)
+	for _, hole := range freeSpaceMap.holes {
+		size += hole.size
+	}
+	return size
+}
+
+func (freeSpaceMap *freeSpaceMap) reset() {
+	freeSpaceMap.holes = nil
+}
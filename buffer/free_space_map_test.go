@@ -0,0 +1,70 @@
+package buffer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReleaseNonAdjacentHoles(t *testing.T) {
+	freeSpaceMap := newFreeSpaceMap()
+	freeSpaceMap.release(20, 5)
+	freeSpaceMap.release(0, 5)
+
+	assert.Equal(t, []hole{{offset: 0, size: 5}, {offset: 20, size: 5}}, freeSpaceMap.holes)
+	assert.Equal(t, uint(10), freeSpaceMap.size())
+}
+
+func TestReleaseAdjacentHoles(t *testing.T) {
+	freeSpaceMap := newFreeSpaceMap()
+	freeSpaceMap.release(0, 5)
+	freeSpaceMap.release(10, 5)
+	freeSpaceMap.release(5, 5)
+
+	assert.Equal(t, []hole{{offset: 0, size: 15}}, freeSpaceMap.holes)
+}
+
+func TestReleaseAnEmptyHole(t *testing.T) {
+	freeSpaceMap := newFreeSpaceMap()
+	freeSpaceMap.release(10, 0)
+
+	assert.Equal(t, 0, len(freeSpaceMap.holes))
+}
+
+func TestAllocateFromTheSmallestFittingHole(t *testing.T) {
+	freeSpaceMap := newFreeSpaceMap()
+	freeSpaceMap.release(0, 10)
+	freeSpaceMap.release(20, 4)
+	freeSpaceMap.release(30, 6)
+
+	offset, ok := freeSpaceMap.allocate(5)
+	assert.True(t, ok)
+	assert.Equal(t, uint(30), offset)
+	assert.Equal(t, []hole{{offset: 0, size: 10}, {offset: 20, size: 4}, {offset: 35, size: 1}}, freeSpaceMap.holes)
+
+	offset, ok = freeSpaceMap.allocate(4)
+	assert.True(t, ok)
+	assert.Equal(t, uint(20), offset)
+	assert.Equal(t, []hole{{offset: 0, size: 10}, {offset: 35, size: 1}}, freeSpaceMap.holes)
+}
+
+func TestAttemptToAllocateWithoutAFittingHole(t *testing.T) {
+	freeSpaceMap := newFreeSpaceMap()
+	freeSpaceMap.release(0, 10)
+
+	_, ok := freeSpaceMap.allocate(11)
+	assert.False(t, ok)
+}
+
+func TestRemoveHoleEndingAtAnOffset(t *testing.T) {
+	freeSpaceMap := newFreeSpaceMap()
+	freeSpaceMap.release(0, 10)
+	freeSpaceMap.release(20, 10)
+
+	_, ok := freeSpaceMap.removeHoleEndingAt(10)
+	assert.False(t, ok)
+
+	offset, ok := freeSpaceMap.removeHoleEndingAt(30)
+	assert.True(t, ok)
+	assert.Equal(t, uint(20), offset)
+	assert.Equal(t, []hole{{offset: 0, size: 10}}, freeSpaceMap.holes)
+}
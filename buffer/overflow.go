@@ -0,0 +1,253 @@
+package buffer
+
+import (
+	"gorel"
+	"gorel/file"
+	"sync"
+)
+
+// overflowFileSuffix is appended to the name of a file to name the file which holds the overflow chains of its
+// values.
+const overflowFileSuffix = ".overflow"
+
+// overflowThresholdDivisor moves a variable length value out of line if its encoding takes more than a quarter of
+// the page.
+const overflowThresholdDivisor = 4
+
+const (
+	overflowHeaderBlockNumber uint64 = 0
+	noOverflowBlock           uint64 = 0
+)
+
+var reservedSizeForAnOverflowBlockNumber = gorel.BytesNeededForEncodingAnUint64()
+
+// overflowPointer is stored in a page in place of a value which is moved to a chain of overflow blocks.
+type overflowPointer struct {
+	firstBlockNumber uint64
+	length           uint32
+}
+
+func (pointer overflowPointer) encode() []byte {
+	buffer := make([]byte, gorel.BytesNeededForEncodingAnUint64()+gorel.BytesNeededForEncodingAnUint32())
+	bytesNeeded := gorel.EncodeUint64(pointer.firstBlockNumber, buffer, 0)
+	gorel.EncodeUint32(pointer.length, buffer, bytesNeeded)
+	return buffer
+}
+
+func decodeOverflowPointer(buffer []byte) overflowPointer {
+	firstBlockNumber, endOffset := gorel.DecodeUint64(buffer, 0)
+	length, _ := gorel.DecodeUint32(buffer, endOffset)
+	return overflowPointer{firstBlockNumber: firstBlockNumber, length: length}
+}
+
+// overflowBlock is a block of an overflow file. The header block (block 0) holds the number of the first free
+// block. Every other block holds the number of the next block in its chain, or in the list of free blocks, followed
+// by a chunk of the value.
+type overflowBlock struct {
+	buffer []byte
+}
+
+func newOverflowBlock(blockSize uint) *overflowBlock {
+	return &overflowBlock{buffer: make([]byte, blockSize)}
+}
+
+func (block *overflowBlock) DecodeFrom(buffer []byte) error {
+	block.buffer = buffer
+	return nil
+}
+
+func (block *overflowBlock) Content() []byte {
+	return block.buffer
+}
+
+func (block *overflowBlock) nextBlockNumber() uint64 {
+	decoded, _ := gorel.DecodeUint64(block.buffer, 0)
+	return decoded
+}
+
+func (block *overflowBlock) setNextBlockNumber(blockNumber uint64) {
+	gorel.EncodeUint64(blockNumber, block.buffer, 0)
+}
+
+func (block *overflowBlock) chunk() []byte {
+	decoded, _ := gorel.DecodeByteSlice(block.buffer, uint32(reservedSizeForAnOverflowBlockNumber))
+	return decoded
+}
+
+func (block *overflowBlock) setChunk(chunk []byte) {
+	gorel.EncodeByteSlice(chunk, block.buffer, reservedSizeForAnOverflowBlockNumber)
+}
+
+// overflowStores holds the overflowStore of each BlockFileManager, which all the buffers and buffer pools over the
+// file manager share, so that their updates of the list of free blocks of an overflow file are serialized.
+var overflowStores sync.Map
+
+// overflowStore writes, reads and frees the overflow chains in the overflow files of a BlockFileManager. The blocks
+// of the overflow files are written directly, bypassing the buffer pool and the log. The chains of an overflow file
+// are written and freed under the lock of the file.
+type overflowStore struct {
+	fileManager *file.BlockFileManager
+	fileLocks   map[string]*sync.Mutex
+	lock        sync.Mutex
+}
+
+func newOverflowStore(fileManager *file.BlockFileManager) *overflowStore {
+	return &overflowStore{fileManager: fileManager, fileLocks: make(map[string]*sync.Mutex)}
+}
+
+// overflowStoreOf returns the overflowStore of the file manager, creating it on the first call.
+func overflowStoreOf(fileManager *file.BlockFileManager) *overflowStore {
+	store, _ := overflowStores.LoadOrStore(fileManager, newOverflowStore(fileManager))
+	return store.(*overflowStore)
+}
+
+// write splits the value across a chain of blocks, reusing the free blocks before appending new ones.
+func (store *overflowStore) write(fileName string, value []byte) (overflowPointer, error) {
+	overflowFileName := fileName + overflowFileSuffix
+	fileLock := store.fileLock(overflowFileName)
+	fileLock.Lock()
+	defer fileLock.Unlock()
+
+	chunkSize := store.chunkSize()
+	blockNumbers := make([]uint64, max((uint(len(value))+chunkSize-1)/chunkSize, 1))
+	for index := range blockNumbers {
+		blockNumber, err := store.allocateBlock(overflowFileName)
+		if err != nil {
+			return overflowPointer{}, err
+		}
+		blockNumbers[index] = blockNumber
+	}
+	for index, blockNumber := range blockNumbers {
+		nextBlockNumber := noOverflowBlock
+		if index+1 < len(blockNumbers) {
+			nextBlockNumber = blockNumbers[index+1]
+		}
+		block := newOverflowBlock(store.fileManager.BlockSize())
+		block.setNextBlockNumber(nextBlockNumber)
+		block.setChunk(value[uint(index)*chunkSize : min(uint(index+1)*chunkSize, uint(len(value)))])
+		if err := store.writeBlock(overflowFileName, blockNumber, block); err != nil {
+			return overflowPointer{}, err
+		}
+	}
+	return overflowPointer{firstBlockNumber: blockNumbers[0], length: uint32(len(value))}, nil
+}
+
+func (store *overflowStore) read(fileName string, pointer overflowPointer) ([]byte, error) {
+	overflowFileName := fileName + overflowFileSuffix
+	value := make([]byte, 0, pointer.length)
+	for blockNumber := pointer.firstBlockNumber; blockNumber != noOverflowBlock; {
+		block, err := store.readBlock(overflowFileName, blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, block.chunk()...)
+		blockNumber = block.nextBlockNumber()
+		store.fileManager.PageBuffers().Put(block.buffer)
+	}
+	return value, nil
+}
+
+// free prepends the blocks of the chain to the list of free blocks.
+func (store *overflowStore) free(fileName string, pointer overflowPointer) error {
+	overflowFileName := fileName + overflowFileSuffix
+	fileLock := store.fileLock(overflowFileName)
+	fileLock.Lock()
+	defer fileLock.Unlock()
+
+	lastBlockNumber := pointer.firstBlockNumber
+	lastBlock, err := store.readBlock(overflowFileName, lastBlockNumber)
+	if err != nil {
+		return err
+	}
+	for lastBlock.nextBlockNumber() != noOverflowBlock {
+		lastBlockNumber = lastBlock.nextBlockNumber()
+		if lastBlock, err = store.readBlock(overflowFileName, lastBlockNumber); err != nil {
+			return err
+		}
+	}
+	header, err := store.readBlock(overflowFileName, overflowHeaderBlockNumber)
+	if err != nil {
+		return err
+	}
+	lastBlock.setNextBlockNumber(header.nextBlockNumber())
+	if err := store.writeBlock(overflowFileName, lastBlockNumber, lastBlock); err != nil {
+		return err
+	}
+	header.setNextBlockNumber(pointer.firstBlockNumber)
+	return store.writeBlock(overflowFileName, overflowHeaderBlockNumber, header)
+}
+
+func (store *overflowStore) allocateBlock(overflowFileName string) (uint64, error) {
+	numberOfBlocks, err := store.fileManager.NumberOfBlocks(overflowFileName)
+	if err != nil {
+		return 0, err
+	}
+	if numberOfBlocks == 0 {
+		if _, err := store.fileManager.AppendEmptyBlock(overflowFileName); err != nil {
+			return 0, err
+		}
+	}
+	header, err := store.readBlock(overflowFileName, overflowHeaderBlockNumber)
+	if err != nil {
+		return 0, err
+	}
+	if freeBlockNumber := header.nextBlockNumber(); freeBlockNumber != noOverflowBlock {
+		freeBlock, err := store.readBlock(overflowFileName, freeBlockNumber)
+		if err != nil {
+			return 0, err
+		}
+		header.setNextBlockNumber(freeBlock.nextBlockNumber())
+		return freeBlockNumber, store.writeBlock(overflowFileName, overflowHeaderBlockNumber, header)
+	}
+	blockId, err := store.fileManager.AppendEmptyBlock(overflowFileName)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(blockId.BlockNumber()), nil
+}
+
+func (store *overflowStore) fileLock(overflowFileName string) *sync.Mutex {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	fileLock, ok := store.fileLocks[overflowFileName]
+	if !ok {
+		fileLock = &sync.Mutex{}
+		store.fileLocks[overflowFileName] = fileLock
+	}
+	return fileLock
+}
+
+func (store *overflowStore) chunkSize() uint {
+	return store.fileManager.BlockSize() - reservedSizeForAnOverflowBlockNumber - gorel.BytesNeededForEncodingAByteSlice(nil)
+}
+
+func (store *overflowStore) readBlock(overflowFileName string, blockNumber uint64) (*overflowBlock, error) {
+	block := &overflowBlock{}
+	if err := store.fileManager.ReadInto(file.NewBlockId(overflowFileName, uint(blockNumber)), block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (store *overflowStore) writeBlock(overflowFileName string, blockNumber uint64, block *overflowBlock) error {
+	return store.fileManager.Write(file.NewBlockId(overflowFileName, uint(blockNumber)), block)
+}
+
+// overflowFile binds a page to the overflow chains of the file of its block.
+type overflowFile struct {
+	store    *overflowStore
+	fileName string
+}
+
+func (overflowFile *overflowFile) write(value []byte) (overflowPointer, error) {
+	return overflowFile.store.write(overflowFile.fileName, value)
+}
+
+func (overflowFile *overflowFile) read(pointer overflowPointer) ([]byte, error) {
+	return overflowFile.store.read(overflowFile.fileName, pointer)
+}
+
+func (overflowFile *overflowFile) free(pointer overflowPointer) error {
+	return overflowFile.store.free(overflowFile.fileName, pointer)
+}
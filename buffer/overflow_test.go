@@ -0,0 +1,304 @@
+package buffer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestEncodeAndDecodeAnOverflowPointer(t *testing.T) {
+	pointer := overflowPointer{firstBlockNumber: 12, length: 9000}
+	assert.Equal(t, pointer, decodeOverflowPointer(pointer.encode()))
+}
+
+func TestWriteAndReadAnOverflowChain(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName + overflowFileSuffix)
+	}()
+
+	store := newOverflowStore(fileManager)
+	value := bytes.Repeat([]byte("PebbleDB"), 1500)
+
+	pointer, err := store.write(fileName, value)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), pointer.firstBlockNumber)
+	assert.Equal(t, uint32(len(value)), pointer.length)
+
+	readValue, err := store.read(fileName, pointer)
+	assert.Nil(t, err)
+	assert.Equal(t, value, readValue)
+
+	numberOfBlocks, err := fileManager.NumberOfBlocks(fileName + overflowFileSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1+3), numberOfBlocks)
+}
+
+func TestFreeAnOverflowChainAndReuseItsBlocks(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName + overflowFileSuffix)
+	}()
+
+	store := newOverflowStore(fileManager)
+	pointer, err := store.write(fileName, bytes.Repeat([]byte("BoltDB"), 2000))
+	assert.Nil(t, err)
+	assert.Nil(t, store.free(fileName, pointer))
+
+	value := bytes.Repeat([]byte("RocksDB"), 1000)
+	pointer, err = store.write(fileName, value)
+	assert.Nil(t, err)
+
+	readValue, err := store.read(fileName, pointer)
+	assert.Nil(t, err)
+	assert.Equal(t, value, readValue)
+
+	numberOfBlocks, err := fileManager.NumberOfBlocks(fileName + overflowFileSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1+3), numberOfBlocks)
+}
+
+func TestAllocateAndFreeOverflowChainsConcurrentlyAcrossBufferPools(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName + overflowFileSuffix)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+	otherBufferManager := NewBufferManager(2, fileManager, logManager)
+	assert.Same(t, bufferManager.overflowStore, otherBufferManager.overflowStore)
+
+	var writers sync.WaitGroup
+	for writer, manager := range []*BufferManager{bufferManager, otherBufferManager, bufferManager, otherBufferManager} {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for round := 0; round < 50; round++ {
+				value := bytes.Repeat([]byte(fmt.Sprintf("%d:%d;", writer, round)), 200)
+				pointer, err := manager.overflowStore.write(fileName, value)
+				assert.Nil(t, err)
+				readValue, err := manager.overflowStore.read(fileName, pointer)
+				assert.Nil(t, err)
+				assert.Equal(t, value, readValue)
+				assert.Nil(t, manager.FreeOverflowChain(fileName, TypeString.withOverflow(), pointer.encode()))
+			}
+		}()
+	}
+	writers.Wait()
+
+	numberOfBlocks, err := fileManager.NumberOfBlocks(fileName + overflowFileSuffix)
+	assert.Nil(t, err)
+	freeBlocks := make(map[uint64]bool)
+	header, err := bufferManager.overflowStore.readBlock(fileName+overflowFileSuffix, overflowHeaderBlockNumber)
+	assert.Nil(t, err)
+	for blockNumber := header.nextBlockNumber(); blockNumber != noOverflowBlock && !freeBlocks[blockNumber]; {
+		freeBlocks[blockNumber] = true
+		block, err := bufferManager.overflowStore.readBlock(fileName+overflowFileSuffix, blockNumber)
+		assert.Nil(t, err)
+		blockNumber = block.nextBlockNumber()
+	}
+	assert.Equal(t, int(numberOfBlocks-1), len(freeBlocks))
+}
+
+func TestAddALargeStringInThePageOfABufferAndReadItAfterEviction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(fileName + overflowFileSuffix)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	value := strings.Repeat("RocksDB is an LSM-based key/value storage engine", 200)
+
+	buffer, err := bufferManager.PinNew(fileName, func(page *Page) {
+		assert.True(t, page.AddUint16(16))
+		assert.True(t, page.AddString(value))
+	})
+	assert.Nil(t, err)
+	blockId := buffer.BlockId()
+	assert.Equal(t, value, buffer.Page().GetString(1))
+	bufferManager.Unpin(buffer)
+
+	otherBuffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+	bufferManager.Unpin(otherBuffer)
+
+	buffer, err = bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(16), buffer.Page().GetUint16(0))
+	assert.Equal(t, value, buffer.Page().GetString(1))
+}
+
+func TestDeleteALargeValueAndReuseItsOverflowChain(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(fileName + overflowFileSuffix)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	buffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+
+	page := buffer.Page()
+	assert.True(t, page.AddBytes(bytes.Repeat([]byte("BoltDB"), 2000)))
+	numberOfBlocks, err := fileManager.NumberOfBlocks(fileName + overflowFileSuffix)
+	assert.Nil(t, err)
+
+	page.Delete(0)
+	value := bytes.Repeat([]byte("PebbleDB"), 1000)
+	assert.True(t, page.AddBytes(value))
+
+	assert.Equal(t, value, page.GetBytes(1))
+	numberOfBlocksAfterReuse, err := fileManager.NumberOfBlocks(fileName + overflowFileSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, numberOfBlocks, numberOfBlocksAfterReuse)
+}
+
+func TestMutateALargeValueToASmallValueAndBack(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(fileName + overflowFileSuffix)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	buffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+
+	page := buffer.Page()
+	largeValue := strings.Repeat("BoltDB is a B+Tree based storage engine", 100)
+	assert.True(t, page.AddString("Bolt"))
+	assert.True(t, page.AddUint8(8))
+	freeSpace := page.FreeSpace()
+
+	assert.True(t, page.MutateString(0, largeValue))
+	assert.Equal(t, largeValue, page.GetString(0))
+	assert.True(t, page.types.GetTypeAt(0).IsOverflow())
+
+	assert.True(t, page.MutateString(0, "Pebble"))
+	assert.Equal(t, "Pebble", page.GetString(0))
+	assert.False(t, page.types.GetTypeAt(0).IsOverflow())
+	assert.Equal(t, uint8(8), page.GetUint8(1))
+	assert.LessOrEqual(t, page.FreeSpace(), freeSpace)
+
+	page.SetNull(0)
+	assert.True(t, page.IsNull(0))
+}
+
+func TestALargeValueInAPageWithoutABufferIsStoredInline(t *testing.T) {
+	page := NewPage(blockSize)
+	value := strings.Repeat("RocksDB", 300)
+
+	assert.True(t, page.AddString(value))
+	assert.False(t, page.types.GetTypeAt(0).IsOverflow())
+	assert.Equal(t, value, page.GetString(0))
+}
+
+func TestStoreALargeValueAndMutateTheStoredImagesWithoutFreeingTheirChains(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(fileName + overflowFileSuffix)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	buffer, err := bufferManager.PinNew(fileName, nil)
+	assert.Nil(t, err)
+
+	page := buffer.Page()
+	largeValue := strings.Repeat("BoltDB", 1000)
+	assert.True(t, page.AddString(largeValue))
+
+	typeDescription, stored, err := page.TryGetStored(0)
+	assert.Nil(t, err)
+	assert.Equal(t, TypeString.withOverflow(), typeDescription)
+	assert.Equal(t, page.overflowPointerAt(0).encode(), stored)
+
+	otherTypeDescription, otherStored, err := page.TryStore(TypeString, []byte(strings.Repeat("RocksDB", 1000)))
+	assert.Nil(t, err)
+	assert.True(t, otherTypeDescription.IsOverflow())
+
+	for range 2 {
+		mutated, err := page.TryMutateStored(0, otherTypeDescription, otherStored)
+		assert.Nil(t, err)
+		assert.True(t, mutated)
+		assert.Equal(t, strings.Repeat("RocksDB", 1000), page.GetString(0))
+
+		mutated, err = page.TryMutateStored(0, TypeTombstone, nil)
+		assert.Nil(t, err)
+		assert.True(t, mutated)
+
+		mutated, err = page.TryMutateStored(0, typeDescription, stored)
+		assert.Nil(t, err)
+		assert.True(t, mutated)
+		assert.Equal(t, largeValue, page.GetString(0))
+	}
+
+	smallTypeDescription, smallStored, err := page.TryStore(TypeString, []byte("Pebble"))
+	assert.Nil(t, err)
+	assert.Equal(t, TypeString, smallTypeDescription)
+	assert.Equal(t, []byte("Pebble"), smallStored)
+
+	assert.Nil(t, bufferManager.FreeOverflowChain(fileName, otherTypeDescription, otherStored))
+	assert.Nil(t, bufferManager.FreeOverflowChain(fileName, smallTypeDescription, smallStored))
+	assert.Equal(t, largeValue, page.GetString(0))
+}
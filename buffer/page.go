@@ -1,151 +1,650 @@
 package buffer
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"gorel"
 	"gorel/file"
+	"sort"
+	"time"
 	"unsafe"
 )
 
-var reservedSizeForNumberOfOffsets = int(unsafe.Sizeof(uint16(0)))
+var reservedSizeForNumberOfOffsets = int(unsafe.Sizeof(uint32(0)))
 
-// Page TODO: Deletion of value(s) in page? handling holes in page?
+// The Try variants of the accessors of a Page return these errors (wrapped with the details) where the other
+// variants panic.
+var (
+	ErrIndexOutOfBounds = errors.New("index out of bounds")
+	ErrTypeMismatch     = errors.New("type description mismatch")
+	ErrNullField        = errors.New("field is null")
+)
+
+// Page is a slotted page: fields are written after the header at the beginning of the page, and the trailer (types, starting
+// offsets and the number of offsets) is written at the end of the page by finish. Deleting a field leaves a
+// tombstone in its slot, and the holes left by deleted or relocated fields are tracked in the freeSpaceMap. The page
+// of a buffer stores its large string and byte slice values in the overflow chains of the overflow file.
 type Page struct {
 	buffer             []byte
 	startingOffsets    *file.StartingOffsets
 	types              *Types
+	freeSpaceMap       *freeSpaceMap
+	overflow           *overflowFile
+	bufferPool         *file.PageBufferPool
 	currentWriteOffset uint
 }
 
+// NewPage creates a data page; the formatter of a new block may change the page type.
 func NewPage(blockSize uint) *Page {
-	return &Page{
+	page := &Page{
 		buffer:             make([]byte, blockSize),
 		startingOffsets:    file.NewStartingOffsets(),
 		types:              NewTypes(),
-		currentWriteOffset: 0,
+		freeSpaceMap:       newFreeSpaceMap(),
+		currentWriteOffset: file.PageHeaderSize,
 	}
+	page.header().Format(file.PageTypeData)
+	return page
 }
 
-func (page *Page) DecodeFrom(buffer []byte) {
-	numberOfOffsets := binary.LittleEndian.Uint16(buffer[len(buffer)-reservedSizeForNumberOfOffsets:])
+// newPooledPage creates a page whose buffer is taken from the pool, and is returned to the pool when the page
+// decodes another buffer or is released.
+func newPooledPage(bufferPool *file.PageBufferPool) *Page {
+	page := newUnbufferedPooledPage(bufferPool)
+	page.buffer = bufferPool.Get()
+	page.reset()
+	return page
+}
+
+// newUnbufferedPooledPage creates a page that gets its buffer by decoding it.
+func newUnbufferedPooledPage(bufferPool *file.PageBufferPool) *Page {
+	return &Page{
+		startingOffsets: file.NewStartingOffsets(),
+		types:           NewTypes(),
+		freeSpaceMap:    newFreeSpaceMap(),
+		bufferPool:      bufferPool,
+	}
+}
+
+// DecodeFrom validates the header, which is formatted as a data page if the block was never written, and takes over
+// the buffer, returning the previous buffer to the pool if the page belongs to a buffer. The starting offsets and
+// the types are read straight from the trailer until the page is modified, and the free space is computed only
+// when the page is about to be modified.
+func (page *Page) DecodeFrom(buffer []byte) error {
+	header := file.PageHeaderOf(buffer)
+	if !header.IsFormatted() {
+		header.Format(file.PageTypeData)
+	}
+	if err := header.Validate(file.PageTypeData, file.PageTypeIndex); err != nil {
+		return err
+	}
+	numberOfOffsets := binary.LittleEndian.Uint32(buffer[len(buffer)-reservedSizeForNumberOfOffsets:])
 	numberOfTypeDescriptions := numberOfOffsets
 
+	page.recycle(buffer)
+	page.buffer = buffer
+	page.currentWriteOffset = file.PageHeaderSize
 	if numberOfOffsets == 0 {
-		page.buffer = buffer
 		page.startingOffsets = file.NewStartingOffsets()
 		page.types = NewTypes()
-		page.currentWriteOffset = 0
-		return
+		page.freeSpaceMap = newFreeSpaceMap()
+		return nil
 	}
 	offsetAtWhichEncodedStartingOffsetsAreWritten := len(buffer) - reservedSizeForNumberOfOffsets - file.SizeUsedInBytesFor(numberOfOffsets)
-	startingOffsets := file.DecodeStartingOffsetsFrom(
-		buffer[offsetAtWhichEncodedStartingOffsetsAreWritten : offsetAtWhichEncodedStartingOffsetsAreWritten+reservedSizeForNumberOfOffsets*int(numberOfOffsets)],
+	page.startingOffsets = file.LazilyDecodeStartingOffsetsFrom(
+		buffer[offsetAtWhichEncodedStartingOffsetsAreWritten : offsetAtWhichEncodedStartingOffsetsAreWritten+file.SizeUsedInBytesFor(numberOfOffsets)],
 	)
 
 	offsetAtWhichEncodedTypeDescriptionsAreWritten := offsetAtWhichEncodedStartingOffsetsAreWritten - SizeUsedInBytes(numberOfTypeDescriptions)
-	types := DecodeTypesFrom(buffer[offsetAtWhichEncodedTypeDescriptionsAreWritten : offsetAtWhichEncodedTypeDescriptionsAreWritten+ReservedSizeForAType*int(numberOfTypeDescriptions)])
+	page.types = LazilyDecodeTypesFrom(buffer[offsetAtWhichEncodedTypeDescriptionsAreWritten : offsetAtWhichEncodedTypeDescriptionsAreWritten+SizeUsedInBytes(numberOfTypeDescriptions)])
+	page.freeSpaceMap = nil
+	return nil
+}
 
-	page.buffer = buffer
-	page.startingOffsets = startingOffsets
-	page.types = types
-	page.updateCurrentWriteOffset()
+func (page *Page) PageType() file.PageType {
+	return page.header().PageType()
+}
+
+func (page *Page) SetPageType(pageType file.PageType) {
+	page.header().SetPageType(pageType)
+}
+
+func (page *Page) Flags() uint16 {
+	return page.header().Flags()
+}
+
+func (page *Page) SetFlags(flags uint16) {
+	page.header().SetFlags(flags)
+}
+
+// LogSequenceNumber returns the log sequence number of the latest log record which modified the page.
+func (page *Page) LogSequenceNumber() uint {
+	return uint(page.header().LogSequenceNumber())
+}
+
+func (page *Page) SetLogSequenceNumber(logSequenceNumber uint) {
+	page.header().SetLogSequenceNumber(uint64(logSequenceNumber))
 }
 
-// AddUint8 TODO: validate capacity before adding, for all the methods.
-func (page *Page) AddUint8(value uint8) {
-	page.addField(
-		func() gorel.BytesNeededForEncoding {
-			return gorel.EncodeUint8(value, page.buffer, page.currentWriteOffset)
+// AddUint8 adds the value if the page has capacity for it, and returns false otherwise. The capacity accounts for
+// the starting offset and the type description that finish writes for each field; the same holds for all the Add
+// methods.
+func (page *Page) AddUint8(value uint8) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnUint8(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeUint8(value, page.buffer, destinationOffset)
 		},
 		TypeUint8,
 	)
 }
 
-func (page *Page) MutateUint8(index int, value uint8) {
-	page.mutateField(index, TypeUint8, func(destinationOffset uint) gorel.BytesNeededForEncoding {
+func (page *Page) MutateUint8(index int, value uint8) bool {
+	return must(page.TryMutateUint8(index, value))
+}
+
+// TryMutateUint8 returns ErrIndexOutOfBounds or ErrTypeMismatch where MutateUint8 panics, and false (without an
+// error) if the page does not have the capacity for the value; the same holds for all the TryMutate methods.
+func (page *Page) TryMutateUint8(index int, value uint8) (bool, error) {
+	return page.mutateField(index, TypeUint8, gorel.BytesNeededForEncodingAnUint8(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
 		return gorel.EncodeUint8(value, page.buffer, destinationOffset)
 	})
 }
 
-func (page *Page) AddUint16(value uint16) {
-	page.addField(
-		func() gorel.BytesNeededForEncoding {
-			return gorel.EncodeUint16(value, page.buffer, page.currentWriteOffset)
+func (page *Page) AddUint16(value uint16) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnUint16(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeUint16(value, page.buffer, destinationOffset)
 		},
 		TypeUint16,
 	)
 }
 
-func (page *Page) MutateUint16(index int, value uint16) {
-	page.mutateField(index, TypeUint16, func(destinationOffset uint) gorel.BytesNeededForEncoding {
+func (page *Page) MutateUint16(index int, value uint16) bool {
+	return must(page.TryMutateUint16(index, value))
+}
+
+func (page *Page) TryMutateUint16(index int, value uint16) (bool, error) {
+	return page.mutateField(index, TypeUint16, gorel.BytesNeededForEncodingAnUint16(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
 		return gorel.EncodeUint16(value, page.buffer, destinationOffset)
 	})
 }
 
-func (page *Page) AddUint32(value uint32) {
-	page.addField(
-		func() gorel.BytesNeededForEncoding {
-			return gorel.EncodeUint32(value, page.buffer, page.currentWriteOffset)
+func (page *Page) AddUint32(value uint32) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnUint32(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeUint32(value, page.buffer, destinationOffset)
 		},
 		TypeUint32,
 	)
 }
 
-func (page *Page) MutateUint32(index int, value uint32) {
-	page.mutateField(index, TypeUint32, func(destinationOffset uint) gorel.BytesNeededForEncoding {
+func (page *Page) MutateUint32(index int, value uint32) bool {
+	return must(page.TryMutateUint32(index, value))
+}
+
+func (page *Page) TryMutateUint32(index int, value uint32) (bool, error) {
+	return page.mutateField(index, TypeUint32, gorel.BytesNeededForEncodingAnUint32(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
 		return gorel.EncodeUint32(value, page.buffer, destinationOffset)
 	})
 }
 
-func (page *Page) AddUint64(value uint64) {
-	page.addField(
-		func() gorel.BytesNeededForEncoding {
-			return gorel.EncodeUint64(value, page.buffer, page.currentWriteOffset)
+func (page *Page) AddUint64(value uint64) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnUint64(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeUint64(value, page.buffer, destinationOffset)
 		},
 		TypeUint64,
 	)
 }
 
-func (page *Page) MutateUint64(index int, value uint64) {
-	page.mutateField(index, TypeUint64, func(destinationOffset uint) gorel.BytesNeededForEncoding {
+func (page *Page) MutateUint64(index int, value uint64) bool {
+	return must(page.TryMutateUint64(index, value))
+}
+
+func (page *Page) TryMutateUint64(index int, value uint64) (bool, error) {
+	return page.mutateField(index, TypeUint64, gorel.BytesNeededForEncodingAnUint64(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
 		return gorel.EncodeUint64(value, page.buffer, destinationOffset)
 	})
 }
 
-func (page *Page) AddBytes(buffer []byte) {
-	page.addField(
-		func() gorel.BytesNeededForEncoding {
-			return gorel.EncodeByteSlice(buffer, page.buffer, page.currentWriteOffset)
+// AddBytes adds the value in the page, or moves it to a chain of overflow blocks and adds a pointer to the chain if
+// the page belongs to a buffer and the value takes more than a quarter of the page. It returns false if the page
+// does not have the capacity for the value (or the pointer), or if the overflow chain cannot be written.
+func (page *Page) AddBytes(buffer []byte) bool {
+	return page.addVariableLengthField(TypeByteSlice, buffer)
+}
+
+// MutateBytes replaces the value in place if it fits in the space of the existing value, and relocates it to the
+// end of the written fields otherwise, compacting the page if needed. Like AddBytes, a large value is moved to a
+// chain of overflow blocks, and the chain of the existing value is freed. It returns false, leaving the page
+// unchanged, if the page does not have the capacity for the value.
+func (page *Page) MutateBytes(index int, value []byte) bool {
+	return must(page.TryMutateBytes(index, value))
+}
+
+// TryMutateBytes also returns the error if the overflow chain of the value cannot be written.
+func (page *Page) TryMutateBytes(index int, value []byte) (bool, error) {
+	return page.mutateVariableLengthField(index, TypeByteSlice, value)
+}
+
+// AddString behaves like AddBytes.
+func (page *Page) AddString(str string) bool {
+	return page.addVariableLengthField(TypeString, []byte(str))
+}
+
+func (page *Page) AddInt8(value int8) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnInt8(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeInt8(value, page.buffer, destinationOffset)
+		},
+		TypeInt8,
+	)
+}
+
+func (page *Page) MutateInt8(index int, value int8) bool {
+	return must(page.TryMutateInt8(index, value))
+}
+
+func (page *Page) TryMutateInt8(index int, value int8) (bool, error) {
+	return page.mutateField(index, TypeInt8, gorel.BytesNeededForEncodingAnInt8(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeInt8(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddInt16(value int16) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnInt16(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeInt16(value, page.buffer, destinationOffset)
+		},
+		TypeInt16,
+	)
+}
+
+func (page *Page) MutateInt16(index int, value int16) bool {
+	return must(page.TryMutateInt16(index, value))
+}
+
+func (page *Page) TryMutateInt16(index int, value int16) (bool, error) {
+	return page.mutateField(index, TypeInt16, gorel.BytesNeededForEncodingAnInt16(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeInt16(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddInt32(value int32) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnInt32(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeInt32(value, page.buffer, destinationOffset)
+		},
+		TypeInt32,
+	)
+}
+
+func (page *Page) MutateInt32(index int, value int32) bool {
+	return must(page.TryMutateInt32(index, value))
+}
+
+func (page *Page) TryMutateInt32(index int, value int32) (bool, error) {
+	return page.mutateField(index, TypeInt32, gorel.BytesNeededForEncodingAnInt32(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeInt32(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddInt64(value int64) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAnInt64(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeInt64(value, page.buffer, destinationOffset)
+		},
+		TypeInt64,
+	)
+}
+
+func (page *Page) MutateInt64(index int, value int64) bool {
+	return must(page.TryMutateInt64(index, value))
+}
+
+func (page *Page) TryMutateInt64(index int, value int64) (bool, error) {
+	return page.mutateField(index, TypeInt64, gorel.BytesNeededForEncodingAnInt64(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeInt64(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddFloat32(value float32) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAFloat32(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeFloat32(value, page.buffer, destinationOffset)
+		},
+		TypeFloat32,
+	)
+}
+
+func (page *Page) MutateFloat32(index int, value float32) bool {
+	return must(page.TryMutateFloat32(index, value))
+}
+
+func (page *Page) TryMutateFloat32(index int, value float32) (bool, error) {
+	return page.mutateField(index, TypeFloat32, gorel.BytesNeededForEncodingAFloat32(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeFloat32(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddFloat64(value float64) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingAFloat64(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeFloat64(value, page.buffer, destinationOffset)
+		},
+		TypeFloat64,
+	)
+}
+
+func (page *Page) MutateFloat64(index int, value float64) bool {
+	return must(page.TryMutateFloat64(index, value))
+}
+
+func (page *Page) TryMutateFloat64(index int, value float64) (bool, error) {
+	return page.mutateField(index, TypeFloat64, gorel.BytesNeededForEncodingAFloat64(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeFloat64(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddBool(value bool) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingABool(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeBool(value, page.buffer, destinationOffset)
+		},
+		TypeBool,
+	)
+}
+
+func (page *Page) MutateBool(index int, value bool) bool {
+	return must(page.TryMutateBool(index, value))
+}
+
+func (page *Page) TryMutateBool(index int, value bool) (bool, error) {
+	return page.mutateField(index, TypeBool, gorel.BytesNeededForEncodingABool(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeBool(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddDate(value time.Time) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingADate(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeDate(value, page.buffer, destinationOffset)
 		},
-		TypeByteSlice,
+		TypeDate,
 	)
 }
 
-// MutateBytes TODO: what if the value does not fit?
-func (page *Page) MutateBytes(index int, value []byte) {
-	page.mutateField(index, TypeByteSlice, func(destinationOffset uint) gorel.BytesNeededForEncoding {
-		return gorel.EncodeByteSlice(value, page.buffer, destinationOffset)
+func (page *Page) MutateDate(index int, value time.Time) bool {
+	return must(page.TryMutateDate(index, value))
+}
+
+func (page *Page) TryMutateDate(index int, value time.Time) (bool, error) {
+	return page.mutateField(index, TypeDate, gorel.BytesNeededForEncodingADate(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeDate(value, page.buffer, destinationOffset)
 	})
 }
 
-func (page *Page) AddString(str string) {
-	page.addField(
-		func() gorel.BytesNeededForEncoding {
-			return gorel.EncodeByteSlice([]byte(str), page.buffer, page.currentWriteOffset)
+func (page *Page) AddTimestamp(value time.Time) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingATimestamp(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeTimestamp(value, page.buffer, destinationOffset)
 		},
-		TypeString,
+		TypeTimestamp,
 	)
 }
 
-func (page *Page) MutateString(index int, value string) {
-	page.mutateField(index, TypeString, func(destinationOffset uint) gorel.BytesNeededForEncoding {
-		return gorel.EncodeByteSlice([]byte(value), page.buffer, destinationOffset)
+func (page *Page) MutateTimestamp(index int, value time.Time) bool {
+	return must(page.TryMutateTimestamp(index, value))
+}
+
+func (page *Page) TryMutateTimestamp(index int, value time.Time) (bool, error) {
+	return page.mutateField(index, TypeTimestamp, gorel.BytesNeededForEncodingATimestamp(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeTimestamp(value, page.buffer, destinationOffset)
+	})
+}
+
+func (page *Page) AddDecimal(value gorel.Decimal) bool {
+	return page.addField(
+		gorel.BytesNeededForEncodingADecimal(),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeDecimal(value, page.buffer, destinationOffset)
+		},
+		TypeDecimal,
+	)
+}
+
+func (page *Page) MutateDecimal(index int, value gorel.Decimal) bool {
+	return must(page.TryMutateDecimal(index, value))
+}
+
+func (page *Page) TryMutateDecimal(index int, value gorel.Decimal) (bool, error) {
+	return page.mutateField(index, TypeDecimal, gorel.BytesNeededForEncodingADecimal(), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return gorel.EncodeDecimal(value, page.buffer, destinationOffset)
+	})
+}
+
+// AddNull adds a NULL field of the type, which does not occupy any bytes other than its starting offset and type
+// description.
+func (page *Page) AddNull(typeDescription TypeDescription) bool {
+	return page.addField(
+		0,
+		func(uint) gorel.BytesNeededForEncoding {
+			return 0
+		},
+		typeDescription.WithNull(),
+	)
+}
+
+func (page *Page) IsNull(index int) bool {
+	page.assertIndexInBounds(index)
+	return page.types.GetTypeAt(index).IsNull()
+}
+
+// SetNull makes the field at the index NULL, releasing the bytes of its value. Mutating the field gives it a value
+// again.
+func (page *Page) SetNull(index int) {
+	page.setNull(index, true)
+}
+
+func (page *Page) setNull(index int, freesOverflowChain bool) {
+	page.assertIndexInBounds(index)
+	typeDescription := page.types.GetTypeAt(index)
+	gorel.Assert(!typeDescription.Equals(TypeTombstone), "field at index %d is deleted", index)
+	if typeDescription.IsNull() {
+		return
+	}
+	if freesOverflowChain {
+		page.freeOverflowChainAt(index)
+	}
+	startingOffset := uint(page.startingOffsets.OffsetAtIndex(index))
+	page.release(startingOffset, page.endOffsetOf(index)-startingOffset)
+	page.startingOffsets.SetOffsetAtIndex(index, 0)
+	page.types.SetTypeAt(index, typeDescription.WithoutOverflow().WithNull())
+}
+
+// Delete replaces the field at the index with a tombstone, so that the indices of the other fields do not change.
+// The bytes of the deleted field are reused by the fields that are added or relocated later, and the blocks of its
+// overflow chain are reused by the values that overflow later.
+func (page *Page) Delete(index int) {
+	page.delete(index, true)
+}
+
+func (page *Page) delete(index int, freesOverflowChain bool) {
+	page.assertIndexInBounds(index)
+	if page.IsDeleted(index) {
+		return
+	}
+	if freesOverflowChain {
+		page.freeOverflowChainAt(index)
+	}
+	startingOffset := uint(page.startingOffsets.OffsetAtIndex(index))
+	page.release(startingOffset, page.endOffsetOf(index)-startingOffset)
+	page.types.SetTypeAt(index, TypeTombstone)
+}
+
+func (page *Page) IsDeleted(index int) bool {
+	page.assertIndexInBounds(index)
+	return page.types.GetTypeAt(index).Equals(TypeTombstone)
+}
+
+// Compact moves all the fields towards the beginning of the page, removing the holes between them.
+func (page *Page) Compact() {
+	page.compactExcluding(-1)
+}
+
+// MutateString behaves like MutateBytes.
+func (page *Page) MutateString(index int, value string) bool {
+	return must(page.TryMutateString(index, value))
+}
+
+func (page *Page) TryMutateString(index int, value string) (bool, error) {
+	return page.mutateVariableLengthField(index, TypeString, []byte(value))
+}
+
+// NumberOfFields returns the number of fields in the page, including the deleted ones.
+func (page *Page) NumberOfFields() int {
+	return page.startingOffsets.Length()
+}
+
+// TryGetEncoded returns the type description of the field at the index along with a copy of its encoded value: the
+// bytes of a fixed size value as they are encoded in the page, or the bytes of a string or a byte slice value without
+// its length, reassembled from its overflow chain if it is stored out of line. A NULL or a deleted field has no bytes.
+func (page *Page) TryGetEncoded(index int) (TypeDescription, []byte, error) {
+	if err := page.checkIndexInBounds(index); err != nil {
+		return TypeTombstone, nil, err
+	}
+	typeDescription := page.types.GetTypeAt(index)
+	if !typeDescription.occupiesBytes() {
+		return typeDescription, nil, nil
+	}
+	if typeDescription.isVariableLength() {
+		value, err := page.variableLengthValueAt(index)
+		if err != nil {
+			return TypeTombstone, nil, err
+		}
+		return typeDescription.WithoutOverflow(), bytes.Clone(value), nil
+	}
+	startingOffset := uint(page.startingOffsets.OffsetAtIndex(index))
+	return typeDescription, bytes.Clone(page.buffer[startingOffset:page.endOffsetOf(index)]), nil
+}
+
+// TryMutateEncoded sets the field at the index to a value returned by TryGetEncoded: it makes the field NULL for a
+// NULL type description, and deletes the field for TypeTombstone. The field is added if the index is equal to the
+// number of fields, and a deleted field is restored, which lets the undo of a deletion restore the before-image. Like
+// the other TryMutate methods, it returns false if the page does not have the capacity for the value.
+func (page *Page) TryMutateEncoded(index int, typeDescription TypeDescription, encoded []byte) (bool, error) {
+	return page.tryMutateImage(index, typeDescription, encoded, true)
+}
+
+// TryGetStored behaves like TryGetEncoded, except that the value of a field which is stored out of line is not
+// reassembled: the copy holds the encoded pointer to its overflow chain, and the type description IsOverflow.
+func (page *Page) TryGetStored(index int) (TypeDescription, []byte, error) {
+	if err := page.checkIndexInBounds(index); err != nil {
+		return TypeTombstone, nil, err
+	}
+	typeDescription := page.types.GetTypeAt(index)
+	if !typeDescription.occupiesBytes() {
+		return typeDescription, nil, nil
+	}
+	if typeDescription.isVariableLength() {
+		decoded, _ := gorel.DecodeByteSlice(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+		return typeDescription, bytes.Clone(decoded), nil
+	}
+	startingOffset := uint(page.startingOffsets.OffsetAtIndex(index))
+	return typeDescription, bytes.Clone(page.buffer[startingOffset:page.endOffsetOf(index)]), nil
+}
+
+// TryStore returns the image of an encoded value which TryMutateStored sets a field to. A string or a byte slice
+// value which overflows is written to a new overflow chain, and its image is the encoded pointer to the chain; the
+// image of any other value is the value itself.
+func (page *Page) TryStore(typeDescription TypeDescription, encoded []byte) (TypeDescription, []byte, error) {
+	if typeDescription.IsNull() || !typeDescription.isVariableLength() || !page.overflows(encoded) {
+		return typeDescription, encoded, nil
+	}
+	pointer, err := page.overflow.write(encoded)
+	if err != nil {
+		return TypeTombstone, nil, err
+	}
+	return typeDescription.withOverflow(), pointer.encode(), nil
+}
+
+// TryMutateStored behaves like TryMutateEncoded for an image returned by TryGetStored or TryStore, except that it
+// neither writes nor frees an overflow chain: the chain of the image must already be written, and the chain of the
+// replaced value is kept. This lets a log record hold the pointer to the chain of a large value, and lets the
+// recovery apply the record to a stale page without freeing the chain that the page points to a second time.
+func (page *Page) TryMutateStored(index int, typeDescription TypeDescription, stored []byte) (bool, error) {
+	return page.tryMutateImage(index, typeDescription, stored, false)
+}
+
+// HasCapacityForStored returns whether TryMutateStored would set the field at the index to the image, without
+// modifying the page: the image is applied to a copy of the page.
+func (page *Page) HasCapacityForStored(index int, typeDescription TypeDescription, stored []byte) (bool, error) {
+	scratch := NewPage(uint(len(page.buffer)))
+	copy(scratch.buffer, page.buffer)
+	page.finishInto(scratch.buffer)
+	if err := scratch.DecodeFrom(scratch.buffer); err != nil {
+		return false, err
+	}
+	return scratch.TryMutateStored(index, typeDescription, stored)
+}
+
+// tryMutateImage sets the field at the index to an image returned by TryGetEncoded, or by TryGetStored and TryStore
+// if the page does not manage the overflow chains.
+func (page *Page) tryMutateImage(index int, typeDescription TypeDescription, image []byte, managesOverflowChains bool) (bool, error) {
+	if index == page.NumberOfFields() {
+		return page.addEncoded(typeDescription, image, managesOverflowChains), nil
+	}
+	if !typeDescription.Equals(TypeTombstone) && page.checkIndexInBounds(index) == nil && page.IsDeleted(index) {
+		page.startingOffsets.SetOffsetAtIndex(index, 0)
+		page.types.SetTypeAt(index, typeDescription.WithoutOverflow().WithNull())
+	}
+	switch {
+	case typeDescription.Equals(TypeTombstone):
+		if err := page.checkIndexInBounds(index); err != nil {
+			return false, err
+		}
+		page.delete(index, managesOverflowChains)
+		return true, nil
+	case typeDescription.IsNull():
+		if err := page.checkFieldAt(index, typeDescription.WithoutNull()); err != nil {
+			return false, err
+		}
+		page.setNull(index, managesOverflowChains)
+		return true, nil
+	case typeDescription.isVariableLength() && managesOverflowChains:
+		return page.mutateVariableLengthField(index, typeDescription, image)
+	case typeDescription.isVariableLength():
+		if err := page.checkFieldAt(index, typeDescription.WithoutOverflow()); err != nil {
+			return false, err
+		}
+		return page.replaceVariableLengthValue(index, typeDescription, image), nil
+	}
+	return page.mutateField(index, typeDescription, uint(len(image)), func(destinationOffset uint) gorel.BytesNeededForEncoding {
+		return uint(copy(page.buffer[destinationOffset:], image))
 	})
 }
 
 func (page *Page) finish() {
-	resultingBuffer := page.buffer
+	page.finishInto(page.buffer)
+}
 
+// finishInto writes the trailer of the page at the end of the buffer, which is the buffer of the page or a copy of it.
+func (page *Page) finishInto(resultingBuffer []byte) {
 	encodedStartingOffsets := page.startingOffsets.Encode()
 	encodedTypeDescription := page.types.Encode()
 
@@ -155,7 +654,26 @@ func (page *Page) finish() {
 	offsetToWriteTypeDescription := offsetToWriteTheEncodedStartingOffsets - page.types.SizeUsedInBytes()
 	copy(resultingBuffer[offsetToWriteTypeDescription:], encodedTypeDescription)
 
-	binary.LittleEndian.PutUint16(resultingBuffer[len(resultingBuffer)-reservedSizeForNumberOfOffsets:], uint16(page.startingOffsets.Length()))
+	binary.LittleEndian.PutUint32(resultingBuffer[len(resultingBuffer)-reservedSizeForNumberOfOffsets:], uint32(page.startingOffsets.Length()))
+}
+
+// FreeSpace returns the number of bytes that are neither used by the fields nor by the trailer
+// (types, starting offsets and the number of offsets) that finish writes at the end of the page.
+func (page *Page) FreeSpace() int {
+	page.ensureLayout()
+	freeSpace := len(page.buffer) -
+		int(page.currentWriteOffset) -
+		page.types.SizeUsedInBytes() -
+		page.startingOffsets.SizeUsedInBytes() -
+		reservedSizeForNumberOfOffsets
+
+	return max(freeSpace, 0)
+}
+
+// FragmentedSpace returns the number of bytes in the holes between the fields, which Compact reclaims.
+func (page *Page) FragmentedSpace() int {
+	page.ensureLayout()
+	return int(page.freeSpaceMap.size())
 }
 
 func (page *Page) Content() []byte {
@@ -163,82 +681,710 @@ func (page *Page) Content() []byte {
 }
 
 func (page *Page) GetUint8(index int) uint8 {
-	page.assertFieldAt(index, TypeUint8)
-	decoded, _ := gorel.DecodeUint8(page.buffer, page.startingOffsets.OffsetAtIndex(index))
-	return decoded
+	return must(page.TryGetUint8(index))
+}
+
+// TryGetUint8 returns ErrIndexOutOfBounds, ErrTypeMismatch or ErrNullField where GetUint8 panics; the same holds
+// for all the TryGet methods.
+func (page *Page) TryGetUint8(index int) (value uint8, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeUint8); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeUint8(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
 }
 
 func (page *Page) GetUint16(index int) uint16 {
-	page.assertFieldAt(index, TypeUint16)
-	decoded, _ := gorel.DecodeUint16(page.buffer, page.startingOffsets.OffsetAtIndex(index))
-	return decoded
+	return must(page.TryGetUint16(index))
+}
+
+func (page *Page) TryGetUint16(index int) (value uint16, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeUint16); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeUint16(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
 }
 
 func (page *Page) GetUint32(index int) uint32 {
-	page.assertFieldAt(index, TypeUint32)
-	decoded, _ := gorel.DecodeUint32(page.buffer, page.startingOffsets.OffsetAtIndex(index))
-	return decoded
+	return must(page.TryGetUint32(index))
+}
+
+func (page *Page) TryGetUint32(index int) (value uint32, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeUint32); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeUint32(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
 }
 
 func (page *Page) GetUint64(index int) uint64 {
-	page.assertFieldAt(index, TypeUint64)
-	decoded, _ := gorel.DecodeUint64(page.buffer, page.startingOffsets.OffsetAtIndex(index))
-	return decoded
+	return must(page.TryGetUint64(index))
+}
+
+func (page *Page) TryGetUint64(index int) (value uint64, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeUint64); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeUint64(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
 }
 
+// GetString returns the value, reassembling it from its overflow chain if the value is stored out of line.
 func (page *Page) GetString(index int) string {
-	page.assertFieldAt(index, TypeString)
-	decoded, _ := gorel.DecodeByteSlice(page.buffer, page.startingOffsets.OffsetAtIndex(index))
-	return string(decoded)
+	return must(page.TryGetString(index))
+}
+
+// TryGetString behaves like GetString, and also returns the error if the overflow chain cannot be read.
+func (page *Page) TryGetString(index int) (string, error) {
+	if err := page.checkNonNullFieldAt(index, TypeString); err != nil {
+		return "", err
+	}
+	value, err := page.variableLengthValueAt(index)
+	return string(value), err
 }
 
+// GetBytes behaves like GetString. A value which is stored in the page is returned without copying it, so it is
+// valid only as long as the buffer of the page is pinned and the field is not modified.
 func (page *Page) GetBytes(index int) []byte {
-	page.assertFieldAt(index, TypeByteSlice)
-	decoded, _ := gorel.DecodeByteSlice(page.buffer, page.startingOffsets.OffsetAtIndex(index))
-	return decoded
+	return must(page.TryGetBytes(index))
+}
+
+func (page *Page) TryGetBytes(index int) ([]byte, error) {
+	if err := page.checkNonNullFieldAt(index, TypeByteSlice); err != nil {
+		return nil, err
+	}
+	return page.variableLengthValueAt(index)
+}
+
+func (page *Page) GetInt8(index int) int8 {
+	return must(page.TryGetInt8(index))
+}
+
+func (page *Page) TryGetInt8(index int) (value int8, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeInt8); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeInt8(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetInt16(index int) int16 {
+	return must(page.TryGetInt16(index))
+}
+
+func (page *Page) TryGetInt16(index int) (value int16, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeInt16); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeInt16(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetInt32(index int) int32 {
+	return must(page.TryGetInt32(index))
+}
+
+func (page *Page) TryGetInt32(index int) (value int32, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeInt32); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeInt32(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetInt64(index int) int64 {
+	return must(page.TryGetInt64(index))
+}
+
+func (page *Page) TryGetInt64(index int) (value int64, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeInt64); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeInt64(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetFloat32(index int) float32 {
+	return must(page.TryGetFloat32(index))
+}
+
+func (page *Page) TryGetFloat32(index int) (value float32, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeFloat32); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeFloat32(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetFloat64(index int) float64 {
+	return must(page.TryGetFloat64(index))
+}
+
+func (page *Page) TryGetFloat64(index int) (value float64, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeFloat64); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeFloat64(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetBool(index int) bool {
+	return must(page.TryGetBool(index))
+}
+
+func (page *Page) TryGetBool(index int) (value bool, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeBool); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeBool(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetDate(index int) time.Time {
+	return must(page.TryGetDate(index))
+}
+
+func (page *Page) TryGetDate(index int) (value time.Time, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeDate); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeDate(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetTimestamp(index int) time.Time {
+	return must(page.TryGetTimestamp(index))
+}
+
+func (page *Page) TryGetTimestamp(index int) (value time.Time, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeTimestamp); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeTimestamp(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetDecimal(index int) gorel.Decimal {
+	return must(page.TryGetDecimal(index))
+}
+
+func (page *Page) TryGetDecimal(index int) (value gorel.Decimal, err error) {
+	if err = page.checkNonNullFieldAt(index, TypeDecimal); err != nil {
+		return value, err
+	}
+	value, _ = gorel.DecodeDecimal(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return value, nil
+}
+
+func (page *Page) GetNullableUint8(index int) (value uint8, ok bool) {
+	if page.isNullFieldOf(index, TypeUint8) {
+		return value, false
+	}
+	return page.GetUint8(index), true
+}
+
+func (page *Page) GetNullableUint16(index int) (value uint16, ok bool) {
+	if page.isNullFieldOf(index, TypeUint16) {
+		return value, false
+	}
+	return page.GetUint16(index), true
+}
+
+func (page *Page) GetNullableUint32(index int) (value uint32, ok bool) {
+	if page.isNullFieldOf(index, TypeUint32) {
+		return value, false
+	}
+	return page.GetUint32(index), true
+}
+
+func (page *Page) GetNullableUint64(index int) (value uint64, ok bool) {
+	if page.isNullFieldOf(index, TypeUint64) {
+		return value, false
+	}
+	return page.GetUint64(index), true
+}
+
+func (page *Page) GetNullableString(index int) (value string, ok bool) {
+	if page.isNullFieldOf(index, TypeString) {
+		return value, false
+	}
+	return page.GetString(index), true
+}
+
+func (page *Page) GetNullableBytes(index int) (value []byte, ok bool) {
+	if page.isNullFieldOf(index, TypeByteSlice) {
+		return value, false
+	}
+	return page.GetBytes(index), true
+}
+
+func (page *Page) GetNullableInt8(index int) (value int8, ok bool) {
+	if page.isNullFieldOf(index, TypeInt8) {
+		return value, false
+	}
+	return page.GetInt8(index), true
+}
+
+func (page *Page) GetNullableInt16(index int) (value int16, ok bool) {
+	if page.isNullFieldOf(index, TypeInt16) {
+		return value, false
+	}
+	return page.GetInt16(index), true
+}
+
+func (page *Page) GetNullableInt32(index int) (value int32, ok bool) {
+	if page.isNullFieldOf(index, TypeInt32) {
+		return value, false
+	}
+	return page.GetInt32(index), true
+}
+
+func (page *Page) GetNullableInt64(index int) (value int64, ok bool) {
+	if page.isNullFieldOf(index, TypeInt64) {
+		return value, false
+	}
+	return page.GetInt64(index), true
+}
+
+func (page *Page) GetNullableFloat32(index int) (value float32, ok bool) {
+	if page.isNullFieldOf(index, TypeFloat32) {
+		return value, false
+	}
+	return page.GetFloat32(index), true
+}
+
+func (page *Page) GetNullableFloat64(index int) (value float64, ok bool) {
+	if page.isNullFieldOf(index, TypeFloat64) {
+		return value, false
+	}
+	return page.GetFloat64(index), true
+}
+
+func (page *Page) GetNullableBool(index int) (value bool, ok bool) {
+	if page.isNullFieldOf(index, TypeBool) {
+		return value, false
+	}
+	return page.GetBool(index), true
+}
+
+func (page *Page) GetNullableDate(index int) (value time.Time, ok bool) {
+	if page.isNullFieldOf(index, TypeDate) {
+		return value, false
+	}
+	return page.GetDate(index), true
+}
+
+func (page *Page) GetNullableTimestamp(index int) (value time.Time, ok bool) {
+	if page.isNullFieldOf(index, TypeTimestamp) {
+		return value, false
+	}
+	return page.GetTimestamp(index), true
+}
+
+func (page *Page) GetNullableDecimal(index int) (value gorel.Decimal, ok bool) {
+	if page.isNullFieldOf(index, TypeDecimal) {
+		return value, false
+	}
+	return page.GetDecimal(index), true
+}
+
+// releaseBuffer returns the buffer to the pool; the page must not be used after its buffer is released.
+func (page *Page) releaseBuffer() {
+	page.recycle(nil)
+	page.buffer = nil
+}
+
+// recycle returns the buffer of the page to the pool, unless the page is about to take over the same buffer.
+func (page *Page) recycle(nextBuffer []byte) {
+	if page.bufferPool == nil || len(page.buffer) == 0 {
+		return
+	}
+	if len(nextBuffer) > 0 && &nextBuffer[0] == &page.buffer[0] {
+		return
+	}
+	page.bufferPool.Put(page.buffer)
+}
+
+func (page *Page) reset() {
+	clear(page.buffer)
+	page.header().Format(file.PageTypeData)
+	page.startingOffsets = file.NewStartingOffsets()
+	page.types = NewTypes()
+	page.freeSpaceMap = newFreeSpaceMap()
+	page.currentWriteOffset = file.PageHeaderSize
+}
+
+func (page *Page) header() file.PageHeader {
+	return file.PageHeaderOf(page.buffer)
 }
 
 func (page *Page) assertIndexInBounds(index int) {
-	gorel.Assert(
-		index < page.startingOffsets.Length(),
-		"index out of bounds, index = %d, available startingOffsets = %d",
-		index,
-		page.startingOffsets.Length(),
+	page.assertNoError(page.checkIndexInBounds(index))
+}
+
+func (page *Page) checkIndexInBounds(index int) error {
+	if index < 0 || index >= page.startingOffsets.Length() {
+		return fmt.Errorf(
+			"%w, index = %d, available startingOffsets = %d",
+			ErrIndexOutOfBounds,
+			index,
+			page.startingOffsets.Length(),
+		)
+	}
+	return nil
+}
+
+func (page *Page) checkTypeDescriptionMatch(expectedTypeDescription, actualTypeDescription TypeDescription) error {
+	if !expectedTypeDescription.Equals(actualTypeDescription) {
+		return fmt.Errorf(
+			"%w, expected type %s actual type %s",
+			ErrTypeMismatch,
+			expectedTypeDescription.AsString(),
+			actualTypeDescription.AsString(),
+		)
+	}
+	return nil
+}
+
+func (page *Page) addField(
+	bytesNeeded gorel.BytesNeededForEncoding,
+	encodeFn func(destinationOffset uint) gorel.BytesNeededForEncoding,
+	typeDescription TypeDescription,
+) bool {
+	bytesNeededForTrailer := uint(page.startingOffsets.SizeInBytesForAnOffset() + ReservedSizeForAType)
+	if bytesNeeded+bytesNeededForTrailer > uint(page.FreeSpace()+page.FragmentedSpace()) || bytesNeededForTrailer > uint(page.FreeSpace()) {
+		return false
+	}
+	destinationOffset := page.allocate(bytesNeeded, bytesNeededForTrailer, -1)
+	encodeFn(destinationOffset)
+	page.startingOffsets.Append(uint32(destinationOffset))
+	page.types.AddTypeDescription(typeDescription)
+	return true
+}
+
+// mutateField encodes the value of a fixed size type in place, or allocates the bytes for it if the field is NULL.
+func (page *Page) mutateField(
+	index int,
+	typeDescription TypeDescription,
+	bytesNeeded gorel.BytesNeededForEncoding,
+	encodeFn func(destinationOffset uint) gorel.BytesNeededForEncoding,
+) (bool, error) {
+	if err := page.checkFieldAt(index, typeDescription); err != nil {
+		return false, err
+	}
+	if !page.types.GetTypeAt(index).IsNull() {
+		encodeFn(uint(page.startingOffsets.OffsetAtIndex(index)))
+		return true, nil
+	}
+	if bytesNeeded > uint(page.FreeSpace()+page.FragmentedSpace()) {
+		return false, nil
+	}
+	destinationOffset := page.allocate(bytesNeeded, 0, index)
+	encodeFn(destinationOffset)
+	page.startingOffsets.SetOffsetAtIndex(index, uint32(destinationOffset))
+	page.types.SetTypeAt(index, typeDescription)
+	return true, nil
+}
+
+func (page *Page) addEncoded(typeDescription TypeDescription, encoded []byte, managesOverflowChains bool) bool {
+	switch {
+	case typeDescription.Equals(TypeTombstone):
+		return page.addField(0, func(uint) gorel.BytesNeededForEncoding { return 0 }, TypeTombstone)
+	case typeDescription.IsNull():
+		return page.AddNull(typeDescription.WithoutNull())
+	case typeDescription.isVariableLength() && managesOverflowChains:
+		return page.addVariableLengthField(typeDescription, encoded)
+	case typeDescription.isVariableLength():
+		return page.addField(
+			gorel.BytesNeededForEncodingAByteSlice(encoded),
+			func(destinationOffset uint) gorel.BytesNeededForEncoding {
+				return gorel.EncodeByteSlice(encoded, page.buffer, destinationOffset)
+			},
+			typeDescription,
+		)
+	}
+	return page.addField(
+		uint(len(encoded)),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return uint(copy(page.buffer[destinationOffset:], encoded))
+		},
+		typeDescription,
 	)
 }
 
-func (page *Page) assertTypeDescriptionMatch(expectedTypeDescription, actualTypeDescription TypeDescription) {
-	gorel.Assert(
-		expectedTypeDescription.Equals(actualTypeDescription),
-		"type description mismatch, expected type %s actual type %s",
-		expectedTypeDescription.AsString(),
-		actualTypeDescription.AsString(),
+func (page *Page) addVariableLengthField(typeDescription TypeDescription, value []byte) bool {
+	if !page.overflows(value) {
+		return page.addField(
+			gorel.BytesNeededForEncodingAByteSlice(value),
+			func(destinationOffset uint) gorel.BytesNeededForEncoding {
+				return gorel.EncodeByteSlice(value, page.buffer, destinationOffset)
+			},
+			typeDescription,
+		)
+	}
+	pointer, err := page.overflow.write(value)
+	if err != nil {
+		return false
+	}
+	encodedPointer := pointer.encode()
+	added := page.addField(
+		gorel.BytesNeededForEncodingAByteSlice(encodedPointer),
+		func(destinationOffset uint) gorel.BytesNeededForEncoding {
+			return gorel.EncodeByteSlice(encodedPointer, page.buffer, destinationOffset)
+		},
+		typeDescription.withOverflow(),
 	)
+	if !added {
+		_ = page.overflow.free(pointer)
+	}
+	return added
 }
 
-func (page *Page) addField(encodeFn func() gorel.BytesNeededForEncoding, typeDescription TypeDescription) {
-	bytesNeededForEncoding := encodeFn()
-	page.startingOffsets.Append(uint16(page.currentWriteOffset))
-	page.types.AddTypeDescription(typeDescription)
-	page.moveCurrentWriteOffsetBy(bytesNeededForEncoding)
+// mutateVariableLengthField writes the chain of a value which overflows before replacing the field, and frees the
+// chain of the existing value only after the field is replaced. A chain which cannot be freed is leaked.
+func (page *Page) mutateVariableLengthField(index int, typeDescription TypeDescription, value []byte) (bool, error) {
+	if err := page.checkFieldAt(index, typeDescription); err != nil {
+		return false, err
+	}
+
+	existingTypeDescription := page.types.GetTypeAt(index)
+	if !page.overflows(value) && !existingTypeDescription.IsOverflow() {
+		return page.replaceVariableLengthValue(index, typeDescription, value), nil
+	}
+	var existingPointer overflowPointer
+	if existingTypeDescription.IsOverflow() {
+		existingPointer = page.overflowPointerAt(index)
+	}
+	if !page.overflows(value) {
+		if !page.replaceVariableLengthValue(index, typeDescription, value) {
+			return false, nil
+		}
+		_ = page.overflow.free(existingPointer)
+		return true, nil
+	}
+	pointer, err := page.overflow.write(value)
+	if err != nil {
+		return false, err
+	}
+	if !page.replaceVariableLengthValue(index, typeDescription.withOverflow(), pointer.encode()) {
+		_ = page.overflow.free(pointer)
+		return false, nil
+	}
+	if existingTypeDescription.IsOverflow() {
+		_ = page.overflow.free(existingPointer)
+	}
+	return true, nil
 }
 
-func (page *Page) mutateField(index int, typeDescription TypeDescription, encodeFn func(destinationOffset uint) gorel.BytesNeededForEncoding) {
-	page.assertFieldAt(index, typeDescription)
-	encodeFn(uint(page.startingOffsets.OffsetAtIndex(index)))
+// replaceVariableLengthValue writes the value of the field in the page and sets the type description of the field,
+// which has the overflowFlag if the value is an encoded overflowPointer.
+func (page *Page) replaceVariableLengthValue(index int, typeDescription TypeDescription, value []byte) bool {
+	page.ensureLayout()
+	startingOffset := uint(page.startingOffsets.OffsetAtIndex(index))
+	existingSize := page.endOffsetOf(index) - startingOffset
+	bytesNeeded := gorel.BytesNeededForEncodingAByteSlice(value)
+
+	switch {
+	case !page.types.GetTypeAt(index).IsNull() && bytesNeeded <= existingSize:
+		gorel.EncodeByteSlice(value, page.buffer, startingOffset)
+		page.release(startingOffset+bytesNeeded, existingSize-bytesNeeded)
+	case !page.types.GetTypeAt(index).IsNull() &&
+		startingOffset+existingSize == page.currentWriteOffset &&
+		bytesNeeded-existingSize <= uint(page.FreeSpace()):
+		gorel.EncodeByteSlice(value, page.buffer, startingOffset)
+		page.currentWriteOffset = startingOffset + bytesNeeded
+	case bytesNeeded > uint(page.FreeSpace()+page.FragmentedSpace())+existingSize:
+		return false
+	default:
+		value = bytes.Clone(value)
+		page.release(startingOffset, existingSize)
+
+		destinationOffset := page.allocate(bytesNeeded, 0, index)
+		gorel.EncodeByteSlice(value, page.buffer, destinationOffset)
+		page.startingOffsets.SetOffsetAtIndex(index, uint32(destinationOffset))
+	}
+	page.types.SetTypeAt(index, typeDescription)
+	return true
 }
 
-func (page *Page) assertFieldAt(index int, typeDescription TypeDescription) {
-	page.assertIndexInBounds(index)
-	page.assertTypeDescriptionMatch(typeDescription, page.types.GetTypeAt(index))
+// allocate returns the offset at which bytesNeeded can be written: the smallest hole that fits, or the end of the
+// written fields. The page, except the field at the excludedIndex, is compacted if bytesNeeded along with the
+// reservedBytes do not fit at the end. The caller ensures that the page has the capacity.
+func (page *Page) allocate(bytesNeeded uint, reservedBytes uint, excludedIndex int) uint {
+	page.ensureLayout()
+	if bytesNeeded == 0 {
+		return 0
+	}
+	if offset, ok := page.freeSpaceMap.allocate(bytesNeeded); ok {
+		return offset
+	}
+	if bytesNeeded+reservedBytes > uint(page.FreeSpace()) {
+		page.compactExcluding(excludedIndex)
+	}
+	offset := page.currentWriteOffset
+	page.moveCurrentWriteOffsetBy(bytesNeeded)
+	return offset
+}
+
+// release adds the bytes to the freeSpaceMap, or moves the currentWriteOffset back if the bytes (along with the
+// adjacent holes) end at the currentWriteOffset.
+func (page *Page) release(offset uint, size uint) {
+	page.ensureLayout()
+	page.freeSpaceMap.release(offset, size)
+	if holeStartingOffset, ok := page.freeSpaceMap.removeHoleEndingAt(page.currentWriteOffset); ok {
+		page.currentWriteOffset = holeStartingOffset
+	}
+}
+
+// compactExcluding moves all the fields, except the tombstones and the one at the excluded index, towards the
+// beginning of the page so that the holes between them are removed. The starting offset of the excluded field is
+// left as is.
+func (page *Page) compactExcluding(excludedIndex int) {
+	page.ensureLayout()
+	indices := make([]int, 0, page.startingOffsets.Length())
+	for index := 0; index < page.startingOffsets.Length(); index++ {
+		if !page.types.GetTypeAt(index).occupiesBytes() {
+			page.startingOffsets.SetOffsetAtIndex(index, 0)
+			continue
+		}
+		if index != excludedIndex {
+			indices = append(indices, index)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return page.startingOffsets.OffsetAtIndex(indices[i]) < page.startingOffsets.OffsetAtIndex(indices[j])
+	})
+
+	writeOffset := uint(file.PageHeaderSize)
+	for _, index := range indices {
+		startingOffset := uint(page.startingOffsets.OffsetAtIndex(index))
+		endOffset := page.endOffsetOf(index)
+		copy(page.buffer[writeOffset:], page.buffer[startingOffset:endOffset])
+		page.startingOffsets.SetOffsetAtIndex(index, uint32(writeOffset))
+		writeOffset += endOffset - startingOffset
+	}
+	page.currentWriteOffset = writeOffset
+	page.freeSpaceMap.reset()
+}
+
+func (page *Page) endOffsetOf(index int) uint {
+	return uint(page.types.GetTypeAt(index).EndOffsetPostDecode(page.buffer, page.startingOffsets.OffsetAtIndex(index)))
+}
+
+// checkFieldAt returns an error if the index is out of bounds, or if the field at the index is not of the type,
+// irrespective of it being NULL or stored in an overflow chain.
+func (page *Page) checkFieldAt(index int, typeDescription TypeDescription) error {
+	if err := page.checkIndexInBounds(index); err != nil {
+		return err
+	}
+	return page.checkTypeDescriptionMatch(typeDescription, page.types.GetTypeAt(index).WithoutNull().WithoutOverflow())
+}
+
+func (page *Page) checkNonNullFieldAt(index int, typeDescription TypeDescription) error {
+	if err := page.checkFieldAt(index, typeDescription); err != nil {
+		return err
+	}
+	if page.types.GetTypeAt(index).IsNull() {
+		return fmt.Errorf("%w, index = %d", ErrNullField, index)
+	}
+	return nil
+}
+
+func (page *Page) isNullFieldOf(index int, typeDescription TypeDescription) bool {
+	page.assertNoError(page.checkFieldAt(index, typeDescription))
+	return page.types.GetTypeAt(index).IsNull()
+}
+
+func (page *Page) assertNoError(err error) {
+	gorel.Assert(err == nil, "%v", err)
+}
+
+// must returns the value of a Try variant of an accessor, and panics if the accessor returns an error.
+func must[T any](value T, err error) T {
+	gorel.Assert(err == nil, "%v", err)
+	return value
+}
+
+// overflows returns true if the page belongs to a buffer, and the encoding of the value takes more than a quarter
+// of the page.
+func (page *Page) overflows(value []byte) bool {
+	return page.overflow != nil && gorel.BytesNeededForEncodingAByteSlice(value) > uint(len(page.buffer))/overflowThresholdDivisor
+}
+
+func (page *Page) variableLengthValueAt(index int) ([]byte, error) {
+	if !page.types.GetTypeAt(index).IsOverflow() {
+		decoded, _ := gorel.DecodeByteSlice(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+		return decoded, nil
+	}
+	value, err := page.overflow.read(page.overflowPointerAt(index))
+	if err != nil {
+		return nil, fmt.Errorf("could not read the overflow chain of the field at index %d: %w", index, err)
+	}
+	return value, nil
+}
+
+func (page *Page) overflowPointerAt(index int) overflowPointer {
+	gorel.Assert(page.overflow != nil, "field at index %d is stored in an overflow chain, but the page does not belong to a buffer", index)
+	decoded, _ := gorel.DecodeByteSlice(page.buffer, page.startingOffsets.OffsetAtIndex(index))
+	return decodeOverflowPointer(decoded)
+}
+
+// freeOverflowChainAt frees the overflow chain of the field at the index, if its value is stored out of line. A
+// chain which cannot be freed is leaked.
+func (page *Page) freeOverflowChainAt(index int) {
+	if page.types.GetTypeAt(index).IsOverflow() {
+		_ = page.overflow.free(page.overflowPointerAt(index))
+	}
 }
 
 func (page *Page) moveCurrentWriteOffsetBy(offset uint) {
 	page.currentWriteOffset += offset
 }
 
+// ensureLayout computes the currentWriteOffset and the freeSpaceMap of a decoded page, before the page is modified or
+// its free space is queried.
+func (page *Page) ensureLayout() {
+	if page.freeSpaceMap == nil {
+		page.updateCurrentWriteOffset()
+		page.rebuildFreeSpaceMap()
+	}
+}
+
+// updateCurrentWriteOffset sets the currentWriteOffset to the largest end offset of all the fields (except the
+// tombstones), given that a field may have been written in a hole or relocated after the field that was added last.
 func (page *Page) updateCurrentWriteOffset() {
-	lastTypeDescription := page.types.GetTypeAt(page.types.Length() - 1)
-	endOffset := lastTypeDescription.EndOffsetPostDecode(page.buffer, page.startingOffsets.OffsetAtIndex(page.startingOffsets.Length()-1))
-	page.currentWriteOffset = uint(endOffset)
+	page.currentWriteOffset = file.PageHeaderSize
+	for index := 0; index < page.startingOffsets.Length(); index++ {
+		if page.types.GetTypeAt(index).occupiesBytes() {
+			page.currentWriteOffset = max(page.currentWriteOffset, page.endOffsetOf(index))
+		}
+	}
+}
+
+// rebuildFreeSpaceMap adds the gaps between the fields (except the tombstones) to a new freeSpaceMap.
+func (page *Page) rebuildFreeSpaceMap() {
+	type field struct {
+		startingOffset uint
+		endOffset      uint
+	}
+	fields := make([]field, 0, page.startingOffsets.Length())
+	for index := 0; index < page.startingOffsets.Length(); index++ {
+		if page.types.GetTypeAt(index).occupiesBytes() {
+			fields = append(fields, field{
+				startingOffset: uint(page.startingOffsets.OffsetAtIndex(index)),
+				endOffset:      page.endOffsetOf(index),
+			})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].startingOffset < fields[j].startingOffset
+	})
+
+	page.freeSpaceMap = newFreeSpaceMap()
+	previousEndOffset := uint(file.PageHeaderSize)
+	for _, field := range fields {
+		page.freeSpaceMap.release(previousEndOffset, field.startingOffset-previousEndOffset)
+		previousEndOffset = field.endOffset
+	}
 }
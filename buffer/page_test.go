@@ -1,8 +1,12 @@
 package buffer
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
+	"gorel"
+	"gorel/file"
 	"testing"
+	"time"
 )
 
 const blockSize = 4096
@@ -187,3 +191,787 @@ func TestAddFewFieldsInPageDecodeThePageAndMutateFieldsInTheDecodedPageToSimulat
 	assert.Equal(t, uint16(160), decodedPage.GetUint16(2))
 	assert.Equal(t, uint64(640), decodedPage.GetUint64(3))
 }
+
+func TestFreeSpaceInAnEmptyPage(t *testing.T) {
+	page := NewPage(blockSize)
+	assert.Equal(t, blockSize-file.PageHeaderSize-reservedSizeForNumberOfOffsets, page.FreeSpace())
+}
+
+func TestFreeSpaceAfterAddingAField(t *testing.T) {
+	page := NewPage(blockSize)
+	assert.True(t, page.AddUint32(32))
+
+	assert.Equal(t, blockSize-file.PageHeaderSize-reservedSizeForNumberOfOffsets-4-4-1, page.FreeSpace())
+}
+
+func TestAttemptToAddAFieldInAPageWithInsufficientSize(t *testing.T) {
+	page := NewPage(26)
+
+	assert.False(t, page.AddUint64(64))
+	assert.False(t, page.AddString("RocksDB"))
+	assert.Equal(t, 6, page.FreeSpace())
+}
+
+func TestFillAPageToExactlyItsLimitWithUnsignedIntegers(t *testing.T) {
+	page := NewPage(42)
+
+	assert.True(t, page.AddUint64(64))
+	assert.Equal(t, 9, page.FreeSpace())
+	assert.True(t, page.AddUint32(32))
+	assert.Equal(t, 0, page.FreeSpace())
+
+	assert.False(t, page.AddUint8(8))
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.Equal(t, uint64(64), decodedPage.GetUint64(0))
+	assert.Equal(t, uint32(32), decodedPage.GetUint32(1))
+	assert.Equal(t, 0, decodedPage.FreeSpace())
+}
+
+func TestFillAPageToExactlyItsLimitWithAString(t *testing.T) {
+	value := "RocksDB is an LSM-based key/value storage engine"
+	page := NewPage(file.PageHeaderSize + uint(len(value)) + 4 + 5 + 4)
+
+	assert.False(t, page.AddString(value+"!"))
+	assert.True(t, page.AddString(value))
+	assert.Equal(t, 0, page.FreeSpace())
+	assert.False(t, page.AddBytes(nil))
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.Equal(t, value, decodedPage.GetString(0))
+}
+
+func TestFillAPageToItsLimitAndKeepAddingFields(t *testing.T) {
+	page := NewPage(blockSize)
+
+	fields := 0
+	for page.AddUint16(uint16(fields)) {
+		fields++
+	}
+	page.finish()
+
+	assert.Equal(t, (blockSize-file.PageHeaderSize-reservedSizeForNumberOfOffsets)/7, fields)
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+	for index := 0; index < fields; index++ {
+		assert.Equal(t, uint16(index), decodedPage.GetUint16(index))
+	}
+}
+
+func TestMutateAStringWithALongerValueInTheMiddleOfThePage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("Bolt")
+	page.AddUint32(32)
+	page.AddString("Pebble")
+
+	assert.True(t, page.MutateString(0, "BoltDB is a B+Tree based storage engine"))
+
+	assert.Equal(t, "BoltDB is a B+Tree based storage engine", page.GetString(0))
+	assert.Equal(t, uint32(32), page.GetUint32(1))
+	assert.Equal(t, "Pebble", page.GetString(2))
+}
+
+func TestMutateAStringWithAShorterValueInTheMiddleOfThePage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("BoltDB is a B+Tree based storage engine")
+	page.AddString("Pebble")
+	freeSpace := page.FreeSpace()
+
+	assert.True(t, page.MutateString(0, "Bolt"))
+
+	assert.Equal(t, "Bolt", page.GetString(0))
+	assert.Equal(t, "Pebble", page.GetString(1))
+	assert.Equal(t, freeSpace, page.FreeSpace())
+}
+
+func TestMutateTheLastWrittenByteSliceWithAShorterAndALongerValue(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint16(16)
+	page.AddBytes([]byte("BoltDB is a B+Tree based storage engine"))
+	freeSpace := page.FreeSpace()
+
+	assert.True(t, page.MutateBytes(1, []byte("Bolt")))
+	assert.Equal(t, freeSpace+len("DB is a B+Tree based storage engine"), page.FreeSpace())
+
+	assert.True(t, page.MutateBytes(1, []byte("BoltDB")))
+	assert.Equal(t, []byte("BoltDB"), page.GetBytes(1))
+	assert.Equal(t, uint16(16), page.GetUint16(0))
+}
+
+func TestMutateAStringWhichNeedsCompaction(t *testing.T) {
+	page := NewPage(58)
+	assert.True(t, page.AddString("abcdef"))
+	assert.True(t, page.AddString("xy"))
+	assert.True(t, page.AddUint8(8))
+	assert.Equal(t, 6, page.FreeSpace())
+
+	assert.True(t, page.MutateString(0, "a"))
+	assert.Equal(t, 6, page.FreeSpace())
+
+	assert.True(t, page.MutateString(1, "uvwxyz1234"))
+	assert.Equal(t, "a", page.GetString(0))
+	assert.Equal(t, "uvwxyz1234", page.GetString(1))
+	assert.Equal(t, uint8(8), page.GetUint8(2))
+	assert.Equal(t, 3, page.FreeSpace())
+}
+
+func TestAttemptToMutateAStringInAFullPage(t *testing.T) {
+	page := NewPage(58)
+	assert.True(t, page.AddString("abcdef"))
+	assert.True(t, page.AddString("xy"))
+	assert.True(t, page.AddUint8(8))
+
+	assert.False(t, page.MutateString(1, "uvwxyz1234"))
+	assert.Equal(t, "abcdef", page.GetString(0))
+	assert.Equal(t, "xy", page.GetString(1))
+	assert.Equal(t, uint8(8), page.GetUint8(2))
+	assert.Equal(t, 6, page.FreeSpace())
+}
+
+func TestMutateAStringToRelocateItDecodeThePageAndAddAField(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("Bolt")
+	page.AddString("Pebble")
+	assert.True(t, page.MutateString(0, "BoltDB is a B+Tree based storage engine"))
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+	decodedPage.AddString("RocksDB")
+
+	assert.Equal(t, "BoltDB is a B+Tree based storage engine", decodedPage.GetString(0))
+	assert.Equal(t, "Pebble", decodedPage.GetString(1))
+	assert.Equal(t, "RocksDB", decodedPage.GetString(2))
+}
+
+func TestDeleteAFieldKeepsTheIndicesOfOtherFields(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("BoltDB")
+	page.AddUint32(32)
+	page.AddString("Pebble")
+
+	page.Delete(1)
+
+	assert.True(t, page.IsDeleted(1))
+	assert.False(t, page.IsDeleted(0))
+	assert.Equal(t, "BoltDB", page.GetString(0))
+	assert.Equal(t, "Pebble", page.GetString(2))
+	assert.Equal(t, 4, page.FragmentedSpace())
+	assert.Panics(t, func() {
+		page.GetUint32(1)
+	})
+}
+
+func TestDeleteTheLastWrittenField(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	freeSpace := page.FreeSpace()
+	page.AddUint64(64)
+
+	page.Delete(1)
+	page.Delete(1)
+
+	assert.Equal(t, 0, page.FragmentedSpace())
+	assert.Equal(t, freeSpace-4-1, page.FreeSpace())
+}
+
+func TestAddAFieldInTheHoleOfADeletedField(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint64(64)
+	page.AddUint16(16)
+	page.Delete(0)
+	freeSpace := page.FreeSpace()
+
+	assert.True(t, page.AddUint32(32))
+
+	assert.Equal(t, freeSpace-4-1, page.FreeSpace())
+	assert.Equal(t, 4, page.FragmentedSpace())
+	assert.Equal(t, uint32(32), page.GetUint32(2))
+	assert.Equal(t, uint16(16), page.GetUint16(1))
+}
+
+func TestCompactAPage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("BoltDB")
+	page.AddUint64(64)
+	page.AddString("Pebble")
+	page.AddUint16(16)
+	page.Delete(0)
+	page.Delete(2)
+	freeSpace := page.FreeSpace()
+
+	page.Compact()
+
+	assert.Equal(t, 0, page.FragmentedSpace())
+	assert.Equal(t, freeSpace+10+10, page.FreeSpace())
+	assert.Equal(t, uint64(64), page.GetUint64(1))
+	assert.Equal(t, uint16(16), page.GetUint16(3))
+	assert.True(t, page.IsDeleted(0))
+	assert.True(t, page.IsDeleted(2))
+}
+
+func TestAddAFieldWhichNeedsCompaction(t *testing.T) {
+	page := NewPage(58)
+	assert.True(t, page.AddString("abcdef"))
+	assert.True(t, page.AddUint8(8))
+	assert.True(t, page.AddString("uv"))
+	page.Delete(0)
+	page.Delete(2)
+
+	assert.Equal(t, 10, page.FragmentedSpace())
+	assert.True(t, page.AddString("abcdefghi"))
+
+	assert.Equal(t, uint8(8), page.GetUint8(1))
+	assert.Equal(t, "abcdefghi", page.GetString(3))
+	assert.Equal(t, 0, page.FragmentedSpace())
+}
+
+func TestDeleteFieldsDecodeThePageAndReuseTheHoles(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("BoltDB")
+	page.AddUint64(64)
+	page.AddString("Pebble")
+	page.Delete(0)
+	page.Delete(2)
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.True(t, decodedPage.IsDeleted(0))
+	assert.True(t, decodedPage.IsDeleted(2))
+	assert.Equal(t, 10, decodedPage.FragmentedSpace())
+	assert.Equal(t, uint(file.PageHeaderSize+18), decodedPage.currentWriteOffset)
+
+	assert.True(t, decodedPage.AddString("Rocks"))
+	assert.Equal(t, "Rocks", decodedPage.GetString(3))
+	assert.Equal(t, uint64(64), decodedPage.GetUint64(1))
+	assert.Equal(t, 1, decodedPage.FragmentedSpace())
+}
+
+func TestCreateAPageWithSignedIntegersFloatsAndBools(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddInt8(-8)
+	page.AddInt16(-16)
+	page.AddInt32(-32)
+	page.AddInt64(-64)
+	page.AddFloat32(3.5)
+	page.AddFloat64(-6.25)
+	page.AddBool(true)
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.Equal(t, int8(-8), decodedPage.GetInt8(0))
+	assert.Equal(t, int16(-16), decodedPage.GetInt16(1))
+	assert.Equal(t, int32(-32), decodedPage.GetInt32(2))
+	assert.Equal(t, int64(-64), decodedPage.GetInt64(3))
+	assert.Equal(t, float32(3.5), decodedPage.GetFloat32(4))
+	assert.Equal(t, -6.25, decodedPage.GetFloat64(5))
+	assert.True(t, decodedPage.GetBool(6))
+}
+
+func TestCreateAPageWithDatesTimestampsAndDecimals(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		newYork = time.FixedZone("EST", -5*60*60)
+	}
+	timestamp := time.Date(2024, time.January, 5, 10, 30, 0, 0, newYork)
+
+	page := NewPage(blockSize)
+	page.AddDate(time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC))
+	page.AddTimestamp(timestamp)
+	page.AddDecimal(gorel.NewDecimal(99995, 2))
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.Equal(t, time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC), decodedPage.GetDate(0))
+	assert.True(t, timestamp.Equal(decodedPage.GetTimestamp(1)))
+	assert.Equal(t, 10, decodedPage.GetTimestamp(1).Hour())
+	assert.Equal(t, "999.95", decodedPage.GetDecimal(2).String())
+}
+
+func TestMutateSignedIntegersFloatsBoolsAndDecimalsInPage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddInt32(-32)
+	page.AddFloat64(6.25)
+	page.AddBool(false)
+	page.AddDecimal(gorel.NewDecimal(100, 1))
+
+	page.MutateInt32(0, 320)
+	page.MutateFloat64(1, -0.5)
+	page.MutateBool(2, true)
+	page.MutateDecimal(3, gorel.NewDecimal(-1, 3))
+
+	assert.Equal(t, int32(320), page.GetInt32(0))
+	assert.Equal(t, -0.5, page.GetFloat64(1))
+	assert.True(t, page.GetBool(2))
+	assert.Equal(t, gorel.NewDecimal(-1, 3), page.GetDecimal(3))
+}
+
+func TestAttemptToGetASignedIntegerAsAnUnsignedInteger(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddInt16(-16)
+
+	assert.Panics(t, func() {
+		page.GetUint16(0)
+	})
+}
+
+func TestAddNullFieldsInPage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	freeSpace := page.FreeSpace()
+	assert.True(t, page.AddNull(TypeString))
+	assert.True(t, page.AddNull(TypeUint64))
+
+	assert.Equal(t, freeSpace-2*5, page.FreeSpace())
+	assert.False(t, page.IsNull(0))
+	assert.True(t, page.IsNull(1))
+	assert.True(t, page.IsNull(2))
+}
+
+func TestGetNullableFields(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddNull(TypeUint32)
+	page.AddNull(TypeString)
+
+	value, ok := page.GetNullableUint32(0)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(32), value)
+
+	_, ok = page.GetNullableUint32(1)
+	assert.False(t, ok)
+
+	str, ok := page.GetNullableString(2)
+	assert.False(t, ok)
+	assert.Equal(t, "", str)
+}
+
+func TestAttemptToGetANullField(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddNull(TypeUint32)
+
+	assert.Panics(t, func() {
+		page.GetUint32(0)
+	})
+	assert.Panics(t, func() {
+		page.GetNullableUint64(0)
+	})
+}
+
+func TestSetAFieldToNull(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("BoltDB")
+	page.AddUint16(16)
+
+	page.SetNull(0)
+	page.SetNull(0)
+
+	assert.True(t, page.IsNull(0))
+	assert.Equal(t, 10, page.FragmentedSpace())
+	assert.Equal(t, uint16(16), page.GetUint16(1))
+}
+
+func TestMutateANullField(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddNull(TypeUint64)
+	page.AddNull(TypeString)
+	page.AddUint16(16)
+
+	assert.True(t, page.MutateUint64(0, 64))
+	assert.True(t, page.MutateString(1, "PebbleDB"))
+
+	assert.False(t, page.IsNull(0))
+	assert.False(t, page.IsNull(1))
+	assert.Equal(t, uint64(64), page.GetUint64(0))
+	assert.Equal(t, "PebbleDB", page.GetString(1))
+	assert.Equal(t, uint16(16), page.GetUint16(2))
+}
+
+func TestAttemptToMutateANullFieldInAFullPage(t *testing.T) {
+	page := NewPage(32)
+	assert.True(t, page.AddNull(TypeUint64))
+	assert.True(t, page.AddNull(TypeString))
+	assert.Equal(t, 2, page.FreeSpace())
+
+	assert.False(t, page.MutateUint64(0, 64))
+	assert.False(t, page.MutateString(1, "Bolt"))
+	assert.True(t, page.IsNull(0))
+	assert.True(t, page.IsNull(1))
+}
+
+func TestSetNullAndMutateDecodeThePageToSimulateLoadingPageFromDisk(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddString("BoltDB")
+	page.AddNull(TypeInt32)
+	page.AddUint16(16)
+	page.SetNull(0)
+	page.MutateInt32(1, -32)
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.True(t, decodedPage.IsNull(0))
+	assert.Equal(t, int32(-32), decodedPage.GetInt32(1))
+	assert.Equal(t, uint16(16), decodedPage.GetUint16(2))
+
+	assert.True(t, decodedPage.MutateString(0, "Rocks"))
+	assert.Equal(t, "Rocks", decodedPage.GetString(0))
+	assert.Equal(t, int32(-32), decodedPage.GetInt32(1))
+}
+
+func TestAddFieldsBeyondTheFirst64KiBOfALargePageAndDecodeIt(t *testing.T) {
+	page := NewPage(1 << 20)
+	largeValue := bytes.Repeat([]byte("RocksDB"), 20_000)
+
+	assert.True(t, page.AddBytes(largeValue))
+	assert.True(t, page.AddUint64(64))
+	assert.True(t, page.AddString("BoltDB"))
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.Equal(t, largeValue, decodedPage.GetBytes(0))
+	assert.Equal(t, uint64(64), decodedPage.GetUint64(1))
+	assert.Equal(t, "BoltDB", decodedPage.GetString(2))
+}
+
+func TestTryGetFields(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddString("PebbleDB")
+	page.AddDecimal(gorel.NewDecimal(1250, 2))
+
+	value, err := page.TryGetUint32(0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(32), value)
+
+	str, err := page.TryGetString(1)
+	assert.Nil(t, err)
+	assert.Equal(t, "PebbleDB", str)
+
+	decimal, err := page.TryGetDecimal(2)
+	assert.Nil(t, err)
+	assert.Equal(t, gorel.NewDecimal(1250, 2), decimal)
+}
+
+func TestTryGetAFieldWithAnIndexOutOfBounds(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+
+	_, err := page.TryGetUint32(1)
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+
+	_, err = page.TryGetUint32(-1)
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+}
+
+func TestTryGetAFieldWithATypeMismatch(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+
+	_, err := page.TryGetString(0)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.Equal(t, "type description mismatch, expected type string actual type uint32", err.Error())
+}
+
+func TestTryGetANullField(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddNull(TypeInt64)
+
+	_, err := page.TryGetInt64(0)
+	assert.ErrorIs(t, err, ErrNullField)
+}
+
+func TestTryMutateFields(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddString("Bolt")
+
+	mutated, err := page.TryMutateUint32(0, 320)
+	assert.Nil(t, err)
+	assert.True(t, mutated)
+
+	mutated, err = page.TryMutateString(1, "BoltDB")
+	assert.Nil(t, err)
+	assert.True(t, mutated)
+
+	_, err = page.TryMutateString(0, "RocksDB")
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	_, err = page.TryMutateInt8(2, 8)
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+
+	assert.Equal(t, uint32(320), page.GetUint32(0))
+	assert.Equal(t, "BoltDB", page.GetString(1))
+}
+
+func TestTryMutateAFieldInAFullPage(t *testing.T) {
+	page := NewPage(32)
+	page.AddNull(TypeUint64)
+
+	mutated, err := page.TryMutateUint64(0, 64)
+	assert.Nil(t, err)
+	assert.False(t, mutated)
+}
+
+func TestDecodeThePageLazily(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddString("PebbleDB")
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+
+	assert.Equal(t, uint32(32), decodedPage.GetUint32(0))
+	assert.Equal(t, "PebbleDB", decodedPage.GetString(1))
+	assert.Nil(t, decodedPage.freeSpaceMap)
+	assert.Equal(t, page.FreeSpace(), decodedPage.FreeSpace())
+	assert.NotNil(t, decodedPage.freeSpaceMap)
+}
+
+func TestModifyALazilyDecodedPageAndDecodeItAgain(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddString("Bolt")
+	page.AddUint8(8)
+	page.finish()
+
+	decodedPage := &Page{}
+	decodedPage.DecodeFrom(page.buffer)
+	assert.True(t, decodedPage.MutateString(1, "BoltDB is a B+Tree based storage engine"))
+	assert.True(t, decodedPage.AddInt16(-16))
+	decodedPage.finish()
+
+	reDecodedPage := &Page{}
+	reDecodedPage.DecodeFrom(decodedPage.buffer)
+
+	assert.Equal(t, uint32(32), reDecodedPage.GetUint32(0))
+	assert.Equal(t, "BoltDB is a B+Tree based storage engine", reDecodedPage.GetString(1))
+	assert.Equal(t, uint8(8), reDecodedPage.GetUint8(2))
+	assert.Equal(t, int16(-16), reDecodedPage.GetInt16(3))
+}
+
+func TestAPooledPageReturnsItsPreviousBufferToThePool(t *testing.T) {
+	bufferPool := file.NewPageBufferPool(blockSize)
+	page := newPooledPage(bufferPool)
+	assert.True(t, page.AddUint64(64))
+	page.finish()
+
+	otherPage := NewPage(blockSize)
+	otherPage.AddString("RocksDB")
+	otherPage.finish()
+
+	previousBuffer := page.buffer
+	page.DecodeFrom(otherPage.buffer)
+	assert.Equal(t, "RocksDB", page.GetString(0))
+
+	page.DecodeFrom(page.buffer)
+	assert.Equal(t, "RocksDB", page.GetString(0))
+
+	page.releaseBuffer()
+	assert.Nil(t, page.buffer)
+	assert.Equal(t, blockSize, len(previousBuffer))
+}
+
+func BenchmarkDecodeAPageAndReadAField(b *testing.B) {
+	page := NewPage(blockSize)
+	for page.AddUint32(32) {
+	}
+	page.finish()
+
+	decodedPage := &Page{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for iteration := 0; iteration < b.N; iteration++ {
+		decodedPage.DecodeFrom(page.buffer)
+		_ = decodedPage.GetUint32(iteration % decodedPage.startingOffsets.Length())
+	}
+}
+
+func BenchmarkDecodeAPageAndScanAllTheFields(b *testing.B) {
+	page := NewPage(blockSize)
+	for page.AddUint32(32) {
+	}
+	page.finish()
+
+	decodedPage := &Page{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for iteration := 0; iteration < b.N; iteration++ {
+		decodedPage.DecodeFrom(page.buffer)
+		for index := 0; index < decodedPage.startingOffsets.Length(); index++ {
+			_ = decodedPage.GetUint32(index)
+		}
+	}
+}
+
+func TestANewPageIsADataPage(t *testing.T) {
+	page := NewPage(blockSize)
+
+	assert.Equal(t, file.PageTypeData, page.PageType())
+	assert.Equal(t, uint(0), page.LogSequenceNumber())
+	assert.Equal(t, uint16(0), page.Flags())
+}
+
+func TestSetTheHeaderFieldsAndDecodeThePage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.SetPageType(file.PageTypeIndex)
+	page.SetFlags(0x1)
+	page.SetLogSequenceNumber(42)
+	page.AddUint32(32)
+	page.finish()
+
+	decodedPage := &Page{}
+	assert.Nil(t, decodedPage.DecodeFrom(page.buffer))
+
+	assert.Equal(t, file.PageTypeIndex, decodedPage.PageType())
+	assert.Equal(t, uint16(0x1), decodedPage.Flags())
+	assert.Equal(t, uint(42), decodedPage.LogSequenceNumber())
+	assert.Equal(t, uint32(32), decodedPage.GetUint32(0))
+}
+
+func TestDecodeABlockWhichWasNeverWritten(t *testing.T) {
+	decodedPage := &Page{}
+	assert.Nil(t, decodedPage.DecodeFrom(make([]byte, blockSize)))
+
+	assert.Equal(t, file.PageTypeData, decodedPage.PageType())
+	assert.Equal(t, blockSize-file.PageHeaderSize-reservedSizeForNumberOfOffsets, decodedPage.FreeSpace())
+}
+
+func TestAttemptToDecodeAPageWithAnInvalidHeader(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	file.PageHeaderOf(buffer).Format(file.PageTypeLog)
+
+	decodedPage := NewPage(blockSize)
+	decodedPage.AddUint8(8)
+
+	assert.ErrorIs(t, decodedPage.DecodeFrom(buffer), file.InvalidPageHeaderError)
+	assert.Equal(t, uint8(8), decodedPage.GetUint8(0))
+}
+
+func TestGetTheEncodedFieldsAndMutateThemInAnotherPage(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint16(16)
+	page.AddString("PebbleDB")
+	page.AddNull(TypeInt64)
+	page.AddDecimal(gorel.NewDecimal(12345, 2))
+	page.AddUint8(8)
+	page.Delete(4)
+
+	otherPage := NewPage(blockSize)
+	for index := 0; index < page.NumberOfFields(); index++ {
+		typeDescription, encoded, err := page.TryGetEncoded(index)
+		assert.Nil(t, err)
+
+		mutated, err := otherPage.TryMutateEncoded(index, typeDescription, encoded)
+		assert.Nil(t, err)
+		assert.True(t, mutated)
+	}
+
+	assert.Equal(t, 5, otherPage.NumberOfFields())
+	assert.Equal(t, uint16(16), otherPage.GetUint16(0))
+	assert.Equal(t, "PebbleDB", otherPage.GetString(1))
+	assert.True(t, otherPage.IsNull(2))
+	assert.Equal(t, gorel.NewDecimal(12345, 2), otherPage.GetDecimal(3))
+	assert.True(t, otherPage.IsDeleted(4))
+}
+
+func TestMutateEncodedFieldsToRestoreTheirPreviousValues(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddString("BoltDB")
+	page.AddNull(TypeUint64)
+
+	typeDescriptions, encodedValues := make([]TypeDescription, 3), make([][]byte, 3)
+	for index := range typeDescriptions {
+		typeDescription, encoded, err := page.TryGetEncoded(index)
+		assert.Nil(t, err)
+		typeDescriptions[index], encodedValues[index] = typeDescription, encoded
+	}
+
+	page.MutateUint32(0, 64)
+	page.MutateString(1, "RocksDB is an LSM-based storage engine")
+	page.MutateUint64(2, 128)
+
+	for index := range typeDescriptions {
+		mutated, err := page.TryMutateEncoded(index, typeDescriptions[index], encodedValues[index])
+		assert.Nil(t, err)
+		assert.True(t, mutated)
+	}
+	assert.Equal(t, uint32(32), page.GetUint32(0))
+	assert.Equal(t, "BoltDB", page.GetString(1))
+	assert.True(t, page.IsNull(2))
+}
+
+func TestMutateEncodedFieldsToRestoreDeletedFields(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+	page.AddString("BoltDB")
+	page.AddUint16(16)
+
+	typeDescription, encoded, err := page.TryGetEncoded(1)
+	assert.Nil(t, err)
+	page.Delete(0)
+	page.Delete(1)
+
+	mutated, err := page.TryMutateEncoded(1, typeDescription, encoded)
+	assert.Nil(t, err)
+	assert.True(t, mutated)
+	mutated, err = page.TryMutateEncoded(0, TypeUint32.WithNull(), nil)
+	assert.Nil(t, err)
+	assert.True(t, mutated)
+
+	assert.Equal(t, "BoltDB", page.GetString(1))
+	assert.True(t, page.IsNull(0))
+	assert.Equal(t, uint16(16), page.GetUint16(2))
+}
+
+func TestAttemptToMutateAnEncodedFieldWithATypeMismatch(t *testing.T) {
+	page := NewPage(blockSize)
+	page.AddUint32(32)
+
+	_, err := page.TryMutateEncoded(0, TypeUint64.WithNull(), nil)
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+
+	_, err = page.TryMutateEncoded(2, TypeUint32, []byte{1, 0, 0, 0})
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+}
+
+func TestCheckTheCapacityForAStoredImageWithoutModifyingThePage(t *testing.T) {
+	page := NewPage(128)
+	for page.AddUint64(64) {
+	}
+	numberOfFields := page.NumberOfFields()
+	content := bytes.Clone(page.Content())
+
+	hasCapacity, err := page.HasCapacityForStored(numberOfFields, TypeUint64, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	assert.Nil(t, err)
+	assert.False(t, hasCapacity)
+
+	hasCapacity, err = page.HasCapacityForStored(0, TypeUint64, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	assert.Nil(t, err)
+	assert.True(t, hasCapacity)
+
+	_, err = page.HasCapacityForStored(numberOfFields+1, TypeUint64, []byte{1, 0, 0, 0, 0, 0, 0, 0})
+	assert.ErrorIs(t, err, ErrIndexOutOfBounds)
+
+	assert.Equal(t, numberOfFields, page.NumberOfFields())
+	assert.Equal(t, content, page.Content())
+	assert.Equal(t, uint64(64), page.GetUint64(0))
+}
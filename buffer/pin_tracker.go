@@ -0,0 +1,145 @@
+package buffer
+
+import (
+	"fmt"
+	"gorel/file"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PinLeak describes a pin of a buffer which is held for too long, or which was never released.
+type PinLeak struct {
+	BlockId  file.BlockId
+	PinnedAt time.Time
+	Stack    string
+}
+
+func (leak PinLeak) String() string {
+	return fmt.Sprintf("block %v:%v pinned at %v by\n%v", leak.BlockId.FileName(), leak.BlockId.BlockNumber(), leak.PinnedAt, leak.Stack)
+}
+
+type pinRecord struct {
+	pinnedAt time.Time
+	stack    string
+	// owned is true if the pin is owned by a BufferHandle, which releases it by its record.
+	owned bool
+}
+
+// pinTracker records the caller stack of each pin. It is guarded by the lock of the BufferManager.
+type pinTracker struct {
+	pins   map[*Buffer][]*pinRecord
+	onLeak func(PinLeak)
+}
+
+func newPinTracker(onLeak func(PinLeak)) *pinTracker {
+	return &pinTracker{
+		pins:   make(map[*Buffer][]*pinRecord),
+		onLeak: onLeak,
+	}
+}
+
+func (tracker *pinTracker) pinned(buffer *Buffer) {
+	tracker.pins[buffer] = append(tracker.pins[buffer], &pinRecord{pinnedAt: time.Now(), stack: callerStack()})
+}
+
+// own marks the latest pin of the buffer as owned by a BufferHandle, and returns its record.
+func (tracker *pinTracker) own(buffer *Buffer) *pinRecord {
+	records := tracker.pins[buffer]
+	record := records[len(records)-1]
+	record.owned = true
+	return record
+}
+
+// unpinned removes the record of the released pin: the record of a BufferHandle, or the latest pin of the buffer
+// which no handle owns if the record is nil. A pin taken before the tracking was enabled has no record.
+func (tracker *pinTracker) unpinned(buffer *Buffer, released *pinRecord) {
+	records := tracker.pins[buffer]
+	index := -1
+	for candidate, record := range records {
+		if record == released || (released == nil && !record.owned) {
+			index = candidate
+		}
+	}
+	if index < 0 {
+		return
+	}
+	if records = slices.Delete(records, index, index+1); len(records) == 0 {
+		delete(tracker.pins, buffer)
+		return
+	}
+	tracker.pins[buffer] = records
+}
+
+func (tracker *pinTracker) pinsHeldLongerThan(threshold time.Duration) []PinLeak {
+	var leaks []PinLeak
+	now := time.Now()
+	for buffer, records := range tracker.pins {
+		for _, record := range records {
+			if now.Sub(record.pinnedAt) > threshold {
+				leaks = append(leaks, PinLeak{BlockId: buffer.blockId, PinnedAt: record.pinnedAt, Stack: record.stack})
+			}
+		}
+	}
+	return leaks
+}
+
+// callerStack returns the stack of the goroutine which pins a buffer, leaving out the frames of the buffer managers.
+func callerStack() string {
+	programCounters := make([]uintptr, 32)
+	frames := runtime.CallersFrames(programCounters[:runtime.Callers(3, programCounters)])
+
+	var stack strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "gorel/buffer.(*BufferManager)") &&
+			!strings.HasPrefix(frame.Function, "gorel/buffer.(*BufferPools)") {
+			stack.WriteString(fmt.Sprintf("%v\n\t%v:%v\n", frame.Function, frame.File, frame.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return stack.String()
+}
+
+// BufferHandle owns a single pin of a buffer. If pin tracking is enabled and a handle becomes unreachable without
+// being released, the leak is reported.
+type BufferHandle struct {
+	buffer        *Buffer
+	bufferManager *BufferManager
+	// record is the record of the pin, nil if pin tracking is disabled.
+	record   *pinRecord
+	released atomic.Bool
+}
+
+func newBufferHandle(
+	buffer *Buffer,
+	bufferManager *BufferManager,
+	record *pinRecord,
+	leak PinLeak,
+	onLeak func(PinLeak),
+) *BufferHandle {
+	handle := &BufferHandle{buffer: buffer, bufferManager: bufferManager, record: record}
+	if onLeak != nil {
+		runtime.SetFinalizer(handle, func(handle *BufferHandle) {
+			if !handle.released.Load() {
+				onLeak(leak)
+			}
+		})
+	}
+	return handle
+}
+
+func (handle *BufferHandle) Buffer() *Buffer {
+	return handle.buffer
+}
+
+// Release unpins the buffer; releasing a handle more than once has no effect.
+func (handle *BufferHandle) Release() {
+	if handle.released.CompareAndSwap(false, true) {
+		handle.bufferManager.unpin(handle.buffer, handle.record)
+	}
+}
@@ -0,0 +1,229 @@
+package buffer
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAssertNoPinsWithoutPinnedBuffers(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	bufferManager.EnablePinTracking(nil)
+
+	buffer, err := bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	bufferManager.Unpin(buffer)
+
+	assert.Nil(t, bufferManager.AssertNoPins())
+}
+
+func TestAssertNoPinsWithAPinnedBuffer(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	bufferManager.EnablePinTracking(nil)
+
+	_, err = bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+
+	err = bufferManager.AssertNoPins()
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "has 1 pin(s)")
+	assert.Contains(t, err.Error(), "TestAssertNoPinsWithAPinnedBuffer")
+}
+
+func TestPinsHeldLongerThanAThreshold(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+	anotherBlockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+	bufferManager.EnablePinTracking(nil)
+
+	_, err = bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = bufferManager.Pin(anotherBlockId)
+	assert.Nil(t, err)
+
+	leaks := bufferManager.PinsHeldLongerThan(10 * time.Millisecond)
+	assert.Equal(t, 1, len(leaks))
+	assert.Equal(t, blockId, leaks[0].BlockId)
+	assert.Contains(t, leaks[0].Stack, "TestPinsHeldLongerThanAThreshold")
+	assert.NotContains(t, leaks[0].Stack, "BufferManager")
+}
+
+func TestPinsHeldLongerThanAThresholdWithoutPinTracking(t *testing.T) {
+	bufferManager := NewBufferManager(0, nil, nil)
+	assert.Nil(t, bufferManager.PinsHeldLongerThan(time.Millisecond))
+}
+
+func TestReleaseABufferHandle(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+
+	handle, err := bufferManager.PinHandle(blockId)
+	assert.Nil(t, err)
+	assert.Equal(t, blockId, handle.Buffer().BlockId())
+	assert.Equal(t, 0, bufferManager.Available())
+
+	handle.Release()
+	handle.Release()
+	assert.Equal(t, 1, bufferManager.Available())
+}
+
+func TestReportABufferHandleWhichIsNeverReleased(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	leaks := make(chan PinLeak, 1)
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	bufferManager.EnablePinTracking(func(leak PinLeak) {
+		leaks <- leak
+	})
+
+	func() {
+		_, err := bufferManager.PinHandle(blockId)
+		assert.Nil(t, err)
+	}()
+
+	var leak PinLeak
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		select {
+		case leak = <-leaks:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, blockId, leak.BlockId)
+	assert.Contains(t, leak.Stack, "TestReportABufferHandleWhichIsNeverReleased")
+}
+
+func TestReleaseABufferHandleOfABufferPinnedTwice(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	bufferManager.EnablePinTracking(nil)
+
+	handle, err := bufferManager.PinHandle(blockId)
+	assert.Nil(t, err)
+	time.Sleep(5 * time.Millisecond)
+	buffer, err := bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+
+	handle.Release()
+	leaks := bufferManager.PinsHeldLongerThan(0)
+	assert.Equal(t, 1, len(leaks))
+	assert.True(t, leaks[0].PinnedAt.After(handle.record.pinnedAt))
+
+	bufferManager.Unpin(buffer)
+	assert.Nil(t, bufferManager.AssertNoPins())
+	assert.Empty(t, bufferManager.PinsHeldLongerThan(0))
+}
@@ -0,0 +1,101 @@
+package buffer
+
+import (
+	"gorel/file"
+	"sync"
+)
+
+// PinHint tells the BufferManager how the caller is going to access the blocks of a file.
+type PinHint uint8
+
+const (
+	// PinDefault prefetches the following blocks once a sequential access pattern is detected.
+	PinDefault PinHint = iota
+	// PinSequential always prefetches the blocks following the pinned block.
+	PinSequential
+	// PinRandom never prefetches.
+	PinRandom
+)
+
+// prefetcher reads the blocks that are likely to be pinned next, using a bounded pool of workers.
+// All the fields, except requests, are guarded by the lock of the BufferManager.
+type prefetcher struct {
+	depth            uint
+	requests         chan file.BlockId
+	inFlight         map[file.BlockId]bool
+	lastPinnedBlocks map[string]uint
+	workers          sync.WaitGroup
+}
+
+func newPrefetcher(depth uint, workers uint) *prefetcher {
+	return &prefetcher{
+		depth:            depth,
+		requests:         make(chan file.BlockId, depth*workers),
+		inFlight:         make(map[file.BlockId]bool),
+		lastPinnedBlocks: make(map[string]uint),
+	}
+}
+
+func (prefetcher *prefetcher) start(workers uint, prefetchFn func(blockId file.BlockId)) {
+	for worker := uint(0); worker < workers; worker++ {
+		prefetcher.workers.Add(1)
+		go func() {
+			defer prefetcher.workers.Done()
+			for blockId := range prefetcher.requests {
+				prefetchFn(blockId)
+			}
+		}()
+	}
+}
+
+func (prefetcher *prefetcher) stop() {
+	close(prefetcher.requests)
+	prefetcher.workers.Wait()
+}
+
+// blocksToPrefetch records the pin of the block and returns the blocks which should be prefetched.
+func (prefetcher *prefetcher) blocksToPrefetch(blockId file.BlockId, hint PinHint) []file.BlockId {
+	fileName, blockNumber := blockId.FileName(), blockId.BlockNumber()
+	lastPinnedBlockNumber, pinnedBefore := prefetcher.lastPinnedBlocks[fileName]
+	prefetcher.lastPinnedBlocks[fileName] = blockNumber
+
+	switch hint {
+	case PinRandom:
+		return nil
+	case PinDefault:
+		if !pinnedBefore || lastPinnedBlockNumber+1 != blockNumber {
+			return nil
+		}
+	}
+	blockIds := make([]file.BlockId, 0, prefetcher.depth)
+	for next := uint(1); next <= prefetcher.depth; next++ {
+		blockIds = append(blockIds, file.NewBlockId(fileName, blockNumber+next))
+	}
+	return blockIds
+}
+
+// schedule hands over the block to the workers, unless it is already being prefetched or the workers are busy.
+func (prefetcher *prefetcher) schedule(blockId file.BlockId) {
+	if _, ok := prefetcher.inFlight[blockId]; ok {
+		return
+	}
+	select {
+	case prefetcher.requests <- blockId:
+		prefetcher.inFlight[blockId] = true
+	default:
+	}
+}
+
+// invalidate marks the in-flight read of the block as stale, because the block was written after the read began.
+func (prefetcher *prefetcher) invalidate(blockId file.BlockId) {
+	if _, ok := prefetcher.inFlight[blockId]; ok {
+		prefetcher.inFlight[blockId] = false
+	}
+}
+
+// done returns true if the prefetched block can be assigned to a buffer.
+func (prefetcher *prefetcher) done(blockId file.BlockId) bool {
+	valid := prefetcher.inFlight[blockId]
+	delete(prefetcher.inFlight, blockId)
+	return valid
+}
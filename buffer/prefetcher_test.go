@@ -0,0 +1,158 @@
+package buffer
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"testing"
+	"time"
+)
+
+func writeBlocks(t *testing.T, fileManager *file.BlockFileManager, fileName string, numberOfBlocks uint) {
+	for blockNumber := uint(0); blockNumber < numberOfBlocks; blockNumber++ {
+		page := NewPage(fileManager.BlockSize())
+		page.AddUint32(uint32(blockNumber))
+		page.finish()
+		assert.Nil(t, fileManager.Write(file.NewBlockId(fileName, blockNumber), page))
+	}
+}
+
+func TestBlocksToPrefetchOnSequentialAccess(t *testing.T) {
+	prefetcher := newPrefetcher(2, 1)
+
+	assert.Nil(t, prefetcher.blocksToPrefetch(file.NewBlockId("table", 0), PinDefault))
+	assert.Equal(t,
+		[]file.BlockId{file.NewBlockId("table", 2), file.NewBlockId("table", 3)},
+		prefetcher.blocksToPrefetch(file.NewBlockId("table", 1), PinDefault),
+	)
+}
+
+func TestBlocksToPrefetchOnRandomAccess(t *testing.T) {
+	prefetcher := newPrefetcher(2, 1)
+
+	assert.Nil(t, prefetcher.blocksToPrefetch(file.NewBlockId("table", 0), PinDefault))
+	assert.Nil(t, prefetcher.blocksToPrefetch(file.NewBlockId("table", 5), PinDefault))
+	assert.Nil(t, prefetcher.blocksToPrefetch(file.NewBlockId("index", 6), PinDefault))
+}
+
+func TestBlocksToPrefetchWithHints(t *testing.T) {
+	prefetcher := newPrefetcher(1, 1)
+
+	assert.Equal(t,
+		[]file.BlockId{file.NewBlockId("table", 6)},
+		prefetcher.blocksToPrefetch(file.NewBlockId("table", 5), PinSequential),
+	)
+	assert.Nil(t, prefetcher.blocksToPrefetch(file.NewBlockId("table", 6), PinRandom))
+}
+
+func TestInvalidatedPrefetchIsNotDone(t *testing.T) {
+	prefetcher := newPrefetcher(1, 1)
+	blockId := file.NewBlockId("table", 1)
+
+	prefetcher.schedule(blockId)
+	prefetcher.invalidate(blockId)
+
+	assert.False(t, prefetcher.done(blockId))
+}
+
+func TestPrefetchBlocksOnSequentialPins(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	writeBlocks(t, fileManager, fileName, 4)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(4, fileManager, logManager)
+	bufferManager.EnablePrefetching(2, 2)
+	defer bufferManager.Close()
+
+	_, err = bufferManager.Pin(file.NewBlockId(fileName, 0))
+	assert.Nil(t, err)
+	_, err = bufferManager.Pin(file.NewBlockId(fileName, 1))
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return bufferManager.Stats().Prefetches == 2
+	}, time.Second, time.Millisecond)
+
+	buffer, err := bufferManager.Pin(file.NewBlockId(fileName, 3))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), buffer.Page().GetUint32(0))
+	assert.Equal(t, uint64(1), bufferManager.Stats().Hits)
+}
+
+func TestPrefetchBlocksWithSequentialHint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	writeBlocks(t, fileManager, fileName, 2)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+	bufferManager.EnablePrefetching(1, 1)
+	defer bufferManager.Close()
+
+	_, err = bufferManager.PinWithHint(file.NewBlockId(fileName, 0), PinSequential)
+	assert.Nil(t, err)
+
+	assert.Eventually(t, func() bool {
+		return bufferManager.Stats().Prefetches == 1
+	}, time.Second, time.Millisecond)
+
+	buffer, err := bufferManager.Pin(file.NewBlockId(fileName, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), buffer.Page().GetUint32(0))
+}
+
+func TestDoesNotPrefetchIntoPinnedBuffers(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	writeBlocks(t, fileManager, fileName, 2)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	bufferManager.EnablePrefetching(1, 1)
+
+	_, err = bufferManager.PinWithHint(file.NewBlockId(fileName, 0), PinSequential)
+	assert.Nil(t, err)
+
+	bufferManager.Close()
+	assert.Equal(t, uint64(0), bufferManager.Stats().Prefetches)
+	assert.Equal(t, file.NewBlockId(fileName, 0), bufferManager.bufferPool[0].BlockId())
+}
@@ -0,0 +1,115 @@
+package buffer
+
+// ReplacementPolicy decides which unpinned buffer gets assigned to a block that is not present in the buffer pool.
+type ReplacementPolicy uint8
+
+const (
+	// ReplacementNaive chooses the first unpinned buffer.
+	ReplacementNaive ReplacementPolicy = iota
+	// ReplacementLRU chooses the unpinned buffer that was unpinned the earliest.
+	ReplacementLRU
+	// ReplacementClock sweeps over the buffers and chooses the first unpinned buffer that is not referenced since
+	// the last sweep.
+	ReplacementClock
+)
+
+type replacer interface {
+	pinned(buffer *Buffer)
+	unpinned(buffer *Buffer)
+	chooseUnpinnedBuffer() *Buffer
+}
+
+func newReplacer(policy ReplacementPolicy, bufferPool []*Buffer) replacer {
+	switch policy {
+	case ReplacementLRU:
+		return newLRUReplacer(bufferPool)
+	case ReplacementClock:
+		return newClockReplacer(bufferPool)
+	}
+	return naiveReplacer{bufferPool: bufferPool}
+}
+
+type naiveReplacer struct {
+	bufferPool []*Buffer
+}
+
+func (replacer naiveReplacer) pinned(*Buffer) {}
+
+func (replacer naiveReplacer) unpinned(*Buffer) {}
+
+func (replacer naiveReplacer) chooseUnpinnedBuffer() *Buffer {
+	for _, buffer := range replacer.bufferPool {
+		if !buffer.isPinned() {
+			return buffer
+		}
+	}
+	return nil
+}
+
+type lruReplacer struct {
+	bufferPool   []*Buffer
+	lastUnpinned map[*Buffer]uint64
+	tick         uint64
+}
+
+func newLRUReplacer(bufferPool []*Buffer) *lruReplacer {
+	return &lruReplacer{
+		bufferPool:   bufferPool,
+		lastUnpinned: make(map[*Buffer]uint64),
+	}
+}
+
+func (replacer *lruReplacer) pinned(*Buffer) {}
+
+func (replacer *lruReplacer) unpinned(buffer *Buffer) {
+	replacer.tick += 1
+	replacer.lastUnpinned[buffer] = replacer.tick
+}
+
+func (replacer *lruReplacer) chooseUnpinnedBuffer() *Buffer {
+	var leastRecentlyUsed *Buffer
+	for _, buffer := range replacer.bufferPool {
+		if buffer.isPinned() {
+			continue
+		}
+		if leastRecentlyUsed == nil || replacer.lastUnpinned[buffer] < replacer.lastUnpinned[leastRecentlyUsed] {
+			leastRecentlyUsed = buffer
+		}
+	}
+	return leastRecentlyUsed
+}
+
+type clockReplacer struct {
+	bufferPool []*Buffer
+	referenced map[*Buffer]bool
+	hand       int
+}
+
+func newClockReplacer(bufferPool []*Buffer) *clockReplacer {
+	return &clockReplacer{
+		bufferPool: bufferPool,
+		referenced: make(map[*Buffer]bool),
+	}
+}
+
+func (replacer *clockReplacer) pinned(buffer *Buffer) {
+	replacer.referenced[buffer] = true
+}
+
+func (replacer *clockReplacer) unpinned(*Buffer) {}
+
+func (replacer *clockReplacer) chooseUnpinnedBuffer() *Buffer {
+	for sweep := 0; sweep < 2*len(replacer.bufferPool); sweep++ {
+		buffer := replacer.bufferPool[replacer.hand]
+		replacer.hand = (replacer.hand + 1) % len(replacer.bufferPool)
+		if buffer.isPinned() {
+			continue
+		}
+		if replacer.referenced[buffer] {
+			replacer.referenced[buffer] = false
+			continue
+		}
+		return buffer
+	}
+	return nil
+}
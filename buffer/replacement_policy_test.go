@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newBufferPoolForReplacement(capacity int) []*Buffer {
+	bufferPool := make([]*Buffer, capacity)
+	for index := 0; index < capacity; index++ {
+		bufferPool[index] = &Buffer{}
+	}
+	return bufferPool
+}
+
+func TestNaiveReplacerChoosesTheFirstUnpinnedBuffer(t *testing.T) {
+	bufferPool := newBufferPoolForReplacement(3)
+	bufferPool[0].pin()
+
+	replacer := newReplacer(ReplacementNaive, bufferPool)
+	assert.Same(t, bufferPool[1], replacer.chooseUnpinnedBuffer())
+}
+
+func TestNaiveReplacerWithAllBuffersPinned(t *testing.T) {
+	bufferPool := newBufferPoolForReplacement(1)
+	bufferPool[0].pin()
+
+	replacer := newReplacer(ReplacementNaive, bufferPool)
+	assert.Nil(t, replacer.chooseUnpinnedBuffer())
+}
+
+func TestLRUReplacerChoosesTheLeastRecentlyUnpinnedBuffer(t *testing.T) {
+	bufferPool := newBufferPoolForReplacement(3)
+	replacer := newReplacer(ReplacementLRU, bufferPool)
+
+	for _, index := range []int{2, 0, 1} {
+		replacer.pinned(bufferPool[index])
+		replacer.unpinned(bufferPool[index])
+	}
+	assert.Same(t, bufferPool[2], replacer.chooseUnpinnedBuffer())
+
+	bufferPool[2].pin()
+	assert.Same(t, bufferPool[0], replacer.chooseUnpinnedBuffer())
+}
+
+func TestLRUReplacerPrefersANeverUsedBuffer(t *testing.T) {
+	bufferPool := newBufferPoolForReplacement(2)
+	replacer := newReplacer(ReplacementLRU, bufferPool)
+
+	replacer.unpinned(bufferPool[0])
+	assert.Same(t, bufferPool[1], replacer.chooseUnpinnedBuffer())
+}
+
+func TestClockReplacerSkipsReferencedBuffers(t *testing.T) {
+	bufferPool := newBufferPoolForReplacement(3)
+	replacer := newReplacer(ReplacementClock, bufferPool)
+
+	replacer.pinned(bufferPool[0])
+	replacer.pinned(bufferPool[1])
+
+	assert.Same(t, bufferPool[2], replacer.chooseUnpinnedBuffer())
+	assert.Same(t, bufferPool[0], replacer.chooseUnpinnedBuffer())
+}
+
+func TestClockReplacerWithAllBuffersPinned(t *testing.T) {
+	bufferPool := newBufferPoolForReplacement(2)
+	bufferPool[0].pin()
+	bufferPool[1].pin()
+
+	replacer := newReplacer(ReplacementClock, bufferPool)
+	assert.Nil(t, replacer.chooseUnpinnedBuffer())
+}
@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"gorel/file"
+	"sync/atomic"
+	"time"
+)
+
+// Statistics is a point-in-time view of the counters maintained by the BufferManager.
+type Statistics struct {
+	Hits            uint64
+	Misses          uint64
+	Evictions       uint64
+	DirtyWrites     uint64
+	PinWaits        uint64
+	Prefetches      uint64
+	AverageWaitTime time.Duration
+}
+
+// FrameSnapshot describes a single buffer of the buffer pool.
+type FrameSnapshot struct {
+	BlockId           file.BlockId
+	Pins              int
+	Modified          bool
+	TransactionNumber int
+	LogSequenceNumber uint
+}
+
+type statistics struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	evictions     atomic.Uint64
+	dirtyWrites   atomic.Uint64
+	pinWaits      atomic.Uint64
+	prefetches    atomic.Uint64
+	totalWaitTime atomic.Int64
+}
+
+func (statistics *statistics) recordHit() {
+	statistics.hits.Add(1)
+}
+
+func (statistics *statistics) recordMiss() {
+	statistics.misses.Add(1)
+}
+
+func (statistics *statistics) recordEviction() {
+	statistics.evictions.Add(1)
+}
+
+func (statistics *statistics) recordDirtyWrite() {
+	statistics.dirtyWrites.Add(1)
+}
+
+func (statistics *statistics) recordPinWait(waitTime time.Duration) {
+	statistics.pinWaits.Add(1)
+	statistics.totalWaitTime.Add(int64(waitTime))
+}
+
+func (statistics *statistics) recordPrefetch() {
+	statistics.prefetches.Add(1)
+}
+
+func (statistics *statistics) snapshot() Statistics {
+	pinWaits := statistics.pinWaits.Load()
+	var averageWaitTime time.Duration
+	if pinWaits > 0 {
+		averageWaitTime = time.Duration(statistics.totalWaitTime.Load() / int64(pinWaits))
+	}
+	return Statistics{
+		Hits:            statistics.hits.Load(),
+		Misses:          statistics.misses.Load(),
+		Evictions:       statistics.evictions.Load(),
+		DirtyWrites:     statistics.dirtyWrites.Load(),
+		PinWaits:        pinWaits,
+		Prefetches:      statistics.prefetches.Load(),
+		AverageWaitTime: averageWaitTime,
+	}
+}
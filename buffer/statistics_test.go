@@ -0,0 +1,209 @@
+package buffer
+
+import (
+	"expvar"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestStatisticsForHitsAndMisses(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+
+	_, err = bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	_, err = bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+
+	stats := bufferManager.Stats()
+	assert.Equal(t, uint64(1), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(0), stats.Evictions)
+}
+
+func TestStatisticsForEvictionsAndDirtyWrites(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+	anotherBlockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+
+	buffer, err := bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	buffer.Page().AddUint16(16)
+	buffer.SetModified(1, 0)
+	bufferManager.Unpin(buffer)
+
+	buffer, err = bufferManager.Pin(anotherBlockId)
+	assert.Nil(t, err)
+	bufferManager.Unpin(buffer)
+
+	_, err = bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+
+	stats := bufferManager.Stats()
+	assert.Equal(t, uint64(3), stats.Misses)
+	assert.Equal(t, uint64(2), stats.Evictions)
+	assert.Equal(t, uint64(1), stats.DirtyWrites)
+}
+
+func TestStatisticsForAPinWaitWhichTimesOut(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManagerWithMaxWaitTime(1, 10*time.Millisecond, fileManager, logManager)
+	bufferManager.bufferPool[0].pin()
+
+	_, err = bufferManager.Pin(file.NewBlockId(fileName, 0))
+	assert.EqualError(t, err, NoBufferAvailableForPinningError.Error())
+
+	stats := bufferManager.Stats()
+	assert.Equal(t, uint64(1), stats.PinWaits)
+	assert.True(t, stats.AverageWaitTime >= 10*time.Millisecond)
+}
+
+func TestPinWaitsForABufferToGetUnpinned(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+	anotherBlockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManagerWithMaxWaitTime(1, 5*time.Second, fileManager, logManager)
+	buffer, err := bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		bufferManager.Unpin(buffer)
+	}()
+
+	anotherBuffer, err := bufferManager.Pin(anotherBlockId)
+	assert.Nil(t, err)
+	assert.Equal(t, anotherBlockId, anotherBuffer.BlockId())
+	assert.Equal(t, uint64(1), bufferManager.Stats().PinWaits)
+}
+
+func TestSnapshotOfBufferPool(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(logFileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	bufferManager := NewBufferManager(2, fileManager, logManager)
+	buffer, err := bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	buffer.SetModified(5, 20)
+
+	frames := bufferManager.Snapshot()
+	assert.Equal(t, 2, len(frames))
+	assert.Equal(t, FrameSnapshot{
+		BlockId:           blockId,
+		Pins:              1,
+		Modified:          true,
+		TransactionNumber: 5,
+		LogSequenceNumber: 20,
+	}, frames[0])
+	assert.True(t, frames[1].BlockId.IsMissing())
+	assert.False(t, frames[1].Modified)
+}
+
+func TestPublishStats(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	logFileName := fmt.Sprintf("%v_%v", t.Name(), "log")
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(logFileName)
+	}()
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName)
+	assert.Nil(t, err)
+
+	name := fmt.Sprintf("%v_%v", t.Name(), time.Now().UnixNano())
+
+	bufferManager := NewBufferManager(1, fileManager, logManager)
+	assert.Nil(t, bufferManager.PublishStats(name))
+	assert.Contains(t, expvar.Get(name).String(), "Hits")
+
+	assert.NotNil(t, bufferManager.PublishStats(name))
+}
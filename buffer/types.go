@@ -16,9 +16,35 @@ const (
 	TypeUint64    TypeDescription = 4
 	TypeString    TypeDescription = 5
 	TypeByteSlice TypeDescription = 6
+	TypeInt8      TypeDescription = 7
+	TypeInt16     TypeDescription = 8
+	TypeInt32     TypeDescription = 9
+	TypeInt64     TypeDescription = 10
+	TypeFloat32   TypeDescription = 11
+	TypeFloat64   TypeDescription = 12
+	TypeBool      TypeDescription = 13
+	TypeDate      TypeDescription = 14
+	TypeTimestamp TypeDescription = 15
+	TypeDecimal   TypeDescription = 16
+
+	// TypeTombstone marks a deleted field, which does not occupy any bytes in the page.
+	TypeTombstone TypeDescription = 0
 )
 
+// nullFlag is set in the type description of a NULL field, which does not occupy any bytes in the page.
+const nullFlag TypeDescription = 0x80
+
+// overflowFlag is set in the type description of a string or a byte slice field whose value is stored in a chain of
+// overflow blocks. The page holds an encoded overflowPointer for the field.
+const overflowFlag TypeDescription = 0x40
+
 func (typeDescription TypeDescription) AsString() string {
+	if typeDescription.IsNull() {
+		return "null " + typeDescription.WithoutNull().AsString()
+	}
+	if typeDescription.IsOverflow() {
+		return "overflow " + typeDescription.WithoutOverflow().AsString()
+	}
 	switch typeDescription {
 	case TypeUint8:
 		return "uint8"
@@ -32,13 +58,38 @@ func (typeDescription TypeDescription) AsString() string {
 		return "string"
 	case TypeByteSlice:
 		return "[]byte"
+	case TypeInt8:
+		return "int8"
+	case TypeInt16:
+		return "int16"
+	case TypeInt32:
+		return "int32"
+	case TypeInt64:
+		return "int64"
+	case TypeFloat32:
+		return "float32"
+	case TypeFloat64:
+		return "float64"
+	case TypeBool:
+		return "bool"
+	case TypeDate:
+		return "date"
+	case TypeTimestamp:
+		return "timestamp"
+	case TypeDecimal:
+		return "decimal"
+	case TypeTombstone:
+		return "tombstone"
 	}
 	return ""
 }
 
-func (typeDescription TypeDescription) EndOffsetPostDecode(source []byte, fromOffset uint16) gorel.EndOffset {
+func (typeDescription TypeDescription) EndOffsetPostDecode(source []byte, fromOffset uint32) gorel.EndOffset {
+	if typeDescription.IsNull() {
+		return fromOffset
+	}
 	var endOffset gorel.EndOffset
-	switch typeDescription {
+	switch typeDescription.WithoutOverflow() {
 	case TypeUint8:
 		_, endOffset = gorel.DecodeUint8(source, fromOffset)
 	case TypeUint16:
@@ -51,6 +102,28 @@ func (typeDescription TypeDescription) EndOffsetPostDecode(source []byte, fromOf
 		_, endOffset = gorel.DecodeByteSlice(source, fromOffset)
 	case TypeByteSlice:
 		_, endOffset = gorel.DecodeByteSlice(source, fromOffset)
+	case TypeInt8:
+		_, endOffset = gorel.DecodeInt8(source, fromOffset)
+	case TypeInt16:
+		_, endOffset = gorel.DecodeInt16(source, fromOffset)
+	case TypeInt32:
+		_, endOffset = gorel.DecodeInt32(source, fromOffset)
+	case TypeInt64:
+		_, endOffset = gorel.DecodeInt64(source, fromOffset)
+	case TypeFloat32:
+		_, endOffset = gorel.DecodeFloat32(source, fromOffset)
+	case TypeFloat64:
+		_, endOffset = gorel.DecodeFloat64(source, fromOffset)
+	case TypeBool:
+		_, endOffset = gorel.DecodeBool(source, fromOffset)
+	case TypeDate:
+		_, endOffset = gorel.DecodeDate(source, fromOffset)
+	case TypeTimestamp:
+		_, endOffset = gorel.DecodeTimestamp(source, fromOffset)
+	case TypeDecimal:
+		_, endOffset = gorel.DecodeDecimal(source, fromOffset)
+	case TypeTombstone:
+		endOffset = fromOffset
 	}
 	return endOffset
 }
@@ -59,8 +132,46 @@ func (typeDescription TypeDescription) Equals(other TypeDescription) bool {
 	return uint8(typeDescription) == uint8(other)
 }
 
+func (typeDescription TypeDescription) IsNull() bool {
+	return typeDescription&nullFlag == nullFlag
+}
+
+func (typeDescription TypeDescription) WithNull() TypeDescription {
+	return typeDescription | nullFlag
+}
+
+func (typeDescription TypeDescription) WithoutNull() TypeDescription {
+	return typeDescription &^ nullFlag
+}
+
+func (typeDescription TypeDescription) IsOverflow() bool {
+	return typeDescription&overflowFlag == overflowFlag
+}
+
+func (typeDescription TypeDescription) withOverflow() TypeDescription {
+	return typeDescription | overflowFlag
+}
+
+func (typeDescription TypeDescription) WithoutOverflow() TypeDescription {
+	return typeDescription &^ overflowFlag
+}
+
+// isVariableLength returns true for the string and the byte slice types, whose values may overflow.
+func (typeDescription TypeDescription) isVariableLength() bool {
+	typeDescription = typeDescription.WithoutNull().WithoutOverflow()
+	return typeDescription.Equals(TypeString) || typeDescription.Equals(TypeByteSlice)
+}
+
+// occupiesBytes returns false for the tombstones and the NULL fields.
+func (typeDescription TypeDescription) occupiesBytes() bool {
+	return !typeDescription.IsNull() && !typeDescription.Equals(TypeTombstone)
+}
+
+// Types holds the type descriptions of the fields of a page. Types that are lazily decoded read the descriptions
+// straight from the encoded bytes, and decode them only when a description is added or set.
 type Types struct {
 	description []TypeDescription
+	encoded     []byte
 }
 
 func NewTypes() *Types {
@@ -75,11 +186,22 @@ func DecodeTypesFrom(buffer []byte) *Types {
 	return types
 }
 
+// LazilyDecodeTypesFrom returns Types which refer to the buffer until they are modified, so the buffer must not be
+// modified in the meantime.
+func LazilyDecodeTypesFrom(buffer []byte) *Types {
+	return &Types{encoded: buffer}
+}
+
 func (types *Types) AddTypeDescription(description TypeDescription) {
+	types.decode()
 	types.description = append(types.description, description)
 }
 
+// Encode returns the encoded bytes which lazily decoded Types refer to, if they are not modified.
 func (types *Types) Encode() []byte {
+	if types.encoded != nil {
+		return types.encoded
+	}
 	buffer := make([]byte, len(types.description)*ReservedSizeForAType)
 	offsetIndex := 0
 	for _, definition := range types.description {
@@ -90,17 +212,35 @@ func (types *Types) Encode() []byte {
 }
 
 func (types *Types) GetTypeAt(index int) TypeDescription {
+	if types.encoded != nil {
+		return TypeDescription(types.encoded[index*ReservedSizeForAType])
+	}
 	return types.description[index]
 }
 
+func (types *Types) SetTypeAt(index int, description TypeDescription) {
+	types.decode()
+	types.description[index] = description
+}
+
 func (types *Types) SizeUsedInBytes() int {
-	return ReservedSizeForAType * len(types.description)
+	return ReservedSizeForAType * types.Length()
 }
 
-func SizeUsedInBytes(numberOfDescriptions uint16) int {
+func SizeUsedInBytes(numberOfDescriptions uint32) int {
 	return ReservedSizeForAType * int(numberOfDescriptions)
 }
 
 func (types *Types) Length() int {
+	if types.encoded != nil {
+		return len(types.encoded) / ReservedSizeForAType
+	}
 	return len(types.description)
 }
+
+func (types *Types) decode() {
+	if types.encoded != nil {
+		types.description = DecodeTypesFrom(types.encoded).description
+		types.encoded = nil
+	}
+}
@@ -67,3 +67,74 @@ func TestEncodeAndDecodeTypesWithAByteSliceTypeDescription(t *testing.T) {
 	assert.Equal(t, 1, len(decodedTypes.description))
 	assert.Equal(t, TypeByteSlice, decodedTypes.description[0])
 }
+
+func TestTypeDescriptionAsString(t *testing.T) {
+	assert.Equal(t, "int8", TypeInt8.AsString())
+	assert.Equal(t, "float64", TypeFloat64.AsString())
+	assert.Equal(t, "bool", TypeBool.AsString())
+	assert.Equal(t, "timestamp", TypeTimestamp.AsString())
+	assert.Equal(t, "decimal", TypeDecimal.AsString())
+}
+
+func TestEndOffsetPostDecodeForFixedSizeTypes(t *testing.T) {
+	source := make([]byte, 32)
+
+	assert.Equal(t, uint32(3), TypeInt16.EndOffsetPostDecode(source, 1))
+	assert.Equal(t, uint32(9), TypeFloat64.EndOffsetPostDecode(source, 1))
+	assert.Equal(t, uint32(2), TypeBool.EndOffsetPostDecode(source, 1))
+	assert.Equal(t, uint32(5), TypeDate.EndOffsetPostDecode(source, 1))
+	assert.Equal(t, uint32(13), TypeTimestamp.EndOffsetPostDecode(source, 1))
+	assert.Equal(t, uint32(10), TypeDecimal.EndOffsetPostDecode(source, 1))
+}
+
+func TestNullTypeDescription(t *testing.T) {
+	nullUint32 := TypeUint32.WithNull()
+
+	assert.True(t, nullUint32.IsNull())
+	assert.False(t, TypeUint32.IsNull())
+	assert.Equal(t, TypeUint32, nullUint32.WithoutNull())
+	assert.Equal(t, "null uint32", nullUint32.AsString())
+	assert.Equal(t, uint32(5), nullUint32.EndOffsetPostDecode(make([]byte, 16), 5))
+}
+
+func TestEncodeAndDecodeTypesWithANullTypeDescription(t *testing.T) {
+	types := NewTypes()
+	types.AddTypeDescription(TypeString.WithNull())
+
+	decodedTypes := DecodeTypesFrom(types.Encode())
+
+	assert.True(t, decodedTypes.GetTypeAt(0).IsNull())
+	assert.Equal(t, TypeString, decodedTypes.GetTypeAt(0).WithoutNull())
+}
+
+func TestOverflowTypeDescription(t *testing.T) {
+	overflowString := TypeString.withOverflow()
+
+	assert.True(t, overflowString.IsOverflow())
+	assert.False(t, TypeString.IsOverflow())
+	assert.Equal(t, TypeString, overflowString.WithoutOverflow())
+	assert.Equal(t, "overflow string", overflowString.AsString())
+	assert.True(t, overflowString.occupiesBytes())
+}
+
+func TestLazilyDecodeTypes(t *testing.T) {
+	types := NewTypes()
+	types.AddTypeDescription(TypeUint16)
+	types.AddTypeDescription(TypeString)
+	encoded := types.Encode()
+
+	decodedTypes := LazilyDecodeTypesFrom(encoded)
+
+	assert.Nil(t, decodedTypes.description)
+	assert.Equal(t, 2, decodedTypes.Length())
+	assert.Equal(t, TypeString, decodedTypes.GetTypeAt(1))
+	assert.Equal(t, encoded, decodedTypes.Encode())
+
+	decodedTypes.SetTypeAt(0, TypeTombstone)
+	decodedTypes.AddTypeDescription(TypeBool)
+
+	assert.Equal(t, 3, decodedTypes.Length())
+	assert.Equal(t, TypeTombstone, decodedTypes.GetTypeAt(0))
+	assert.Equal(t, TypeBool, decodedTypes.GetTypeAt(2))
+	assert.Equal(t, TypeUint16, TypeDescription(encoded[0]))
+}
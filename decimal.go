@@ -0,0 +1,32 @@
+package gorel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Decimal is a fixed-scale decimal number, whose value is Unscaled * 10^(-Scale).
+type Decimal struct {
+	Unscaled int64
+	Scale    uint8
+}
+
+func NewDecimal(unscaled int64, scale uint8) Decimal {
+	return Decimal{Unscaled: unscaled, Scale: scale}
+}
+
+func (decimal Decimal) String() string {
+	if decimal.Scale == 0 {
+		return fmt.Sprintf("%d", decimal.Unscaled)
+	}
+	sign := ""
+	unscaled := fmt.Sprintf("%d", decimal.Unscaled)
+	if decimal.Unscaled < 0 {
+		sign, unscaled = "-", unscaled[1:]
+	}
+	if len(unscaled) <= int(decimal.Scale) {
+		unscaled = strings.Repeat("0", int(decimal.Scale)-len(unscaled)+1) + unscaled
+	}
+	integerPartLength := len(unscaled) - int(decimal.Scale)
+	return sign + unscaled[:integerPartLength] + "." + unscaled[integerPartLength:]
+}
@@ -0,0 +1,14 @@
+package gorel
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDecimalAsString(t *testing.T) {
+	assert.Equal(t, "123.45", NewDecimal(12345, 2).String())
+	assert.Equal(t, "-123.45", NewDecimal(-12345, 2).String())
+	assert.Equal(t, "0.05", NewDecimal(5, 2).String())
+	assert.Equal(t, "-0.005", NewDecimal(-5, 3).String())
+	assert.Equal(t, "42", NewDecimal(42, 0).String())
+}
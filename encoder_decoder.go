@@ -2,35 +2,98 @@ package gorel
 
 import (
 	"encoding/binary"
+	"math"
+	"time"
 	"unsafe"
 )
 
 var (
-	reservedSizeForByteSlice = uint(unsafe.Sizeof(uint16(0)))
+	reservedSizeForByteSlice = uint(unsafe.Sizeof(uint32(0)))
 	uint8Size                = uint(unsafe.Sizeof(uint8(0)))
 	uint16Size               = uint(unsafe.Sizeof(uint16(0)))
 	uint32Size               = uint(unsafe.Sizeof(uint32(0)))
 	uint64Size               = uint(unsafe.Sizeof(uint64(0)))
+	dateSize                 = uint32Size
+	timestampSize            = uint64Size + uint32Size
+	decimalSize              = uint64Size + uint8Size
 )
 
+const secondsInADay = 24 * 60 * 60
+
 type BytesNeededForEncoding = uint
-type EndOffset = uint16
+type EndOffset = uint32
 
 func BytesNeededForEncodingAByteSlice(buffer []byte) BytesNeededForEncoding {
 	return (reservedSizeForByteSlice) + uint(len(buffer))
 }
 
+func BytesNeededForEncodingAnUint8() BytesNeededForEncoding {
+	return uint8Size
+}
+
+func BytesNeededForEncodingAnUint16() BytesNeededForEncoding {
+	return uint16Size
+}
+
+func BytesNeededForEncodingAnUint32() BytesNeededForEncoding {
+	return uint32Size
+}
+
+func BytesNeededForEncodingAnUint64() BytesNeededForEncoding {
+	return uint64Size
+}
+
+func BytesNeededForEncodingAnInt8() BytesNeededForEncoding {
+	return uint8Size
+}
+
+func BytesNeededForEncodingAnInt16() BytesNeededForEncoding {
+	return uint16Size
+}
+
+func BytesNeededForEncodingAnInt32() BytesNeededForEncoding {
+	return uint32Size
+}
+
+func BytesNeededForEncodingAnInt64() BytesNeededForEncoding {
+	return uint64Size
+}
+
+func BytesNeededForEncodingAFloat32() BytesNeededForEncoding {
+	return uint32Size
+}
+
+func BytesNeededForEncodingAFloat64() BytesNeededForEncoding {
+	return uint64Size
+}
+
+func BytesNeededForEncodingABool() BytesNeededForEncoding {
+	return uint8Size
+}
+
+func BytesNeededForEncodingADate() BytesNeededForEncoding {
+	return dateSize
+}
+
+func BytesNeededForEncodingATimestamp() BytesNeededForEncoding {
+	return timestampSize
+}
+
+func BytesNeededForEncodingADecimal() BytesNeededForEncoding {
+	return decimalSize
+}
+
 func EncodeByteSlice(source []byte, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
-	binary.LittleEndian.PutUint16(destination[destinationStartingOffset:], uint16(len(source)))
+	binary.LittleEndian.PutUint32(destination[destinationStartingOffset:], uint32(len(source)))
 	copy(destination[destinationStartingOffset+reservedSizeForByteSlice:], source)
 
 	return reservedSizeForByteSlice + uint(len(source))
 }
 
-func DecodeByteSlice(source []byte, fromOffset uint16) ([]byte, EndOffset) {
-	byteSliceLength := binary.LittleEndian.Uint16(source[fromOffset:])
-	endOffset := fromOffset + uint16(reservedSizeForByteSlice) + byteSliceLength
-	return source[fromOffset+uint16(reservedSizeForByteSlice) : endOffset], endOffset
+func DecodeByteSlice(source []byte, fromOffset uint32) ([]byte, EndOffset) {
+	byteSliceLength := binary.LittleEndian.Uint32(source[fromOffset:])
+	endOffset := fromOffset + uint32(reservedSizeForByteSlice) + byteSliceLength
+	return source[fromOffset+uint32(reservedSizeForByteSlice) : endOffset], endOffset
 }
 
 func EncodeUint8(source uint8, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
@@ -38,7 +101,7 @@ func EncodeUint8(source uint8, destination []byte, destinationStartingOffset uin
 	return uint8Size
 }
 
-func DecodeUint8(source []byte, fromOffset uint16) (uint8, EndOffset) {
+func DecodeUint8(source []byte, fromOffset uint32) (uint8, EndOffset) {
 	return source[fromOffset], fromOffset + EndOffset(uint8Size)
 }
 
@@ -47,7 +110,7 @@ func EncodeUint16(source uint16, destination []byte, destinationStartingOffset u
 	return uint16Size
 }
 
-func DecodeUint16(source []byte, fromOffset uint16) (uint16, EndOffset) {
+func DecodeUint16(source []byte, fromOffset uint32) (uint16, EndOffset) {
 	return binary.LittleEndian.Uint16(source[fromOffset:]), fromOffset + EndOffset(uint16Size)
 }
 
@@ -56,7 +119,7 @@ func EncodeUint32(source uint32, destination []byte, destinationStartingOffset u
 	return uint32Size
 }
 
-func DecodeUint32(source []byte, fromOffset uint16) (uint32, EndOffset) {
+func DecodeUint32(source []byte, fromOffset uint32) (uint32, EndOffset) {
 	return binary.LittleEndian.Uint32(source[fromOffset:]), fromOffset + EndOffset(uint32Size)
 }
 
@@ -65,6 +128,115 @@ func EncodeUint64(source uint64, destination []byte, destinationStartingOffset u
 	return uint64Size
 }
 
-func DecodeUint64(source []byte, fromOffset uint16) (uint64, EndOffset) {
+func DecodeUint64(source []byte, fromOffset uint32) (uint64, EndOffset) {
 	return binary.LittleEndian.Uint64(source[fromOffset:]), fromOffset + EndOffset(uint64Size)
 }
+
+func EncodeInt8(source int8, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	return EncodeUint8(uint8(source), destination, destinationStartingOffset)
+}
+
+func DecodeInt8(source []byte, fromOffset uint32) (int8, EndOffset) {
+	decoded, endOffset := DecodeUint8(source, fromOffset)
+	return int8(decoded), endOffset
+}
+
+func EncodeInt16(source int16, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	return EncodeUint16(uint16(source), destination, destinationStartingOffset)
+}
+
+func DecodeInt16(source []byte, fromOffset uint32) (int16, EndOffset) {
+	decoded, endOffset := DecodeUint16(source, fromOffset)
+	return int16(decoded), endOffset
+}
+
+func EncodeInt32(source int32, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	return EncodeUint32(uint32(source), destination, destinationStartingOffset)
+}
+
+func DecodeInt32(source []byte, fromOffset uint32) (int32, EndOffset) {
+	decoded, endOffset := DecodeUint32(source, fromOffset)
+	return int32(decoded), endOffset
+}
+
+func EncodeInt64(source int64, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	return EncodeUint64(uint64(source), destination, destinationStartingOffset)
+}
+
+func DecodeInt64(source []byte, fromOffset uint32) (int64, EndOffset) {
+	decoded, endOffset := DecodeUint64(source, fromOffset)
+	return int64(decoded), endOffset
+}
+
+func EncodeFloat32(source float32, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	return EncodeUint32(math.Float32bits(source), destination, destinationStartingOffset)
+}
+
+func DecodeFloat32(source []byte, fromOffset uint32) (float32, EndOffset) {
+	decoded, endOffset := DecodeUint32(source, fromOffset)
+	return math.Float32frombits(decoded), endOffset
+}
+
+func EncodeFloat64(source float64, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	return EncodeUint64(math.Float64bits(source), destination, destinationStartingOffset)
+}
+
+func DecodeFloat64(source []byte, fromOffset uint32) (float64, EndOffset) {
+	decoded, endOffset := DecodeUint64(source, fromOffset)
+	return math.Float64frombits(decoded), endOffset
+}
+
+func EncodeBool(source bool, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	if source {
+		return EncodeUint8(1, destination, destinationStartingOffset)
+	}
+	return EncodeUint8(0, destination, destinationStartingOffset)
+}
+
+func DecodeBool(source []byte, fromOffset uint32) (bool, EndOffset) {
+	decoded, endOffset := DecodeUint8(source, fromOffset)
+	return decoded != 0, endOffset
+}
+
+// EncodeDate encodes the calendar date of the source (in its own location) as the number of days since
+// the Unix epoch.
+func EncodeDate(source time.Time, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	year, month, day := source.Date()
+	days := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / secondsInADay
+	return EncodeInt32(int32(days), destination, destinationStartingOffset)
+}
+
+// DecodeDate decodes the date as midnight UTC.
+func DecodeDate(source []byte, fromOffset uint32) (time.Time, EndOffset) {
+	days, endOffset := DecodeInt32(source, fromOffset)
+	return time.Unix(int64(days)*secondsInADay, 0).UTC(), endOffset
+}
+
+// EncodeTimestamp encodes the source as nanoseconds since the Unix epoch, followed by the offset (in seconds) of
+// its time zone from UTC.
+func EncodeTimestamp(source time.Time, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	_, zoneOffset := source.Zone()
+	EncodeInt64(source.UnixNano(), destination, destinationStartingOffset)
+	EncodeInt32(int32(zoneOffset), destination, destinationStartingOffset+uint64Size)
+	return timestampSize
+}
+
+// DecodeTimestamp decodes the timestamp in a fixed time zone with the encoded offset; the name of the
+// original time zone is not preserved.
+func DecodeTimestamp(source []byte, fromOffset uint32) (time.Time, EndOffset) {
+	nanos, endOffset := DecodeInt64(source, fromOffset)
+	zoneOffset, endOffset := DecodeInt32(source, endOffset)
+	return time.Unix(0, nanos).In(time.FixedZone("", int(zoneOffset))), endOffset
+}
+
+func EncodeDecimal(source Decimal, destination []byte, destinationStartingOffset uint) BytesNeededForEncoding {
+	EncodeInt64(source.Unscaled, destination, destinationStartingOffset)
+	EncodeUint8(source.Scale, destination, destinationStartingOffset+uint64Size)
+	return decimalSize
+}
+
+func DecodeDecimal(source []byte, fromOffset uint32) (Decimal, EndOffset) {
+	unscaled, endOffset := DecodeInt64(source, fromOffset)
+	scale, endOffset := DecodeUint8(source, endOffset)
+	return NewDecimal(unscaled, scale), endOffset
+}
@@ -2,11 +2,20 @@ package gorel
 
 import (
 	"github.com/stretchr/testify/assert"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestBytesNeededForEncodingAByteSlice(t *testing.T) {
-	assert.Equal(t, uint(6), BytesNeededForEncodingAByteSlice([]byte("raft")))
+	assert.Equal(t, uint(8), BytesNeededForEncodingAByteSlice([]byte("raft")))
+}
+
+func TestBytesNeededForEncodingUnsignedIntegers(t *testing.T) {
+	assert.Equal(t, uint(1), BytesNeededForEncodingAnUint8())
+	assert.Equal(t, uint(2), BytesNeededForEncodingAnUint16())
+	assert.Equal(t, uint(4), BytesNeededForEncodingAnUint32())
+	assert.Equal(t, uint(8), BytesNeededForEncodingAnUint64())
 }
 
 func TestEncodeAndDecodeAByteSlice(t *testing.T) {
@@ -60,3 +69,102 @@ func TestEncodeAndDecodeAnUint64(t *testing.T) {
 	assert.Equal(t, uint64(10000), decoded)
 	assert.Equal(t, EndOffset(uint64Size), endOffset)
 }
+
+func TestEncodeAndDecodeSignedIntegers(t *testing.T) {
+	destination := make([]byte, 15)
+
+	EncodeInt8(-8, destination, 0)
+	EncodeInt16(-16, destination, 1)
+	EncodeInt32(-32, destination, 3)
+	EncodeInt64(math.MinInt64, destination, 7)
+
+	decodedInt8, endOffset := DecodeInt8(destination, 0)
+	assert.Equal(t, int8(-8), decodedInt8)
+	assert.Equal(t, EndOffset(1), endOffset)
+
+	decodedInt16, endOffset := DecodeInt16(destination, endOffset)
+	assert.Equal(t, int16(-16), decodedInt16)
+	assert.Equal(t, EndOffset(3), endOffset)
+
+	decodedInt32, endOffset := DecodeInt32(destination, endOffset)
+	assert.Equal(t, int32(-32), decodedInt32)
+	assert.Equal(t, EndOffset(7), endOffset)
+
+	decodedInt64, endOffset := DecodeInt64(destination, endOffset)
+	assert.Equal(t, int64(math.MinInt64), decodedInt64)
+	assert.Equal(t, EndOffset(15), endOffset)
+}
+
+func TestEncodeAndDecodeFloats(t *testing.T) {
+	destination := make([]byte, 12)
+
+	EncodeFloat32(-3.25, destination, 0)
+	EncodeFloat64(math.Pi, destination, 4)
+
+	decodedFloat32, endOffset := DecodeFloat32(destination, 0)
+	assert.Equal(t, float32(-3.25), decodedFloat32)
+	assert.Equal(t, EndOffset(4), endOffset)
+
+	decodedFloat64, endOffset := DecodeFloat64(destination, endOffset)
+	assert.Equal(t, math.Pi, decodedFloat64)
+	assert.Equal(t, EndOffset(12), endOffset)
+}
+
+func TestEncodeAndDecodeBools(t *testing.T) {
+	destination := make([]byte, 2)
+
+	EncodeBool(true, destination, 0)
+	EncodeBool(false, destination, 1)
+
+	decoded, endOffset := DecodeBool(destination, 0)
+	assert.True(t, decoded)
+	assert.Equal(t, EndOffset(1), endOffset)
+
+	decoded, _ = DecodeBool(destination, endOffset)
+	assert.False(t, decoded)
+}
+
+func TestEncodeAndDecodeADate(t *testing.T) {
+	destination := make([]byte, BytesNeededForEncodingADate())
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+
+	EncodeDate(time.Date(2024, time.March, 10, 23, 45, 0, 0, kolkata), destination, 0)
+
+	decoded, endOffset := DecodeDate(destination, 0)
+	assert.Equal(t, time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC), decoded)
+	assert.Equal(t, EndOffset(4), endOffset)
+}
+
+func TestEncodeAndDecodeADateBeforeTheEpoch(t *testing.T) {
+	destination := make([]byte, BytesNeededForEncodingADate())
+
+	EncodeDate(time.Date(1947, time.August, 15, 0, 0, 0, 0, time.UTC), destination, 0)
+
+	decoded, _ := DecodeDate(destination, 0)
+	assert.Equal(t, time.Date(1947, time.August, 15, 0, 0, 0, 0, time.UTC), decoded)
+}
+
+func TestEncodeAndDecodeATimestamp(t *testing.T) {
+	destination := make([]byte, BytesNeededForEncodingATimestamp())
+	kolkata := time.FixedZone("IST", 5*60*60+30*60)
+	timestamp := time.Date(2024, time.March, 10, 23, 45, 10, 500, kolkata)
+
+	EncodeTimestamp(timestamp, destination, 0)
+
+	decoded, endOffset := DecodeTimestamp(destination, 0)
+	assert.True(t, timestamp.Equal(decoded))
+	assert.Equal(t, 23, decoded.Hour())
+	_, zoneOffset := decoded.Zone()
+	assert.Equal(t, 5*60*60+30*60, zoneOffset)
+	assert.Equal(t, EndOffset(12), endOffset)
+}
+
+func TestEncodeAndDecodeADecimal(t *testing.T) {
+	destination := make([]byte, BytesNeededForEncodingADecimal())
+
+	EncodeDecimal(NewDecimal(-12345, 2), destination, 0)
+
+	decoded, endOffset := DecodeDecimal(destination, 0)
+	assert.Equal(t, NewDecimal(-12345, 2), decoded)
+	assert.Equal(t, EndOffset(9), endOffset)
+}
@@ -20,6 +20,10 @@ func (blockId BlockId) offset(blockSize uint) int64 {
 	return int64(blockId.blockNumber * blockSize)
 }
 
+func (blockId BlockId) FileName() string {
+	return blockId.fileName
+}
+
 func (blockId BlockId) BlockNumber() uint {
 	return blockId.blockNumber
 }
@@ -1,20 +1,42 @@
 package file
 
 import (
+	"errors"
+	"fmt"
 	"gorel"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
 )
 
-// BlockFileManager
-// TODO: synchronization
+// MaxBlockSize is the largest block size supported by the pages, whose offsets and lengths are encoded as uint32.
+const MaxBlockSize uint = 1 << 30
+
+// MinBlockSize is the smallest block size which holds the page header and the smallest page trailer, the number of
+// offsets of a page along with the number of offsets of a log page.
+var MinBlockSize = uint(PageHeaderSize + 2*reservedSizeForAnOffset)
+
+var InvalidBlockSizeError = errors.New("invalid block size")
+
+// WriteFault is run, with the name of the file, before every write of a block, append of a block and rename of a
+// file. The operation fails without modifying the file if the fault returns an error, which lets the tests simulate a
+// crash at any write.
+type WriteFault func(fileName string) error
+
 type BlockFileManager struct {
 	dbDirectory string
 	blockSize   uint
 	openFiles   map[string]*os.File
+	pageBuffers *PageBufferPool
+	writeFault  WriteFault
+	lock        sync.Mutex
 }
 
 func NewBlockFileManager(dbDirectory string, blockSize uint) (*BlockFileManager, error) {
+	if blockSize < MinBlockSize || blockSize > MaxBlockSize {
+		return nil, fmt.Errorf("%w: %v, block size must be between %v and %v bytes", InvalidBlockSizeError, blockSize, MinBlockSize, MaxBlockSize)
+	}
 	if _, err := os.Stat(dbDirectory); os.IsNotExist(err) {
 		if err := os.MkdirAll(dbDirectory, os.ModePerm); err != nil {
 			return nil, err
@@ -25,26 +47,35 @@ func NewBlockFileManager(dbDirectory string, blockSize uint) (*BlockFileManager,
 		dbDirectory: dbDirectory,
 		blockSize:   blockSize,
 		openFiles:   make(map[string]*os.File),
+		pageBuffers: NewPageBufferPool(blockSize),
 	}, nil
 }
 
+// ReadInto reads the block into a buffer from the PageBufferPool, which the page takes over by decoding it.
 func (fileManager *BlockFileManager) ReadInto(blockId BlockId, page gorel.Page) error {
-	buffer := make([]byte, fileManager.blockSize)
+	buffer := fileManager.pageBuffers.Get()
 	err := fileManager.seekWithinFileAndRun(blockId, func(file *os.File) error {
-		if _, err := file.Read(buffer); err != nil {
+		if _, err := io.ReadFull(file, buffer); err != nil {
 			return err
 		}
 		return nil
 	})
 	if err != nil {
+		fileManager.pageBuffers.Put(buffer)
+		return err
+	}
+	if err := page.DecodeFrom(buffer); err != nil {
+		fileManager.pageBuffers.Put(buffer)
 		return err
 	}
-	page.DecodeFrom(buffer)
 	return nil
 }
 
 func (fileManager *BlockFileManager) Write(blockId BlockId, page gorel.Page) error {
 	return fileManager.seekWithinFileAndRun(blockId, func(file *os.File) error {
+		if err := fileManager.runWriteFault(blockId.fileName); err != nil {
+			return err
+		}
 		if _, err := file.Write(page.Content()); err != nil {
 			return err
 		}
@@ -53,7 +84,13 @@ func (fileManager *BlockFileManager) Write(blockId BlockId, page gorel.Page) err
 }
 
 func (fileManager *BlockFileManager) AppendEmptyBlock(fileName string) (BlockId, error) {
-	newBlockNumber, err := fileManager.NumberOfBlocks(fileName)
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
+	if err := fileManager.runWriteFault(fileName); err != nil {
+		return BlockId{}, err
+	}
+	newBlockNumber, err := fileManager.numberOfBlocks(fileName)
 	if err != nil {
 		return BlockId{}, err
 	}
@@ -72,7 +109,35 @@ func (fileManager *BlockFileManager) AppendEmptyBlock(fileName string) (BlockId,
 	return blockId, nil
 }
 
+// Rename renames the file, replacing the file with the new name if it exists.
+func (fileManager *BlockFileManager) Rename(fileName string, newFileName string) error {
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
+	if err := fileManager.runWriteFault(newFileName); err != nil {
+		return err
+	}
+	fileManager.closeFile(fileName)
+	fileManager.closeFile(newFileName)
+	return os.Rename(filepath.Join(fileManager.dbDirectory, fileName), filepath.Join(fileManager.dbDirectory, newFileName))
+}
+
+// Remove removes the file, if it exists.
+func (fileManager *BlockFileManager) Remove(fileName string) error {
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
+	fileManager.closeFile(fileName)
+	if err := os.Remove(filepath.Join(fileManager.dbDirectory, fileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 func (fileManager *BlockFileManager) Close() {
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
 	for _, file := range fileManager.openFiles {
 		if file != nil {
 			_ = file.Close()
@@ -80,11 +145,32 @@ func (fileManager *BlockFileManager) Close() {
 	}
 }
 
+// SetWriteFault sets the fault which is run before every write, or removes it if the fault is nil.
+func (fileManager *BlockFileManager) SetWriteFault(fault WriteFault) {
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
+	fileManager.writeFault = fault
+}
+
 func (fileManager *BlockFileManager) BlockSize() uint {
 	return fileManager.blockSize
 }
 
+// PageBuffers returns the pool of the buffers that ReadInto reads the blocks into. A page may return the buffer it
+// no longer uses to the pool.
+func (fileManager *BlockFileManager) PageBuffers() *PageBufferPool {
+	return fileManager.pageBuffers
+}
+
 func (fileManager *BlockFileManager) NumberOfBlocks(fileName string) (int64, error) {
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
+	return fileManager.numberOfBlocks(fileName)
+}
+
+func (fileManager *BlockFileManager) numberOfBlocks(fileName string) (int64, error) {
 	file, err := fileManager.getOrCreateFile(fileName)
 	if err != nil {
 		return 0, err
@@ -97,6 +183,9 @@ func (fileManager *BlockFileManager) NumberOfBlocks(fileName string) (int64, err
 }
 
 func (fileManager *BlockFileManager) seekWithinFileAndRun(blockId BlockId, block func(*os.File) error) error {
+	fileManager.lock.Lock()
+	defer fileManager.lock.Unlock()
+
 	file, err := fileManager.getOrCreateFile(blockId.fileName)
 	if err != nil {
 		return err
@@ -107,6 +196,20 @@ func (fileManager *BlockFileManager) seekWithinFileAndRun(blockId BlockId, block
 	return block(file)
 }
 
+func (fileManager *BlockFileManager) runWriteFault(fileName string) error {
+	if fileManager.writeFault == nil {
+		return nil
+	}
+	return fileManager.writeFault(fileName)
+}
+
+func (fileManager *BlockFileManager) closeFile(fileName string) {
+	if file, ok := fileManager.openFiles[fileName]; ok {
+		_ = file.Close()
+		delete(fileManager.openFiles, fileName)
+	}
+}
+
 func (fileManager *BlockFileManager) getOrCreateFile(fileName string) (*os.File, error) {
 	file, ok := fileManager.openFiles[fileName]
 	if ok {
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"gorel"
 	"os"
@@ -21,8 +22,9 @@ func newTestPage(blockSize uint) *testPage {
 	}
 }
 
-func (page *testPage) DecodeFrom(buffer []byte) {
+func (page *testPage) DecodeFrom(buffer []byte) error {
 	page.buffer = buffer
+	return nil
 }
 
 func (page *testPage) add(buffer []byte) {
@@ -30,7 +32,7 @@ func (page *testPage) add(buffer []byte) {
 	page.currentWriteOffset += numberOfBytesForEncoding
 }
 
-func (page *testPage) getBytes(offset uint16) []byte {
+func (page *testPage) getBytes(offset uint32) []byte {
 	decoded, _ := gorel.DecodeByteSlice(page.buffer, offset)
 	return decoded
 }
@@ -168,3 +170,170 @@ func TestAppendACoupleOfEmptyBlocksUsingBlockFileManager(t *testing.T) {
 
 	assert.Equal(t, uint(1), blockId.blockNumber)
 }
+
+func TestAttemptToCreateABlockFileManagerWithAnInvalidBlockSize(t *testing.T) {
+	_, err := NewBlockFileManager(".", 0)
+	assert.ErrorIs(t, err, InvalidBlockSizeError)
+
+	_, err = NewBlockFileManager(".", MaxBlockSize+1)
+	assert.ErrorIs(t, err, InvalidBlockSizeError)
+
+	_, err = NewBlockFileManager(".", PageHeaderSize)
+	assert.ErrorIs(t, err, InvalidBlockSizeError)
+
+	_, err = NewBlockFileManager(".", MinBlockSize-1)
+	assert.ErrorIs(t, err, InvalidBlockSizeError)
+}
+
+func TestCreateABlockFileManagerWithTheMinimumBlockSize(t *testing.T) {
+	fileManager, err := NewBlockFileManager(".", MinBlockSize)
+	assert.Nil(t, err)
+	assert.Equal(t, MinBlockSize, fileManager.BlockSize())
+	fileManager.Close()
+}
+
+func TestWriteAndReadALargeValueInABlockOfOneMebibyte(t *testing.T) {
+	const largeBlockSize = 1 << 20
+	fileManager, err := NewBlockFileManager(".", largeBlockSize)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	value := make([]byte, 200_000)
+	for index := range value {
+		value[index] = byte(index)
+	}
+	page := newTestPage(largeBlockSize)
+	page.add(value)
+
+	blockId := NewBlockId(t.Name(), 1)
+	assert.Nil(t, fileManager.Write(blockId, page))
+
+	readPage := newTestPage(largeBlockSize)
+	assert.Nil(t, fileManager.ReadInto(blockId, readPage))
+	assert.Equal(t, value, readPage.getBytes(0))
+}
+
+func BenchmarkReadABlock(b *testing.B) {
+	fileManager, err := NewBlockFileManager(".", blockSize)
+	assert.Nil(b, err)
+
+	fileName := b.Name()
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+	}()
+
+	blockId, err := fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(b, err)
+
+	page := newTestPage(blockSize)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for iteration := 0; iteration < b.N; iteration++ {
+		previousBuffer := page.buffer
+		if err := fileManager.ReadInto(blockId, page); err != nil {
+			b.Fatal(err)
+		}
+		fileManager.PageBuffers().Put(previousBuffer)
+	}
+}
+
+func TestRenameAFileOverAnotherFile(t *testing.T) {
+	fileManager, err := NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	otherFileName := t.Name() + "_other"
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(otherFileName)
+	}()
+
+	page := newTestPage(blockSize)
+	page.add([]byte("RocksDB"))
+	assert.Nil(t, fileManager.Write(NewBlockId(otherFileName, 0), page))
+
+	_, err = fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+	_, err = fileManager.AppendEmptyBlock(fileName)
+	assert.Nil(t, err)
+
+	assert.Nil(t, fileManager.Rename(otherFileName, fileName))
+
+	numberOfBlocks, err := fileManager.NumberOfBlocks(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), numberOfBlocks)
+
+	readPage := &testPage{}
+	assert.Nil(t, fileManager.ReadInto(NewBlockId(fileName, 0), readPage))
+	assert.Equal(t, "RocksDB", string(readPage.getBytes(0)))
+
+	_, err = os.Stat(otherFileName)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRemoveAFile(t *testing.T) {
+	fileManager, err := NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	_, err = fileManager.AppendEmptyBlock(t.Name())
+	assert.Nil(t, err)
+
+	assert.Nil(t, fileManager.Remove(t.Name()))
+	_, err = os.Stat(t.Name())
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, fileManager.Remove(t.Name()))
+}
+
+func TestFailTheWritesWithAWriteFault(t *testing.T) {
+	fileManager, err := NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	fileName := t.Name()
+	otherFileName := t.Name() + "_other"
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(fileName)
+		_ = os.Remove(otherFileName)
+	}()
+
+	page := newTestPage(blockSize)
+	page.add([]byte("RocksDB"))
+	assert.Nil(t, fileManager.Write(NewBlockId(fileName, 0), page))
+
+	crashError := errors.New("crash")
+	var faultedFileNames []string
+	fileManager.SetWriteFault(func(fileName string) error {
+		faultedFileNames = append(faultedFileNames, fileName)
+		return crashError
+	})
+	otherPage := newTestPage(blockSize)
+	otherPage.add([]byte("PebbleDB"))
+	assert.ErrorIs(t, fileManager.Write(NewBlockId(fileName, 0), otherPage), crashError)
+	_, err = fileManager.AppendEmptyBlock(fileName)
+	assert.ErrorIs(t, err, crashError)
+	assert.ErrorIs(t, fileManager.Rename(otherFileName, fileName), crashError)
+	assert.Equal(t, []string{fileName, fileName, fileName}, faultedFileNames)
+
+	readPage := &testPage{}
+	assert.Nil(t, fileManager.ReadInto(NewBlockId(fileName, 0), readPage))
+	assert.Equal(t, "RocksDB", string(readPage.getBytes(0)))
+	numberOfBlocks, err := fileManager.NumberOfBlocks(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), numberOfBlocks)
+
+	fileManager.SetWriteFault(nil)
+	assert.Nil(t, fileManager.Write(NewBlockId(fileName, 0), otherPage))
+}
@@ -0,0 +1,36 @@
+package file
+
+import "sync"
+
+// PageBufferPool reuses the block sized buffers of the pages, so that reading a block does not allocate a buffer
+// once the pool is warmed up.
+type PageBufferPool struct {
+	blockSize uint
+	pool      sync.Pool
+}
+
+func NewPageBufferPool(blockSize uint) *PageBufferPool {
+	return &PageBufferPool{
+		blockSize: blockSize,
+		pool: sync.Pool{
+			New: func() any {
+				buffer := make([]byte, blockSize)
+				return &buffer
+			},
+		},
+	}
+}
+
+// Get returns a buffer of the block size. The content of the buffer is undefined.
+func (bufferPool *PageBufferPool) Get() []byte {
+	return *bufferPool.pool.Get().(*[]byte)
+}
+
+// Put returns the buffer to the pool, ignoring a buffer which is not of the block size. The buffer must not be used
+// after it is returned.
+func (bufferPool *PageBufferPool) Put(buffer []byte) {
+	if uint(len(buffer)) != bufferPool.blockSize {
+		return
+	}
+	bufferPool.pool.Put(&buffer)
+}
@@ -0,0 +1,28 @@
+package file
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetABufferOfTheBlockSizeFromThePageBufferPool(t *testing.T) {
+	bufferPool := NewPageBufferPool(blockSize)
+	assert.Equal(t, blockSize, len(bufferPool.Get()))
+}
+
+func TestPutABufferBackInThePageBufferPool(t *testing.T) {
+	bufferPool := NewPageBufferPool(blockSize)
+	buffer := bufferPool.Get()
+	bufferPool.Put(buffer)
+
+	assert.Equal(t, blockSize, len(bufferPool.Get()))
+}
+
+func TestIgnoreABufferOfAnotherSizeInThePageBufferPool(t *testing.T) {
+	bufferPool := NewPageBufferPool(blockSize)
+	bufferPool.Put(make([]byte, 10))
+
+	for attempt := 0; attempt < 10; attempt++ {
+		assert.Equal(t, blockSize, len(bufferPool.Get()))
+	}
+}
@@ -0,0 +1,115 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// PageType tells the pages of the data, log and index files apart.
+type PageType uint8
+
+const (
+	PageTypeData  PageType = 1
+	PageTypeLog   PageType = 2
+	PageTypeIndex PageType = 3
+)
+
+const (
+	// PageMagic is "GREL" in little endian, and marks a block written by a page that has a header.
+	PageMagic uint32 = 0x4c455247
+	// PageFormatVersion is the version of the layout of the pages.
+	PageFormatVersion uint8 = 1
+)
+
+const (
+	magicOffset             = 0
+	versionOffset           = 4
+	pageTypeOffset          = 5
+	flagsOffset             = 6
+	logSequenceNumberOffset = 8
+
+	// PageHeaderSize is the number of bytes at the beginning of a page which are used by its header.
+	PageHeaderSize = 16
+)
+
+var InvalidPageHeaderError = errors.New("invalid page header")
+
+func (pageType PageType) AsString() string {
+	switch pageType {
+	case PageTypeData:
+		return "data"
+	case PageTypeLog:
+		return "log"
+	case PageTypeIndex:
+		return "index"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(pageType))
+}
+
+// PageHeader reads and writes the fixed size header at the beginning of the buffer of a page: magic (uint32),
+// format version (uint8), page type (uint8), flags (uint16) and page LSN (uint64).
+type PageHeader struct {
+	buffer []byte
+}
+
+func PageHeaderOf(buffer []byte) PageHeader {
+	return PageHeader{buffer: buffer[:PageHeaderSize]}
+}
+
+// Format writes a header of the page type, with no flags and a zero page LSN.
+func (header PageHeader) Format(pageType PageType) {
+	clear(header.buffer)
+	binary.LittleEndian.PutUint32(header.buffer[magicOffset:], PageMagic)
+	header.buffer[versionOffset] = PageFormatVersion
+	header.buffer[pageTypeOffset] = uint8(pageType)
+}
+
+// IsFormatted returns false for a block which was appended, but never written by a page.
+func (header PageHeader) IsFormatted() bool {
+	return binary.LittleEndian.Uint32(header.buffer[magicOffset:]) != 0
+}
+
+// Validate returns InvalidPageHeaderError if the magic or the version is unknown, or if the page type is not one of
+// the expected page types.
+func (header PageHeader) Validate(expectedPageTypes ...PageType) error {
+	if magic := binary.LittleEndian.Uint32(header.buffer[magicOffset:]); magic != PageMagic {
+		return fmt.Errorf("%w: unknown magic %#x", InvalidPageHeaderError, magic)
+	}
+	if version := header.Version(); version != PageFormatVersion {
+		return fmt.Errorf("%w: unsupported format version %d", InvalidPageHeaderError, version)
+	}
+	if !slices.Contains(expectedPageTypes, header.PageType()) {
+		return fmt.Errorf("%w: unexpected page type %s", InvalidPageHeaderError, header.PageType().AsString())
+	}
+	return nil
+}
+
+func (header PageHeader) Version() uint8 {
+	return header.buffer[versionOffset]
+}
+
+func (header PageHeader) PageType() PageType {
+	return PageType(header.buffer[pageTypeOffset])
+}
+
+func (header PageHeader) SetPageType(pageType PageType) {
+	header.buffer[pageTypeOffset] = uint8(pageType)
+}
+
+func (header PageHeader) Flags() uint16 {
+	return binary.LittleEndian.Uint16(header.buffer[flagsOffset:])
+}
+
+func (header PageHeader) SetFlags(flags uint16) {
+	binary.LittleEndian.PutUint16(header.buffer[flagsOffset:], flags)
+}
+
+func (header PageHeader) LogSequenceNumber() uint64 {
+	return binary.LittleEndian.Uint64(header.buffer[logSequenceNumberOffset:])
+}
+
+func (header PageHeader) SetLogSequenceNumber(logSequenceNumber uint64) {
+	binary.LittleEndian.PutUint64(header.buffer[logSequenceNumberOffset:], logSequenceNumber)
+}
@@ -0,0 +1,62 @@
+package file
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFormatAPageHeader(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	header := PageHeaderOf(buffer)
+	assert.False(t, header.IsFormatted())
+
+	header.Format(PageTypeIndex)
+
+	assert.True(t, header.IsFormatted())
+	assert.Equal(t, PageFormatVersion, header.Version())
+	assert.Equal(t, PageTypeIndex, header.PageType())
+	assert.Equal(t, uint16(0), header.Flags())
+	assert.Equal(t, uint64(0), header.LogSequenceNumber())
+	assert.Nil(t, header.Validate(PageTypeData, PageTypeIndex))
+}
+
+func TestSetTheFieldsOfAPageHeader(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	PageHeaderOf(buffer).Format(PageTypeData)
+
+	header := PageHeaderOf(buffer)
+	header.SetFlags(0x3)
+	header.SetLogSequenceNumber(1024)
+	header.SetPageType(PageTypeIndex)
+
+	decodedHeader := PageHeaderOf(buffer)
+	assert.Equal(t, uint16(0x3), decodedHeader.Flags())
+	assert.Equal(t, uint64(1024), decodedHeader.LogSequenceNumber())
+	assert.Equal(t, PageTypeIndex, decodedHeader.PageType())
+}
+
+func TestValidateAPageHeaderWithAnUnexpectedPageType(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	header := PageHeaderOf(buffer)
+	header.Format(PageTypeLog)
+
+	err := header.Validate(PageTypeData)
+	assert.ErrorIs(t, err, InvalidPageHeaderError)
+	assert.Equal(t, "invalid page header: unexpected page type log", err.Error())
+}
+
+func TestValidateAPageHeaderWithAnUnknownMagic(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	copy(buffer, "RocksDB is an LSM-based storage engine")
+
+	assert.ErrorIs(t, PageHeaderOf(buffer).Validate(PageTypeData), InvalidPageHeaderError)
+}
+
+func TestValidateAPageHeaderWithAnUnsupportedVersion(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	header := PageHeaderOf(buffer)
+	header.Format(PageTypeData)
+	buffer[versionOffset] = PageFormatVersion + 1
+
+	assert.ErrorIs(t, header.Validate(PageTypeData), InvalidPageHeaderError)
+}
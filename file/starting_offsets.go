@@ -5,10 +5,13 @@ import (
 	"unsafe"
 )
 
-var reservedSizeForAnOffset = int(unsafe.Sizeof(uint16(0)))
+var reservedSizeForAnOffset = int(unsafe.Sizeof(uint32(0)))
 
+// StartingOffsets holds the starting offsets of the fields of a page. StartingOffsets that are lazily decoded read
+// the offsets straight from the encoded bytes, and decode them only when an offset is appended or set.
 type StartingOffsets struct {
-	offsets []uint16
+	offsets []uint32
+	encoded []byte
 }
 
 func NewStartingOffsets() *StartingOffsets {
@@ -18,42 +21,71 @@ func NewStartingOffsets() *StartingOffsets {
 func DecodeStartingOffsetsFrom(buffer []byte) *StartingOffsets {
 	startingOffsets := NewStartingOffsets()
 	for len(buffer) > 0 {
-		startingOffsets.offsets = append(startingOffsets.offsets, binary.LittleEndian.Uint16(buffer[:]))
+		startingOffsets.offsets = append(startingOffsets.offsets, binary.LittleEndian.Uint32(buffer[:]))
 		buffer = buffer[reservedSizeForAnOffset:]
 	}
 	return startingOffsets
 }
 
-func (startingOffsets *StartingOffsets) Append(offset uint16) {
+// LazilyDecodeStartingOffsetsFrom returns StartingOffsets which refer to the buffer until they are modified, so the
+// buffer must not be modified in the meantime.
+func LazilyDecodeStartingOffsetsFrom(buffer []byte) *StartingOffsets {
+	return &StartingOffsets{encoded: buffer}
+}
+
+func (startingOffsets *StartingOffsets) Append(offset uint32) {
+	startingOffsets.decode()
 	startingOffsets.offsets = append(startingOffsets.offsets, offset)
 }
 
+// Encode returns the encoded bytes which lazily decoded StartingOffsets refer to, if they are not modified.
 func (startingOffsets *StartingOffsets) Encode() []byte {
+	if startingOffsets.encoded != nil {
+		return startingOffsets.encoded
+	}
 	buffer := make([]byte, len(startingOffsets.offsets)*reservedSizeForAnOffset)
 	offsetIndex := 0
 	for _, offset := range startingOffsets.offsets {
-		binary.LittleEndian.PutUint16(buffer[offsetIndex:], offset)
+		binary.LittleEndian.PutUint32(buffer[offsetIndex:], offset)
 		offsetIndex += reservedSizeForAnOffset
 	}
 	return buffer
 }
 
 func (startingOffsets *StartingOffsets) Length() int {
+	if startingOffsets.encoded != nil {
+		return len(startingOffsets.encoded) / reservedSizeForAnOffset
+	}
 	return len(startingOffsets.offsets)
 }
 
-func (startingOffsets *StartingOffsets) OffsetAtIndex(index int) uint16 {
+func (startingOffsets *StartingOffsets) OffsetAtIndex(index int) uint32 {
+	if startingOffsets.encoded != nil {
+		return binary.LittleEndian.Uint32(startingOffsets.encoded[index*reservedSizeForAnOffset:])
+	}
 	return startingOffsets.offsets[index]
 }
 
+func (startingOffsets *StartingOffsets) SetOffsetAtIndex(index int, offset uint32) {
+	startingOffsets.decode()
+	startingOffsets.offsets[index] = offset
+}
+
 func (startingOffsets *StartingOffsets) SizeInBytesForAnOffset() int {
 	return reservedSizeForAnOffset
 }
 
 func (startingOffsets *StartingOffsets) SizeUsedInBytes() int {
-	return reservedSizeForAnOffset * len(startingOffsets.offsets)
+	return reservedSizeForAnOffset * startingOffsets.Length()
+}
+
+func (startingOffsets *StartingOffsets) decode() {
+	if startingOffsets.encoded != nil {
+		startingOffsets.offsets = DecodeStartingOffsetsFrom(startingOffsets.encoded).offsets
+		startingOffsets.encoded = nil
+	}
 }
 
-func SizeUsedInBytesFor(numberOfOffsets uint16) int {
+func SizeUsedInBytesFor(numberOfOffsets uint32) int {
 	return reservedSizeForAnOffset * int(numberOfOffsets)
 }
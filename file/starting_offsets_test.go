@@ -25,7 +25,7 @@ func TestEncodeAndDecodeASingleStartingOffset(t *testing.T) {
 
 	decodedStartingOffsets := DecodeStartingOffsetsFrom(encoded)
 	assert.Equal(t, 1, decodedStartingOffsets.Length())
-	assert.Equal(t, uint16(20), decodedStartingOffsets.offsets[0])
+	assert.Equal(t, uint32(20), decodedStartingOffsets.offsets[0])
 }
 
 func TestEncodeAndDecodeAFewStartingOffsets(t *testing.T) {
@@ -38,9 +38,9 @@ func TestEncodeAndDecodeAFewStartingOffsets(t *testing.T) {
 
 	decodedStartingOffsets := DecodeStartingOffsetsFrom(encoded)
 	assert.Equal(t, 3, decodedStartingOffsets.Length())
-	assert.Equal(t, uint16(20), decodedStartingOffsets.offsets[0])
-	assert.Equal(t, uint16(400), decodedStartingOffsets.offsets[1])
-	assert.Equal(t, uint16(520), decodedStartingOffsets.offsets[2])
+	assert.Equal(t, uint32(20), decodedStartingOffsets.offsets[0])
+	assert.Equal(t, uint32(400), decodedStartingOffsets.offsets[1])
+	assert.Equal(t, uint32(520), decodedStartingOffsets.offsets[2])
 }
 
 func TestSizeUsedInBytes(t *testing.T) {
@@ -49,5 +49,46 @@ func TestSizeUsedInBytes(t *testing.T) {
 	startingOffsets.Append(400)
 	startingOffsets.Append(800)
 
-	assert.Equal(t, 6, startingOffsets.SizeUsedInBytes())
+	assert.Equal(t, 12, startingOffsets.SizeUsedInBytes())
+}
+
+func TestSetOffsetAtIndex(t *testing.T) {
+	startingOffsets := NewStartingOffsets()
+	startingOffsets.Append(20)
+	startingOffsets.Append(400)
+
+	startingOffsets.SetOffsetAtIndex(0, 800)
+
+	assert.Equal(t, uint32(800), startingOffsets.OffsetAtIndex(0))
+	assert.Equal(t, uint32(400), startingOffsets.OffsetAtIndex(1))
+}
+
+func TestLazilyDecodeStartingOffsets(t *testing.T) {
+	startingOffsets := NewStartingOffsets()
+	startingOffsets.Append(20)
+	startingOffsets.Append(400)
+	encoded := startingOffsets.Encode()
+
+	decodedStartingOffsets := LazilyDecodeStartingOffsetsFrom(encoded)
+
+	assert.Nil(t, decodedStartingOffsets.offsets)
+	assert.Equal(t, 2, decodedStartingOffsets.Length())
+	assert.Equal(t, uint32(400), decodedStartingOffsets.OffsetAtIndex(1))
+	assert.Equal(t, 8, decodedStartingOffsets.SizeUsedInBytes())
+	assert.Equal(t, encoded, decodedStartingOffsets.Encode())
+}
+
+func TestModifyLazilyDecodedStartingOffsets(t *testing.T) {
+	startingOffsets := NewStartingOffsets()
+	startingOffsets.Append(20)
+	encoded := startingOffsets.Encode()
+
+	decodedStartingOffsets := LazilyDecodeStartingOffsetsFrom(encoded)
+	decodedStartingOffsets.SetOffsetAtIndex(0, 40)
+	decodedStartingOffsets.Append(800)
+
+	assert.Equal(t, 2, decodedStartingOffsets.Length())
+	assert.Equal(t, uint32(40), decodedStartingOffsets.OffsetAtIndex(0))
+	assert.Equal(t, uint32(800), decodedStartingOffsets.OffsetAtIndex(1))
+	assert.Equal(t, []byte{20, 0, 0, 0}, encoded)
 }
@@ -2,8 +2,11 @@ package log
 
 import "gorel/file"
 
+// BackwardLogIterator iterates over the log records from a block to the earliest. An iterator returned by
+// BlockLogManager.BackwardIterator holds back the truncation of the log until it is closed.
 type BackwardLogIterator struct {
 	fileManager     *file.BlockFileManager
+	logManager      *BlockLogManager
 	logPageIterator *BackwardRecordIterator
 	currentBlockId  file.BlockId
 }
@@ -18,6 +21,9 @@ func NewBackwardLogIterator(fileManager *file.BlockFileManager, currentBlockId f
 		return nil, err
 	}
 	iterator.logPageIterator = page.BackwardIterator()
+	if err := iterator.skipExhaustedPages(); err != nil {
+		return nil, err
+	}
 	return iterator, nil
 }
 
@@ -26,11 +32,13 @@ func (iterator *BackwardLogIterator) IsValid() bool {
 }
 
 func (iterator *BackwardLogIterator) Previous() error {
-	if iterator.logPageIterator.IsValid() {
-		iterator.logPageIterator.Previous()
-		return nil
-	}
-	if iterator.currentBlockId.BlockNumber() > 0 {
+	iterator.logPageIterator.Previous()
+	return iterator.skipExhaustedPages()
+}
+
+// skipExhaustedPages moves to the previous blocks until a record is found, or the first block is read.
+func (iterator *BackwardLogIterator) skipExhaustedPages() error {
+	for !iterator.logPageIterator.IsValid() && iterator.currentBlockId.BlockNumber() > 0 {
 		iterator.currentBlockId = iterator.currentBlockId.Previous()
 		page := NewPage(iterator.fileManager.BlockSize())
 		if err := iterator.readBlockInto(iterator.currentBlockId, page); err != nil {
@@ -45,6 +53,18 @@ func (iterator *BackwardLogIterator) Record() []byte {
 	return iterator.logPageIterator.Record()
 }
 
+func (iterator *BackwardLogIterator) LogSequenceNumber() uint {
+	return iterator.logPageIterator.LogSequenceNumber()
+}
+
+// Close releases the iterator, so that the log can be truncated. Closing an iterator twice has no effect.
+func (iterator *BackwardLogIterator) Close() {
+	if iterator.logManager != nil {
+		iterator.logManager.closeIterator()
+		iterator.logManager = nil
+	}
+}
+
 func (iterator *BackwardLogIterator) readBlockInto(blockId file.BlockId, page *Page) error {
 	return iterator.fileManager.ReadInto(blockId, page)
 }
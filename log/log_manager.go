@@ -1,11 +1,22 @@
 package log
 
 import (
+	"errors"
+	"fmt"
 	"gorel"
 	"gorel/file"
+	"sync"
 )
 
-// BlockLogManager TODO: concurrency + persistence of latestLogSequenceNumber
+var RecordTooLargeError = errors.New("log record does not fit in a log block")
+
+// truncatedLogFileSuffix names the temporary file which Truncate writes the remaining log blocks to.
+const truncatedLogFileSuffix = ".truncated"
+
+// BlockLogManager appends the log records to the blocks of the log file. The latestLogSequenceNumber is restored from
+// the header of the last log page which has records: the last block is empty if the log was not flushed after the
+// block was appended. The log is not truncated while a BackwardLogIterator is open, as truncating renumbers the
+// blocks that the iterator reads.
 type BlockLogManager struct {
 	fileManager                *file.BlockFileManager
 	logFile                    string
@@ -13,6 +24,8 @@ type BlockLogManager struct {
 	currentBlockId             file.BlockId
 	latestLogSequenceNumber    uint
 	lastSavedLogSequenceNumber uint
+	openIterators              int
+	lock                       sync.Mutex
 }
 
 func NewBlockLogManager(fileManager *file.BlockFileManager, logFile string) (*BlockLogManager, error) {
@@ -37,41 +50,162 @@ func NewBlockLogManager(fileManager *file.BlockFileManager, logFile string) (*Bl
 		if err := fileManager.ReadInto(blockId, logManager.logPage); err != nil {
 			return nil, err
 		}
+		if logManager.latestLogSequenceNumber, err = logManager.latestLogSequenceNumberUpTo(blockId); err != nil {
+			return nil, err
+		}
+		logManager.lastSavedLogSequenceNumber = logManager.latestLogSequenceNumber
 	}
 	logManager.currentBlockId = blockId
 	return logManager, nil
 }
 
-func (logManager *BlockLogManager) Append(buffer []byte) error {
+// Append adds the record to the log, and returns the log sequence number of the record. It returns
+// RecordTooLargeError if the record does not fit in a log block.
+func (logManager *BlockLogManager) Append(buffer []byte) (uint, error) {
+	logManager.lock.Lock()
+	defer logManager.lock.Unlock()
+
+	if !fitsInAnEmptyPage(logManager.fileManager.BlockSize(), buffer) {
+		return 0, fmt.Errorf("%w: record of %d bytes, block size %d", RecordTooLargeError, len(buffer), logManager.fileManager.BlockSize())
+	}
 	couldAdd := logManager.logPage.Add(buffer)
 	if !couldAdd {
 		if err := logManager.forceFlush(); err != nil {
-			return err
+			return 0, err
 		}
 		blockId, err := logManager.appendNewBlock()
 		if err != nil {
-			return err
+			return 0, err
 		}
 		logManager.currentBlockId = blockId
 		logManager.logPage = NewPage(logManager.fileManager.BlockSize())
 		gorel.Assert(logManager.logPage.Add(buffer), "could not add the bytes to the new log page")
 	}
 	logManager.latestLogSequenceNumber += 1
-	return nil
+	logManager.logPage.setLogSequenceNumber(logManager.latestLogSequenceNumber)
+	return logManager.latestLogSequenceNumber, nil
+}
+
+// MaxRecordSize returns the size of the largest record which Append accepts, the records which fit in a log block.
+func (logManager *BlockLogManager) MaxRecordSize() int {
+	return maxRecordSize(logManager.fileManager.BlockSize())
 }
 
 func (logManager *BlockLogManager) Flush(logSequenceNumber uint) error {
+	logManager.lock.Lock()
+	defer logManager.lock.Unlock()
+
 	if logSequenceNumber >= logManager.lastSavedLogSequenceNumber {
 		return logManager.forceFlush()
 	}
 	return nil
 }
 
+// LatestLogSequenceNumber returns the log sequence number of the latest appended record, or 0 if the log is empty.
+func (logManager *BlockLogManager) LatestLogSequenceNumber() uint {
+	logManager.lock.Lock()
+	defer logManager.lock.Unlock()
+
+	return logManager.latestLogSequenceNumber
+}
+
+// Truncate removes the log blocks whose records all precede the log sequence number. The remaining blocks are
+// written to a temporary file which is renamed over the log file, so a crash leaves either the old log or the
+// truncated one. It does nothing while a BackwardLogIterator is open, and leaves the blocks to a later Truncate.
+func (logManager *BlockLogManager) Truncate(logSequenceNumber uint) error {
+	logManager.lock.Lock()
+	defer logManager.lock.Unlock()
+
+	if logManager.openIterators > 0 {
+		return nil
+	}
+	if err := logManager.forceFlush(); err != nil {
+		return err
+	}
+	firstBlockId, err := logManager.firstBlockWithRecordsFrom(logSequenceNumber)
+	if err != nil || firstBlockId.BlockNumber() == 0 {
+		return err
+	}
+
+	truncatedLogFile := logManager.logFile + truncatedLogFileSuffix
+	if err := logManager.fileManager.Remove(truncatedLogFile); err != nil {
+		return err
+	}
+	page := NewPage(logManager.fileManager.BlockSize())
+	for blockNumber := firstBlockId.BlockNumber(); blockNumber <= logManager.currentBlockId.BlockNumber(); blockNumber++ {
+		if err := logManager.fileManager.ReadInto(file.NewBlockId(logManager.logFile, blockNumber), page); err != nil {
+			return err
+		}
+		truncatedBlockId := file.NewBlockId(truncatedLogFile, blockNumber-firstBlockId.BlockNumber())
+		if err := logManager.fileManager.Write(truncatedBlockId, page); err != nil {
+			return err
+		}
+	}
+	if err := logManager.fileManager.Rename(truncatedLogFile, logManager.logFile); err != nil {
+		return err
+	}
+	logManager.currentBlockId = file.NewBlockId(
+		logManager.logFile,
+		logManager.currentBlockId.BlockNumber()-firstBlockId.BlockNumber(),
+	)
+	return nil
+}
+
+// BackwardIterator returns an iterator from the latest record to the earliest. The iterator must be closed, so that
+// the log can be truncated again.
 func (logManager *BlockLogManager) BackwardIterator() (*BackwardLogIterator, error) {
+	logManager.lock.Lock()
+	defer logManager.lock.Unlock()
+
 	if err := logManager.forceFlush(); err != nil {
 		return nil, err
 	}
-	return NewBackwardLogIterator(logManager.fileManager, logManager.currentBlockId)
+	iterator, err := NewBackwardLogIterator(logManager.fileManager, logManager.currentBlockId)
+	if err != nil {
+		return nil, err
+	}
+	iterator.logManager = logManager
+	logManager.openIterators++
+	return iterator, nil
+}
+
+func (logManager *BlockLogManager) closeIterator() {
+	logManager.lock.Lock()
+	defer logManager.lock.Unlock()
+
+	logManager.openIterators--
+}
+
+// firstBlockWithRecordsFrom walks the log blocks backward, and returns the earliest block whose latest record is not
+// before the log sequence number.
+func (logManager *BlockLogManager) firstBlockWithRecordsFrom(logSequenceNumber uint) (file.BlockId, error) {
+	blockId := logManager.currentBlockId
+	page := NewPage(logManager.fileManager.BlockSize())
+	for blockId.BlockNumber() > 0 {
+		if err := logManager.fileManager.ReadInto(blockId.Previous(), page); err != nil {
+			return file.MissingBlockId, err
+		}
+		if page.LogSequenceNumber() < logSequenceNumber {
+			break
+		}
+		blockId = blockId.Previous()
+	}
+	return blockId, nil
+}
+
+// latestLogSequenceNumberUpTo walks the log blocks backward from the block in the logPage, and returns the log
+// sequence number of the first page which has records, or 0 if no page has.
+func (logManager *BlockLogManager) latestLogSequenceNumberUpTo(blockId file.BlockId) (uint, error) {
+	logSequenceNumber := logManager.logPage.LogSequenceNumber()
+	page := NewPage(logManager.fileManager.BlockSize())
+	for logSequenceNumber == 0 && blockId.BlockNumber() > 0 {
+		blockId = blockId.Previous()
+		if err := logManager.fileManager.ReadInto(blockId, page); err != nil {
+			return 0, err
+		}
+		logSequenceNumber = page.LogSequenceNumber()
+	}
+	return logSequenceNumber, nil
 }
 
 func (logManager *BlockLogManager) appendNewBlock() (file.BlockId, error) {
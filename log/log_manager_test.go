@@ -20,7 +20,9 @@ func TestAppendARecordInLogManager(t *testing.T) {
 	logManager, err := NewBlockLogManager(fileManager, fileName)
 
 	assert.Nil(t, err)
-	assert.Nil(t, logManager.Append([]byte("RocksDB is an LSM-based storage engine")))
+	logSequenceNumber, err := logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), logSequenceNumber)
 }
 
 func TestAppendARecordInLogManagerAndIterateOverIt(t *testing.T) {
@@ -36,7 +38,8 @@ func TestAppendARecordInLogManagerAndIterateOverIt(t *testing.T) {
 	logManager, err := NewBlockLogManager(fileManager, fileName)
 
 	assert.Nil(t, err)
-	assert.Nil(t, logManager.Append([]byte("RocksDB is an LSM-based storage engine")))
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
 
 	iterator, err := logManager.BackwardIterator()
 	assert.Nil(t, err)
@@ -61,9 +64,12 @@ func TestAppendAFewRecordsInLogManagerAndIterateOverThem(t *testing.T) {
 	logManager, err := NewBlockLogManager(fileManager, fileName)
 
 	assert.Nil(t, err)
-	assert.Nil(t, logManager.Append([]byte("RocksDB is an LSM-based storage engine")))
-	assert.Nil(t, logManager.Append([]byte("PebbleDB is an LSM-based storage engine")))
-	assert.Nil(t, logManager.Append([]byte("BoltDB is a B+Tree storage engine")))
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("PebbleDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("BoltDB is a B+Tree storage engine"))
+	assert.Nil(t, err)
 
 	iterator, err := logManager.BackwardIterator()
 	assert.Nil(t, err)
@@ -100,9 +106,12 @@ func TestAppendAFewRecordsInLogManagerWithSmallerBlockSizeAndIterateOverThem(t *
 	logManager, err := NewBlockLogManager(fileManager, fileName)
 
 	assert.Nil(t, err)
-	assert.Nil(t, logManager.Append([]byte("RocksDB is an LSM-based storage engine")))
-	assert.Nil(t, logManager.Append([]byte("PebbleDB is an LSM-based storage engine")))
-	assert.Nil(t, logManager.Append([]byte("BoltDB is a B+Tree storage engine")))
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("PebbleDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("BoltDB is a B+Tree storage engine"))
+	assert.Nil(t, err)
 
 	iterator, err := logManager.BackwardIterator()
 	assert.Nil(t, err)
@@ -137,14 +146,17 @@ func TestAppendAFewRecordsInLogManagerAndRecreatesLogManagerInstanceToSimulateRe
 	logManager, err := NewBlockLogManager(fileManager, fileName)
 
 	assert.Nil(t, err)
-	assert.Nil(t, logManager.Append([]byte("RocksDB is an LSM-based storage engine")))
-	assert.Nil(t, logManager.Append([]byte("PebbleDB is an LSM-based storage engine")))
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("PebbleDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
 	assert.Nil(t, logManager.forceFlush())
 
 	reloadedLogManager, err := NewBlockLogManager(fileManager, fileName)
 	assert.Nil(t, err)
 
-	assert.Nil(t, reloadedLogManager.Append([]byte("BoltDB is a B+Tree storage engine")))
+	_, err = reloadedLogManager.Append([]byte("BoltDB is a B+Tree storage engine"))
+	assert.Nil(t, err)
 
 	iterator, err := reloadedLogManager.BackwardIterator()
 	assert.Nil(t, err)
@@ -165,3 +177,249 @@ func TestAppendAFewRecordsInLogManagerAndRecreatesLogManagerInstanceToSimulateRe
 	assert.Nil(t, iterator.Previous())
 	assert.False(t, iterator.IsValid())
 }
+
+func TestRestoreTheLatestLogSequenceNumberFromTheLastLogPage(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	fileName := t.Name()
+	logManager, err := NewBlockLogManager(fileManager, fileName)
+	assert.Nil(t, err)
+
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("PebbleDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	assert.Nil(t, logManager.forceFlush())
+
+	reloadedLogManager, err := NewBlockLogManager(fileManager, fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), reloadedLogManager.latestLogSequenceNumber)
+	assert.Equal(t, uint(2), reloadedLogManager.logPage.LogSequenceNumber())
+}
+
+func TestRestoreTheLatestLogSequenceNumberWhenTheLastLogPageIsEmpty(t *testing.T) {
+	const blockSizeInBytes = 150
+
+	fileManager, err := file.NewBlockFileManager(".", blockSizeInBytes)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	fileName := t.Name()
+	logManager, err := NewBlockLogManager(fileManager, fileName)
+	assert.Nil(t, err)
+
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	_, err = logManager.Append([]byte("PebbleDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	logSequenceNumber, err := logManager.Append([]byte("BoltDB is a B+Tree storage engine"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), logManager.currentBlockId.BlockNumber())
+
+	reloadedLogManager, err := NewBlockLogManager(fileManager, fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, logSequenceNumber-1, reloadedLogManager.LatestLogSequenceNumber())
+
+	logSequenceNumber, err = reloadedLogManager.Append([]byte("BadgerDB is an LSM-based storage engine"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(3), logSequenceNumber)
+}
+
+func TestAttemptToAppendARecordLargerThanALogBlock(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 64)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	_, err = logManager.Append([]byte("RocksDB is an LSM-based key/value storage engine"))
+	assert.ErrorIs(t, err, RecordTooLargeError)
+
+	logSequenceNumber, err := logManager.Append([]byte("BoltDB"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), logSequenceNumber)
+}
+
+func TestAppendRecordsOfTheMaxRecordSize(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 64)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	_, err = logManager.Append(make([]byte, logManager.MaxRecordSize()+1))
+	assert.ErrorIs(t, err, RecordTooLargeError)
+
+	for count := 0; count < 2; count++ {
+		_, err = logManager.Append(make([]byte, logManager.MaxRecordSize()))
+		assert.Nil(t, err)
+	}
+	numberOfBlocks, err := fileManager.NumberOfBlocks(t.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), numberOfBlocks)
+}
+
+func TestIterateOverTheLogSequenceNumbersOfRecordsAcrossBlocks(t *testing.T) {
+	const blockSizeInBytes = 150
+
+	fileManager, err := file.NewBlockFileManager(".", blockSizeInBytes)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	records := []string{
+		"RocksDB is an LSM-based storage engine",
+		"PebbleDB is an LSM-based storage engine",
+		"BoltDB is a B+Tree storage engine",
+		"BadgerDB is an LSM-based storage engine",
+	}
+	for _, record := range records {
+		_, err = logManager.Append([]byte(record))
+		assert.Nil(t, err)
+	}
+
+	iterator, err := logManager.BackwardIterator()
+	assert.Nil(t, err)
+
+	for logSequenceNumber := uint(len(records)); logSequenceNumber > 0; logSequenceNumber-- {
+		assert.True(t, iterator.IsValid())
+		assert.Equal(t, logSequenceNumber, iterator.LogSequenceNumber())
+		assert.Equal(t, records[logSequenceNumber-1], string(iterator.Record()))
+		assert.Nil(t, iterator.Previous())
+	}
+	assert.False(t, iterator.IsValid())
+}
+
+func TestTruncateTheLogBlocksBeforeALogSequenceNumber(t *testing.T) {
+	const blockSizeInBytes = 150
+
+	fileManager, err := file.NewBlockFileManager(".", blockSizeInBytes)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	records := []string{
+		"RocksDB is an LSM-based storage engine",
+		"PebbleDB is an LSM-based storage engine",
+		"BoltDB is a B+Tree storage engine",
+		"BadgerDB is an LSM-based storage engine",
+		"LevelDB is an LSM-based storage engine",
+		"LMDB is a B+Tree storage engine",
+	}
+	for _, record := range records {
+		_, err = logManager.Append([]byte(record))
+		assert.Nil(t, err)
+	}
+	numberOfBlocks, err := fileManager.NumberOfBlocks(t.Name())
+	assert.Nil(t, err)
+
+	assert.Nil(t, logManager.Truncate(5))
+	numberOfBlocksAfterTruncation, err := fileManager.NumberOfBlocks(t.Name())
+	assert.Nil(t, err)
+	assert.Less(t, numberOfBlocksAfterTruncation, numberOfBlocks)
+
+	iterator, err := logManager.BackwardIterator()
+	assert.Nil(t, err)
+
+	logSequenceNumber := uint(len(records))
+	for ; iterator.IsValid(); logSequenceNumber-- {
+		assert.Equal(t, logSequenceNumber, iterator.LogSequenceNumber())
+		assert.Equal(t, records[logSequenceNumber-1], string(iterator.Record()))
+		assert.Nil(t, iterator.Previous())
+	}
+	assert.Less(t, logSequenceNumber, uint(5))
+	assert.Greater(t, logSequenceNumber, uint(0))
+
+	logSequenceNumber, err = logManager.Append([]byte("SQLite is a B+Tree storage engine"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint(len(records)+1), logSequenceNumber)
+	assert.Nil(t, logManager.Flush(logSequenceNumber))
+
+	reloadedLogManager, err := NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, uint(len(records)+1), reloadedLogManager.LatestLogSequenceNumber())
+}
+
+func TestDoNotTruncateTheLogWhileABackwardIteratorIsOpen(t *testing.T) {
+	const blockSizeInBytes = 150
+
+	fileManager, err := file.NewBlockFileManager(".", blockSizeInBytes)
+	assert.Nil(t, err)
+
+	defer func() {
+		fileManager.Close()
+		_ = os.Remove(t.Name())
+	}()
+
+	logManager, err := NewBlockLogManager(fileManager, t.Name())
+	assert.Nil(t, err)
+
+	records := []string{
+		"RocksDB is an LSM-based storage engine",
+		"PebbleDB is an LSM-based storage engine",
+		"BoltDB is a B+Tree storage engine",
+		"BadgerDB is an LSM-based storage engine",
+		"LevelDB is an LSM-based storage engine",
+		"LMDB is a B+Tree storage engine",
+	}
+	for _, record := range records {
+		_, err = logManager.Append([]byte(record))
+		assert.Nil(t, err)
+	}
+	numberOfBlocks, err := fileManager.NumberOfBlocks(t.Name())
+	assert.Nil(t, err)
+
+	iterator, err := logManager.BackwardIterator()
+	assert.Nil(t, err)
+	assert.Nil(t, logManager.Truncate(5))
+
+	numberOfBlocksAfterTruncation, err := fileManager.NumberOfBlocks(t.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, numberOfBlocks, numberOfBlocksAfterTruncation)
+
+	logSequenceNumber := uint(len(records))
+	for ; iterator.IsValid(); logSequenceNumber-- {
+		assert.Equal(t, records[logSequenceNumber-1], string(iterator.Record()))
+		assert.Nil(t, iterator.Previous())
+	}
+	assert.Equal(t, uint(0), logSequenceNumber)
+
+	iterator.Close()
+	iterator.Close()
+	assert.Nil(t, logManager.Truncate(5))
+	numberOfBlocksAfterTruncation, err = fileManager.NumberOfBlocks(t.Name())
+	assert.Nil(t, err)
+	assert.Less(t, numberOfBlocksAfterTruncation, numberOfBlocks)
+}
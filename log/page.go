@@ -7,7 +7,7 @@ import (
 	"unsafe"
 )
 
-var reservedSizeForNumberOfOffsets = int(unsafe.Sizeof(uint16(0)))
+var reservedSizeForNumberOfOffsets = int(unsafe.Sizeof(uint32(0)))
 
 type Page struct {
 	buffer             []byte
@@ -16,20 +16,30 @@ type Page struct {
 }
 
 func NewPage(blockSize uint) *Page {
-	return &Page{
+	page := &Page{
 		buffer:             make([]byte, blockSize),
 		startingOffsets:    file.NewStartingOffsets(),
-		currentWriteOffset: 0,
+		currentWriteOffset: file.PageHeaderSize,
 	}
+	page.header().Format(file.PageTypeLog)
+	return page
 }
 
-func (page *Page) DecodeFrom(buffer []byte) {
-	numberOfOffsets := binary.LittleEndian.Uint16(buffer[len(buffer)-reservedSizeForNumberOfOffsets:])
+// DecodeFrom validates the header, which is formatted if the block was never written.
+func (page *Page) DecodeFrom(buffer []byte) error {
+	header := file.PageHeaderOf(buffer)
+	if !header.IsFormatted() {
+		header.Format(file.PageTypeLog)
+	}
+	if err := header.Validate(file.PageTypeLog); err != nil {
+		return err
+	}
+	numberOfOffsets := binary.LittleEndian.Uint32(buffer[len(buffer)-reservedSizeForNumberOfOffsets:])
 	if numberOfOffsets == 0 {
 		page.buffer = buffer
 		page.startingOffsets = file.NewStartingOffsets()
-		page.currentWriteOffset = 0
-		return
+		page.currentWriteOffset = file.PageHeaderSize
+		return nil
 	}
 	offsetAtWhichEncodedStartingOffsetsAreWritten := len(buffer) - reservedSizeForNumberOfOffsets - file.SizeUsedInBytesFor(numberOfOffsets)
 	startingOffsets := file.DecodeStartingOffsetsFrom(
@@ -39,12 +49,13 @@ func (page *Page) DecodeFrom(buffer []byte) {
 	page.buffer = buffer
 	page.startingOffsets = startingOffsets
 	page.updateCurrentWriteOffset()
+	return nil
 }
 
 func (page *Page) Add(buffer []byte) bool {
 	if page.hasCapacityFor(buffer) {
 		numberOfBytesForEncoding := gorel.EncodeByteSlice(buffer, page.buffer, page.currentWriteOffset)
-		page.startingOffsets.Append(uint16(page.currentWriteOffset))
+		page.startingOffsets.Append(uint32(page.currentWriteOffset))
 		page.moveCurrentWriteOffsetBy(numberOfBytesForEncoding)
 		return true
 	}
@@ -58,13 +69,26 @@ func (page *Page) finish() {
 	offsetToWriteTheEncodedStartingOffsets := len(resultingBuffer) - reservedSizeForNumberOfOffsets - page.startingOffsets.SizeUsedInBytes()
 
 	copy(resultingBuffer[offsetToWriteTheEncodedStartingOffsets:], encodedStartingOffsets)
-	binary.LittleEndian.PutUint16(resultingBuffer[len(resultingBuffer)-reservedSizeForNumberOfOffsets:], uint16(page.startingOffsets.Length()))
+	binary.LittleEndian.PutUint32(resultingBuffer[len(resultingBuffer)-reservedSizeForNumberOfOffsets:], uint32(page.startingOffsets.Length()))
 }
 
 func (page *Page) Content() []byte {
 	return page.buffer
 }
 
+// LogSequenceNumber returns the log sequence number of the latest record in the page.
+func (page *Page) LogSequenceNumber() uint {
+	return uint(page.header().LogSequenceNumber())
+}
+
+func (page *Page) setLogSequenceNumber(logSequenceNumber uint) {
+	page.header().SetLogSequenceNumber(uint64(logSequenceNumber))
+}
+
+func (page *Page) header() file.PageHeader {
+	return file.PageHeaderOf(page.buffer)
+}
+
 func (page *Page) BackwardIterator() *BackwardRecordIterator {
 	return &BackwardRecordIterator{
 		page:        page,
@@ -72,7 +96,7 @@ func (page *Page) BackwardIterator() *BackwardRecordIterator {
 	}
 }
 
-func (page *Page) getBytesAt(offset uint16) []byte {
+func (page *Page) getBytesAt(offset uint32) []byte {
 	decoded, _ := gorel.DecodeByteSlice(page.buffer, offset)
 	return decoded
 }
@@ -92,6 +116,18 @@ func (page *Page) hasCapacityFor(buffer []byte) bool {
 	return uint(bytesAvailable) >= bytesNeeded
 }
 
+// fitsInAnEmptyPage returns true if a page of the block size, without any records, has the capacity for the record.
+func fitsInAnEmptyPage(blockSize uint, record []byte) bool {
+	return len(record) <= maxRecordSize(blockSize)
+}
+
+// maxRecordSize returns the size of the largest record which a page of the block size, without any records, has the
+// capacity for.
+func maxRecordSize(blockSize uint) int {
+	bytesAvailable := int(blockSize) - file.PageHeaderSize - 2*reservedSizeForNumberOfOffsets
+	return bytesAvailable - int(gorel.BytesNeededForEncodingAByteSlice(nil)) - file.SizeUsedInBytesFor(1)
+}
+
 func (page *Page) updateCurrentWriteOffset() {
 	lastStartingOffset := page.startingOffsets.OffsetAtIndex(page.startingOffsets.Length() - 1)
 	_, endOffset := gorel.DecodeByteSlice(page.buffer, lastStartingOffset)
@@ -17,3 +17,9 @@ func (iterator *BackwardRecordIterator) Record() []byte {
 	recordStartingOffset := iterator.page.startingOffsets.OffsetAtIndex(iterator.offsetIndex)
 	return iterator.page.getBytesAt(recordStartingOffset)
 }
+
+// LogSequenceNumber returns the log sequence number of the record. The records of a page have consecutive log
+// sequence numbers, and the header of the page holds the log sequence number of its latest record.
+func (iterator *BackwardRecordIterator) LogSequenceNumber() uint {
+	return iterator.page.LogSequenceNumber() - uint(iterator.page.startingOffsets.Length()-1-iterator.offsetIndex)
+}
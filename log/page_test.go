@@ -3,6 +3,7 @@ package log
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"gorel/file"
 	"testing"
 )
 
@@ -14,13 +15,13 @@ func TestAttemptToAddARecordInAPageWithInsufficientSize(t *testing.T) {
 }
 
 func TestAttemptToAddACoupleOfRecordsInAPageWithSizeSufficientForOnlyOneRecord(t *testing.T) {
-	page := NewPage(60)
+	page := NewPage(86)
 	assert.True(t, page.Add([]byte("RocksDB is an LSM-based key/value storage engine")))
 	assert.False(t, page.Add([]byte("RocksDB is an LSM-based key/value storage engine")))
 }
 
 func TestAttemptToAddACoupleOfRecordsSuccessfullyInAPageWithJustEnoughSize(t *testing.T) {
-	page := NewPage(108)
+	page := NewPage(140)
 	assert.True(t, page.Add([]byte("RocksDB is an LSM-based key/value storage engine")))
 	assert.True(t, page.Add([]byte("RocksDB is an LSM-based key/value storage engine")))
 }
@@ -156,3 +157,16 @@ func TestDecodeAPageWithFewRecords(t *testing.T) {
 	}
 	assert.False(t, iterator.IsValid())
 }
+
+func TestAttemptToDecodeALogPageFromADataPage(t *testing.T) {
+	buffer := make([]byte, blockSize)
+	file.PageHeaderOf(buffer).Format(file.PageTypeData)
+
+	page := NewPage(blockSize)
+	assert.ErrorIs(t, page.DecodeFrom(buffer), file.InvalidPageHeaderError)
+}
+
+func TestARecordFitsInAnEmptyPage(t *testing.T) {
+	assert.True(t, fitsInAnEmptyPage(86, []byte("RocksDB is an LSM-based key/value storage engine")))
+	assert.False(t, fitsInAnEmptyPage(30, []byte("RocksDB is an LSM-based key/value storage engine")))
+}
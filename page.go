@@ -1,6 +1,6 @@
 package gorel
 
 type Page interface {
-	DecodeFrom([]byte)
+	DecodeFrom([]byte) error
 	Content() []byte
 }
@@ -0,0 +1,70 @@
+package tx
+
+import (
+	"gorel/buffer"
+	"gorel/file"
+	"slices"
+)
+
+// bufferList tracks the buffers pinned by a transaction. A block may be pinned more than once, and it stays in the
+// list until all its pins are released.
+type bufferList struct {
+	bufferManager *buffer.BufferManager
+	buffers       map[file.BlockId]*buffer.Buffer
+	pins          []file.BlockId
+}
+
+func newBufferList(bufferManager *buffer.BufferManager) *bufferList {
+	return &bufferList{
+		bufferManager: bufferManager,
+		buffers:       make(map[file.BlockId]*buffer.Buffer),
+	}
+}
+
+func (list *bufferList) pin(blockId file.BlockId) error {
+	pinnedBuffer, err := list.bufferManager.Pin(blockId)
+	if err != nil {
+		return err
+	}
+	list.buffers[blockId] = pinnedBuffer
+	list.pins = append(list.pins, blockId)
+	return nil
+}
+
+// pinNew appends a new block to the file, and pins its buffer.
+func (list *bufferList) pinNew(fileName string) (file.BlockId, error) {
+	pinnedBuffer, err := list.bufferManager.PinNew(fileName, nil)
+	if err != nil {
+		return file.MissingBlockId, err
+	}
+	blockId := pinnedBuffer.BlockId()
+	list.buffers[blockId] = pinnedBuffer
+	list.pins = append(list.pins, blockId)
+	return blockId, nil
+}
+
+func (list *bufferList) unpin(blockId file.BlockId) {
+	pinnedBuffer, ok := list.buffers[blockId]
+	if !ok {
+		return
+	}
+	list.bufferManager.Unpin(pinnedBuffer)
+	pinIndex := slices.Index(list.pins, blockId)
+	list.pins = slices.Delete(list.pins, pinIndex, pinIndex+1)
+	if !slices.Contains(list.pins, blockId) {
+		delete(list.buffers, blockId)
+	}
+}
+
+func (list *bufferList) unpinAll() {
+	for _, blockId := range list.pins {
+		list.bufferManager.Unpin(list.buffers[blockId])
+	}
+	list.buffers = make(map[file.BlockId]*buffer.Buffer)
+	list.pins = nil
+}
+
+func (list *bufferList) bufferFor(blockId file.BlockId) (*buffer.Buffer, bool) {
+	pinnedBuffer, ok := list.buffers[blockId]
+	return pinnedBuffer, ok
+}
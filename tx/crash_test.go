@@ -0,0 +1,578 @@
+package tx
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"gorel/buffer"
+	"gorel/file"
+	"gorel/log"
+	"maps"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var (
+	crashSeed   = flag.Int64("crash.seed", 0, "seed of the crash recovery workload, 0 runs the default seeds")
+	crashRounds = flag.Int("crash.rounds", 8, "number of crashes and recoveries per seed")
+)
+
+const (
+	crashDataFileName      = "data"
+	crashLogFileName       = "log"
+	crashNumberOfBlocks    = 8
+	crashFieldsPerBlock    = 6
+	crashUint64Fields      = 4
+	crashMaxActive         = 3
+	crashMaxStepsPerRound  = 64
+	crashSavepointName     = "savepoint"
+	crashBuffersInThePool  = 4
+	crashFirstWrittenValue = 1
+	// crashMaxWritesBeforeTheCrash bounds the number of writes which succeed before the injected crash.
+	crashMaxWritesBeforeTheCrash = 48
+	crashMaxConcurrentWrites     = 4
+)
+
+// injectedCrashError fails the writes from the point at which the crash harness crashes the database.
+var injectedCrashError = errors.New("crash injected by the crash harness")
+
+// injectedCrash is the panic which abandons a step of the workload once a write failed with injectedCrashError.
+type injectedCrash struct{}
+
+// crashField identifies a field of the data file of the crash harness. The first crashUint64Fields fields of a block
+// hold uint64 values, and the others hold the byte slices which encode the values.
+type crashField struct {
+	blockNumber uint
+	index       int
+}
+
+// crashTransaction is a transaction of the workload along with the blocks it owns, which no other transaction of the
+// workload reads or modifies, and the values it has written. A prepared transaction keeps its blocks until it is
+// committed or rolled back under its global transaction id, across the crashes.
+type crashTransaction struct {
+	transaction         *Transaction
+	blocks              map[uint]struct{}
+	written             map[crashField]uint64
+	writtenAtSavepoint  map[crashField]uint64
+	globalTransactionId string
+}
+
+// crashHarness runs random workloads against a database directory, crashes the database at a random point by
+// dropping the buffers and the log records which were not written, restarts it and runs the recovery, and then checks
+// that the fields hold the values of the committed transactions and none of the others, and that the prepared
+// transactions are restored in doubt. The crash happens between two steps of the workload, or at a write that it
+// injects a failure into: in the middle of a log flush, of a checkpoint, of a truncation of the log or of the write of
+// a buffer. Every written value is unique, so an uncommitted value which survives the crash is detected, and every
+// other byte slice value overflows the page. A workload is reproducible from its seed, except for the order of the
+// concurrent writes.
+type crashHarness struct {
+	t           *testing.T
+	seed        int64
+	random      *rand.Rand
+	directory   string
+	fileManager *file.BlockFileManager
+	database    database
+	committed   map[crashField]uint64
+	active      []*crashTransaction
+	prepared    map[string]*crashTransaction
+	// rollingBack holds the global transaction ids of the prepared transactions whose rollback was interrupted by the
+	// crash, which the recovery restores in doubt or rolls back, depending on the compensation records which reached
+	// the log.
+	rollingBack map[string]struct{}
+	freeBlocks  map[uint]struct{}
+	nextValue   uint64
+	crashed     atomic.Bool
+	// lastFinishedTransactionNumber is the greatest number of the transactions which committed or rolled back, whose
+	// log records are flushed.
+	lastFinishedTransactionNumber int
+}
+
+func newCrashHarness(t *testing.T, seed int64) *crashHarness {
+	harness := &crashHarness{
+		t:           t,
+		seed:        seed,
+		random:      rand.New(rand.NewSource(seed)),
+		directory:   t.TempDir(),
+		committed:   make(map[crashField]uint64),
+		prepared:    make(map[string]*crashTransaction),
+		rollingBack: make(map[string]struct{}),
+		nextValue:   crashFirstWrittenValue,
+	}
+	harness.open()
+
+	transaction := harness.newTransaction()
+	for blockNumber := uint(0); blockNumber < crashNumberOfBlocks; blockNumber++ {
+		blockId, err := transaction.Append(crashDataFileName)
+		harness.check(err)
+		for index := 0; index < crashFieldsPerBlock; index++ {
+			field := crashField{blockNumber: blockNumber, index: index}
+			harness.set(transaction, field, 0)
+			harness.committed[field] = 0
+		}
+		transaction.Unpin(blockId)
+	}
+	harness.check(transaction.Commit())
+	harness.recordFinished(transaction)
+	return harness
+}
+
+// open opens the database in the directory, along with a new log manager and a new buffer pool.
+func (harness *crashHarness) open() {
+	fileManager, err := file.NewBlockFileManager(harness.directory, blockSize)
+	harness.check(err)
+	logManager, err := log.NewBlockLogManager(fileManager, crashLogFileName)
+	harness.check(err)
+	bufferManager := buffer.NewBufferManager(crashBuffersInThePool, fileManager, logManager)
+
+	harness.fileManager = fileManager
+	harness.database = database{
+		fileManager:        fileManager,
+		logManager:         logManager,
+		bufferManager:      bufferManager,
+		transactionManager: NewTransactionManager(fileManager, logManager, bufferManager),
+	}
+	harness.active = nil
+	harness.freeBlocks = make(map[uint]struct{})
+	for blockNumber := uint(0); blockNumber < crashNumberOfBlocks; blockNumber++ {
+		harness.freeBlocks[blockNumber] = struct{}{}
+	}
+	for _, prepared := range harness.prepared {
+		for blockNumber := range prepared.blocks {
+			delete(harness.freeBlocks, blockNumber)
+		}
+	}
+}
+
+// crash abandons the database without writing its modified buffers and the log records which were not flushed,
+// and restarts it. The database keeps no state outside of the managers which open creates, the last transaction
+// number included, so the restarted database knows only what the files hold.
+func (harness *crashHarness) crash() {
+	harness.database.bufferManager.Close()
+	harness.fileManager.Close()
+	harness.open()
+}
+
+// runRound runs a random number of workload steps, crashes, recovers and verifies the recovered fields.
+func (harness *crashHarness) runRound(round int) {
+	harness.injectCrash()
+	harness.runSteps(1 + harness.random.Intn(crashMaxStepsPerRound))
+	harness.crash()
+	harness.check(harness.newTransaction().Recover())
+	harness.verifyPrepared(round)
+	harness.verify(round)
+}
+
+// injectCrash fails, in half of the rounds, a random write of the round along with all the writes after it, as if the
+// database crashed in the middle of the write. In half of those rounds, the failing write is a write of the log, so
+// the crash happens in the middle of a log flush, of a checkpoint or of a truncation of the log.
+func (harness *crashHarness) injectCrash() {
+	harness.crashed.Store(false)
+	if harness.random.Intn(2) == 0 {
+		return
+	}
+	logOnly := harness.random.Intn(2) == 0
+	remainingWrites := harness.random.Intn(crashMaxWritesBeforeTheCrash)
+	harness.fileManager.SetWriteFault(func(fileName string) error {
+		switch {
+		case harness.crashed.Load():
+			return injectedCrashError
+		case logOnly && !strings.HasPrefix(fileName, crashLogFileName):
+			return nil
+		case remainingWrites > 0:
+			remainingWrites--
+			return nil
+		}
+		harness.crashed.Store(true)
+		return injectedCrashError
+	})
+}
+
+// runSteps runs the steps of the workload, until the injected crash fails one of them.
+func (harness *crashHarness) runSteps(steps int) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if _, ok := recovered.(injectedCrash); !ok {
+				panic(recovered)
+			}
+		}
+	}()
+	for step := 0; step < steps; step++ {
+		harness.step()
+	}
+}
+
+func (harness *crashHarness) step() {
+	if len(harness.active) == 0 {
+		harness.begin()
+		return
+	}
+	workload := harness.active[harness.random.Intn(len(harness.active))]
+	switch choice := harness.random.Intn(100); {
+	case choice < 10 && len(harness.active) < crashMaxActive:
+		harness.begin()
+	case choice < 45:
+		harness.write(workload)
+	case choice < 55:
+		harness.readOwnWrite(workload)
+	case choice < 60:
+		workload.transaction.Savepoint(crashSavepointName)
+		workload.writtenAtSavepoint = maps.Clone(workload.written)
+	case choice < 65:
+		if workload.writtenAtSavepoint != nil {
+			harness.check(workload.transaction.RollbackTo(crashSavepointName))
+			workload.written = maps.Clone(workload.writtenAtSavepoint)
+		}
+	case choice < 73:
+		harness.check(workload.transaction.Commit())
+		maps.Copy(harness.committed, workload.written)
+		harness.finish(workload)
+	case choice < 78:
+		harness.check(workload.transaction.Rollback())
+		harness.finish(workload)
+	case choice < 83:
+		harness.prepare(workload)
+	case choice < 88:
+		harness.finishPrepared()
+	case choice < 93:
+		harness.check(harness.database.bufferManager.FlushAll(workload.transaction.TransactionNumber()))
+	case choice < 96:
+		harness.writeConcurrently()
+	default:
+		harness.check(harness.database.transactionManager.Checkpoint())
+	}
+}
+
+func (harness *crashHarness) begin() {
+	harness.active = append(harness.active, &crashTransaction{
+		transaction: harness.newTransaction(),
+		blocks:      make(map[uint]struct{}),
+		written:     make(map[crashField]uint64),
+	})
+}
+
+// write writes the next value to a field of a block that the transaction owns, or of a free block which it takes.
+func (harness *crashHarness) write(workload *crashTransaction) {
+	var candidates []uint
+	for blockNumber := uint(0); blockNumber < crashNumberOfBlocks; blockNumber++ {
+		_, owned := workload.blocks[blockNumber]
+		_, free := harness.freeBlocks[blockNumber]
+		if owned || free {
+			candidates = append(candidates, blockNumber)
+		}
+	}
+	if len(candidates) == 0 {
+		return
+	}
+	blockNumber := candidates[harness.random.Intn(len(candidates))]
+	delete(harness.freeBlocks, blockNumber)
+	workload.blocks[blockNumber] = struct{}{}
+
+	field := crashField{blockNumber: blockNumber, index: harness.random.Intn(crashFieldsPerBlock)}
+	harness.check(harness.pinAndSet(workload.transaction, field, harness.nextValue))
+
+	workload.written[field] = harness.nextValue
+	harness.nextValue++
+}
+
+// writeConcurrently writes a few values to the blocks of each active transaction, with all the transactions writing
+// at the same time.
+func (harness *crashHarness) writeConcurrently() {
+	type plannedWrite struct {
+		field crashField
+		value uint64
+	}
+	plannedWrites := make([][]plannedWrite, len(harness.active))
+	for index, workload := range harness.active {
+		blockNumbers := workload.ownedBlockNumbers()
+		if len(blockNumbers) == 0 {
+			continue
+		}
+		for writes := 1 + harness.random.Intn(crashMaxConcurrentWrites); writes > 0; writes-- {
+			field := crashField{
+				blockNumber: blockNumbers[harness.random.Intn(len(blockNumbers))],
+				index:       harness.random.Intn(crashFieldsPerBlock),
+			}
+			plannedWrites[index] = append(plannedWrites[index], plannedWrite{field: field, value: harness.nextValue})
+			harness.nextValue++
+		}
+	}
+
+	errs := make([]error, len(harness.active))
+	var writers sync.WaitGroup
+	for index, workload := range harness.active {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for _, planned := range plannedWrites[index] {
+				if errs[index] = harness.pinAndSet(workload.transaction, planned.field, planned.value); errs[index] != nil {
+					return
+				}
+			}
+		}()
+	}
+	writers.Wait()
+
+	for index, workload := range harness.active {
+		harness.check(errs[index])
+		for _, planned := range plannedWrites[index] {
+			workload.written[planned.field] = planned.value
+		}
+	}
+}
+
+// prepare prepares the transaction under a global transaction id, after which it is no longer active but keeps its
+// blocks until it is committed or rolled back with the id.
+func (harness *crashHarness) prepare(workload *crashTransaction) {
+	globalTransactionId := fmt.Sprintf("global-%d", workload.transaction.TransactionNumber())
+	harness.check(workload.transaction.Prepare(globalTransactionId))
+	harness.recordFinished(workload.transaction)
+
+	workload.globalTransactionId = globalTransactionId
+	harness.prepared[globalTransactionId] = workload
+	harness.removeActive(workload)
+}
+
+// finishPrepared commits or rolls back a random prepared transaction, which may have been restored in doubt by the
+// recovery.
+func (harness *crashHarness) finishPrepared() {
+	if len(harness.prepared) == 0 {
+		return
+	}
+	globalTransactionIds := harness.preparedGlobalTransactionIds()
+	globalTransactionId := globalTransactionIds[harness.random.Intn(len(globalTransactionIds))]
+	workload := harness.prepared[globalTransactionId]
+	if harness.random.Intn(2) == 0 {
+		harness.check(harness.database.transactionManager.CommitPrepared(globalTransactionId))
+		maps.Copy(harness.committed, workload.written)
+	} else {
+		harness.rollingBack[globalTransactionId] = struct{}{}
+		harness.check(harness.database.transactionManager.RollbackPrepared(globalTransactionId))
+		delete(harness.rollingBack, globalTransactionId)
+	}
+	harness.removePrepared(globalTransactionId)
+}
+
+// readOwnWrite reads a field of a block that the transaction owns, which holds the value it wrote, or the committed
+// value.
+func (harness *crashHarness) readOwnWrite(workload *crashTransaction) {
+	blockNumbers := workload.ownedBlockNumbers()
+	if len(blockNumbers) == 0 {
+		return
+	}
+
+	field := crashField{
+		blockNumber: blockNumbers[harness.random.Intn(len(blockNumbers))],
+		index:       harness.random.Intn(crashFieldsPerBlock),
+	}
+	expected, ok := workload.written[field]
+	if !ok {
+		expected = harness.committed[field]
+	}
+	blockId := file.NewBlockId(crashDataFileName, field.blockNumber)
+	harness.check(workload.transaction.Pin(blockId))
+	value := harness.get(workload.transaction, field)
+	workload.transaction.Unpin(blockId)
+	if value != expected {
+		harness.t.Fatalf("seed %d: transaction %d read %d from %v, expected %d",
+			harness.seed, workload.transaction.TransactionNumber(), value, field, expected,
+		)
+	}
+}
+
+func (harness *crashHarness) finish(workload *crashTransaction) {
+	harness.recordFinished(workload.transaction)
+	harness.removeActive(workload)
+	for blockNumber := range workload.blocks {
+		harness.freeBlocks[blockNumber] = struct{}{}
+	}
+}
+
+func (harness *crashHarness) removeActive(workload *crashTransaction) {
+	for index, active := range harness.active {
+		if active == workload {
+			harness.active = append(harness.active[:index], harness.active[index+1:]...)
+			return
+		}
+	}
+}
+
+func (harness *crashHarness) removePrepared(globalTransactionId string) {
+	for blockNumber := range harness.prepared[globalTransactionId].blocks {
+		harness.freeBlocks[blockNumber] = struct{}{}
+	}
+	delete(harness.prepared, globalTransactionId)
+}
+
+func (harness *crashHarness) preparedGlobalTransactionIds() []string {
+	globalTransactionIds := make([]string, 0, len(harness.prepared))
+	for globalTransactionId := range harness.prepared {
+		globalTransactionIds = append(globalTransactionIds, globalTransactionId)
+	}
+	slices.Sort(globalTransactionIds)
+	return globalTransactionIds
+}
+
+// verifyPrepared checks that the recovery restored the prepared transactions in doubt, and settles the transactions
+// whose rollback was interrupted by the crash.
+func (harness *crashHarness) verifyPrepared(round int) {
+	recovered := harness.database.transactionManager.PreparedTransactions()
+	for globalTransactionId := range harness.rollingBack {
+		if !slices.Contains(recovered, globalTransactionId) {
+			harness.removePrepared(globalTransactionId)
+		}
+	}
+	clear(harness.rollingBack)
+	if expected := harness.preparedGlobalTransactionIds(); !slices.Equal(expected, recovered) {
+		harness.t.Fatalf("seed %d, round %d: recovered the prepared transactions %v, expected %v", harness.seed, round, recovered, expected)
+	}
+}
+
+// verify reads all the fields in a new transaction, in the order of the blocks, and compares them to the committed
+// values. The blocks of the prepared transactions are skipped, as they remain locked until the transactions finish.
+func (harness *crashHarness) verify(round int) {
+	transaction := harness.newTransaction()
+	for blockNumber := uint(0); blockNumber < crashNumberOfBlocks; blockNumber++ {
+		if harness.isPrepared(blockNumber) {
+			continue
+		}
+		blockId := file.NewBlockId(crashDataFileName, blockNumber)
+		harness.check(transaction.Pin(blockId))
+		for index := 0; index < crashFieldsPerBlock; index++ {
+			field := crashField{blockNumber: blockNumber, index: index}
+			if value, expected := harness.get(transaction, field), harness.committed[field]; value != expected {
+				harness.t.Fatalf("seed %d, round %d: recovered %d in %v, expected the committed %d", harness.seed, round, value, field, expected)
+			}
+		}
+		transaction.Unpin(blockId)
+	}
+	harness.check(transaction.Commit())
+	harness.recordFinished(transaction)
+}
+
+// newTransaction starts a transaction, whose transaction number must follow the numbers of the finished transactions,
+// even those which finished before a crash. The number of a transaction whose log records were lost in a crash may
+// be assigned again.
+func (harness *crashHarness) newTransaction() *Transaction {
+	transaction, err := harness.database.transactionManager.NewTransaction()
+	harness.check(err)
+	if transaction.TransactionNumber() <= harness.lastFinishedTransactionNumber {
+		harness.t.Fatalf("seed %d: transaction number %d was assigned to a finished transaction", harness.seed, transaction.TransactionNumber())
+	}
+	return transaction
+}
+
+func (harness *crashHarness) isPrepared(blockNumber uint) bool {
+	for _, prepared := range harness.prepared {
+		if _, ok := prepared.blocks[blockNumber]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// recordFinished records the number of a transaction which committed, rolled back or prepared.
+func (harness *crashHarness) recordFinished(transaction *Transaction) {
+	harness.lastFinishedTransactionNumber = max(harness.lastFinishedTransactionNumber, transaction.TransactionNumber())
+}
+
+// set writes the value to the field, as a uint64 or as the byte slice which encodes it.
+func (harness *crashHarness) set(transaction *Transaction, field crashField, value uint64) {
+	harness.check(harness.trySet(transaction, field, value))
+}
+
+func (harness *crashHarness) trySet(transaction *Transaction, field crashField, value uint64) error {
+	blockId := file.NewBlockId(crashDataFileName, field.blockNumber)
+	if field.index < crashUint64Fields {
+		return transaction.SetUint64(blockId, field.index, value)
+	}
+	return transaction.SetBytes(blockId, field.index, crashBytes(value))
+}
+
+// pinAndSet pins the block of the field for the duration of trySet.
+func (harness *crashHarness) pinAndSet(transaction *Transaction, field crashField, value uint64) error {
+	blockId := file.NewBlockId(crashDataFileName, field.blockNumber)
+	if err := transaction.Pin(blockId); err != nil {
+		return err
+	}
+	defer transaction.Unpin(blockId)
+
+	return harness.trySet(transaction, field, value)
+}
+
+// get reads the value of the field, decoding a byte slice which must be the encoding of the value.
+func (harness *crashHarness) get(transaction *Transaction, field crashField) uint64 {
+	blockId := file.NewBlockId(crashDataFileName, field.blockNumber)
+	if field.index < crashUint64Fields {
+		value, err := transaction.GetUint64(blockId, field.index)
+		harness.check(err)
+		return value
+	}
+	encoded, err := transaction.GetBytes(blockId, field.index)
+	harness.check(err)
+	var value uint64
+	if len(encoded) >= 8 {
+		value = binary.LittleEndian.Uint64(encoded)
+	}
+	if !bytes.Equal(encoded, crashBytes(value)) {
+		harness.t.Fatalf("seed %d: the %d bytes in %v are not the encoding of a value", harness.seed, len(encoded), field)
+	}
+	return value
+}
+
+// check fails the test on an error, unless the error follows the injected crash, which abandons the step instead.
+func (harness *crashHarness) check(err error) {
+	harness.t.Helper()
+	if err != nil && harness.crashed.Load() {
+		panic(injectedCrash{})
+	}
+	if err != nil {
+		harness.t.Fatalf("seed %d: %v", harness.seed, err)
+	}
+}
+
+// crashBytes encodes the value as the value repeated up to a length which depends on it. The encoding of an odd value
+// takes more than a quarter of a block, so it is stored in an overflow chain.
+func crashBytes(value uint64) []byte {
+	length := 8 + int(value%(blockSize/8))
+	if value%2 == 1 {
+		length = blockSize/4 + int(value%(2*blockSize))
+	}
+	encoded := make([]byte, 0, length+8)
+	for len(encoded) < length {
+		encoded = binary.LittleEndian.AppendUint64(encoded, value)
+	}
+	return encoded[:length]
+}
+
+func (workload *crashTransaction) ownedBlockNumbers() []uint {
+	blockNumbers := make([]uint, 0, len(workload.blocks))
+	for blockNumber := range workload.blocks {
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+	slices.Sort(blockNumbers)
+	return blockNumbers
+}
+
+func (field crashField) String() string {
+	return fmt.Sprintf("%v:%v[%v]", crashDataFileName, field.blockNumber, field.index)
+}
+
+func TestRecoverFromCrashesAtRandomPointsOfRandomWorkloads(t *testing.T) {
+	seeds := []int64{1, 2, 3, 4, 5, 6, 7, 8}
+	if *crashSeed != 0 {
+		seeds = []int64{*crashSeed}
+	}
+	for _, seed := range seeds {
+		harness := newCrashHarness(t, seed)
+		for round := 0; round < *crashRounds; round++ {
+			harness.runRound(round)
+		}
+		harness.fileManager.Close()
+	}
+}
@@ -0,0 +1,71 @@
+package tx
+
+import (
+	"fmt"
+	"gorel/file"
+	"math"
+)
+
+// endOfFileBlockNumber is the block number of the end-of-file marker of a file. A transaction which appends a block
+// takes an exclusive lock on the marker, and a SERIALIZABLE transaction which reads the size of the file holds a
+// shared lock on it, so that no block appears in the file while it runs.
+const endOfFileBlockNumber = math.MaxUint
+
+// IsolationLevel decides which shared locks a transaction takes, and how long it holds them. Every level holds the
+// exclusive locks until the transaction commits or rolls back.
+type IsolationLevel uint8
+
+const (
+	// IsolationReadUncommitted takes no shared lock, and reads the modifications of the transactions which have not
+	// committed.
+	IsolationReadUncommitted IsolationLevel = iota
+	// IsolationReadCommitted releases a shared lock right after the read, so that reading a field twice may return
+	// the values of two different committed transactions.
+	IsolationReadCommitted
+	// IsolationRepeatableRead holds the shared locks on the blocks until the end of the transaction, but it does
+	// not hold the end-of-file marker, so that the blocks appended by the other transactions may appear.
+	IsolationRepeatableRead
+	// IsolationSerializable also holds a shared lock on the end-of-file marker of the files whose size it reads,
+	// which prevents the phantoms.
+	IsolationSerializable
+)
+
+func (isolationLevel IsolationLevel) AsString() string {
+	switch isolationLevel {
+	case IsolationReadUncommitted:
+		return "READ UNCOMMITTED"
+	case IsolationReadCommitted:
+		return "READ COMMITTED"
+	case IsolationRepeatableRead:
+		return "REPEATABLE READ"
+	case IsolationSerializable:
+		return "SERIALIZABLE"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(isolationLevel))
+}
+
+func endOfFileMarker(fileName string) file.BlockId {
+	return file.NewBlockId(fileName, endOfFileBlockNumber)
+}
+
+// lockForRead takes the shared lock which the isolation level requires before a read of the block, and returns the
+// function which releases it after the read. The lock is held until the end of the transaction if holdUntilTheEnd
+// is true, and a lock which the transaction held before the read is never released.
+func (transaction *Transaction) lockForRead(blockId file.BlockId, holdUntilTheEnd bool) (func(), error) {
+	noRelease := func() {}
+	if transaction.isolationLevel == IsolationReadUncommitted {
+		return noRelease, nil
+	}
+	if _, held := transaction.lockTable.HeldMode(transaction.transactionNumber, blockId); held {
+		return noRelease, nil
+	}
+	if err := transaction.lockTable.SLock(transaction.transactionNumber, blockId); err != nil {
+		return noRelease, err
+	}
+	if holdUntilTheEnd {
+		return noRelease, nil
+	}
+	return func() {
+		transaction.lockTable.Unlock(transaction.transactionNumber, blockId)
+	}, nil
+}
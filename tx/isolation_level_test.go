@@ -0,0 +1,165 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"testing"
+)
+
+var isolationLevels = []IsolationLevel{
+	IsolationReadUncommitted,
+	IsolationReadCommitted,
+	IsolationRepeatableRead,
+	IsolationSerializable,
+}
+
+func (database database) newTransactionWithIsolationLevel(t *testing.T, isolationLevel IsolationLevel) *Transaction {
+	transaction, err := database.transactionManager.NewTransactionWithIsolationLevel(isolationLevel)
+	assert.Nil(t, err)
+	return transaction
+}
+
+// appendBlockWithAStringAndCommit appends a block with the string in a transaction which commits.
+func appendBlockWithAStringAndCommit(t *testing.T, database database, value string) file.BlockId {
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, value))
+	assert.Nil(t, transaction.Commit())
+	return blockId
+}
+
+func TestDirtyReadsAtEachIsolationLevel(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	for _, isolationLevel := range isolationLevels {
+		blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+
+		writer := database.newTransaction(t)
+		reader := database.newTransactionWithIsolationLevel(t, isolationLevel)
+		assert.Nil(t, writer.Pin(blockId))
+		assert.Nil(t, reader.Pin(blockId))
+		assert.Nil(t, writer.SetString(blockId, 0, "RocksDB"))
+
+		result := make(chan string, 1)
+		go func() {
+			value, err := reader.GetString(blockId, 0)
+			assert.Nil(t, err)
+			result <- value
+		}()
+		if isolationLevel == IsolationReadUncommitted {
+			assert.Equal(t, "RocksDB", <-result, isolationLevel.AsString())
+			assert.Nil(t, writer.Rollback())
+		} else {
+			waitUntilWaiting(t, database.transactionManager.lockTable, reader.TransactionNumber())
+			assert.Nil(t, writer.Rollback())
+			assert.Equal(t, "BoltDB", <-result, isolationLevel.AsString())
+		}
+		assert.Nil(t, reader.Commit())
+	}
+}
+
+func TestNonRepeatableReadsAtEachIsolationLevel(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	for _, isolationLevel := range isolationLevels {
+		blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+
+		reader := database.newTransactionWithIsolationLevel(t, isolationLevel)
+		writer := database.newTransaction(t)
+		assert.Nil(t, reader.Pin(blockId))
+		assert.Nil(t, writer.Pin(blockId))
+
+		value, err := reader.GetString(blockId, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, "BoltDB", value)
+
+		result := acquireInBackground(func() error {
+			if err := writer.SetString(blockId, 0, "RocksDB"); err != nil {
+				return err
+			}
+			return writer.Commit()
+		})
+		if isolationLevel < IsolationRepeatableRead {
+			assert.Nil(t, <-result)
+			value, err = reader.GetString(blockId, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, "RocksDB", value, isolationLevel.AsString())
+			assert.Nil(t, reader.Commit())
+		} else {
+			waitUntilWaiting(t, database.transactionManager.lockTable, writer.TransactionNumber())
+			value, err = reader.GetString(blockId, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, "BoltDB", value, isolationLevel.AsString())
+			assert.Nil(t, reader.Commit())
+			assert.Nil(t, <-result)
+		}
+	}
+}
+
+func TestPhantomsAtEachIsolationLevel(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	for _, isolationLevel := range isolationLevels {
+		reader := database.newTransactionWithIsolationLevel(t, isolationLevel)
+		appender := database.newTransaction(t)
+
+		size, err := reader.Size(t.Name())
+		assert.Nil(t, err)
+
+		result := acquireInBackground(func() error {
+			if _, err := appender.Append(t.Name()); err != nil {
+				return err
+			}
+			return appender.Commit()
+		})
+		if isolationLevel < IsolationSerializable {
+			assert.Nil(t, <-result)
+			sizeAfterTheAppend, err := reader.Size(t.Name())
+			assert.Nil(t, err)
+			assert.Equal(t, size+1, sizeAfterTheAppend, isolationLevel.AsString())
+			assert.Nil(t, reader.Commit())
+		} else {
+			waitUntilWaiting(t, database.transactionManager.lockTable, appender.TransactionNumber())
+			sizeWhileTheAppenderWaits, err := reader.Size(t.Name())
+			assert.Nil(t, err)
+			assert.Equal(t, size, sizeWhileTheAppenderWaits, isolationLevel.AsString())
+			assert.Nil(t, reader.Commit())
+			assert.Nil(t, <-result)
+		}
+	}
+}
+
+func TestReadCommittedReleasesTheSharedLockAfterTheRead(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+
+	transaction := database.newTransactionWithIsolationLevel(t, IsolationReadCommitted)
+	assert.Nil(t, transaction.Pin(blockId))
+	_, err = transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	_, held := database.transactionManager.lockTable.HeldMode(transaction.TransactionNumber(), blockId)
+	assert.False(t, held)
+
+	assert.Nil(t, transaction.SetString(blockId, 0, "RocksDB"))
+	_, err = transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	mode, held := database.transactionManager.lockTable.HeldMode(transaction.TransactionNumber(), blockId)
+	assert.True(t, held)
+	assert.Equal(t, LockExclusive, mode)
+	assert.Nil(t, transaction.Commit())
+}
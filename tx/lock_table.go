@@ -0,0 +1,289 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"gorel/file"
+	"sync"
+	"time"
+)
+
+var ErrLockAbort = errors.New("transaction must abort, it could not acquire a lock")
+
+// LockMode is the mode in which a transaction locks a block.
+type LockMode uint8
+
+const (
+	// LockShared is held by the transactions which read a block. Any number of transactions may hold it together.
+	LockShared LockMode = iota + 1
+	// LockExclusive is held by the transaction which modifies a block, and by no other transaction.
+	LockExclusive
+)
+
+func (mode LockMode) AsString() string {
+	switch mode {
+	case LockShared:
+		return "S"
+	case LockExclusive:
+		return "X"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(mode))
+}
+
+// DeadlockPolicy decides what happens when a transaction requests a lock which conflicts with the locks of other
+// transactions. A transaction is older than another one if its transaction number is smaller.
+type DeadlockPolicy uint8
+
+const (
+	// DeadlockDetection lets the transaction wait, and aborts the youngest transaction of a cycle in the waits-for
+	// graph.
+	DeadlockDetection DeadlockPolicy = iota
+	// DeadlockWaitDie lets the transaction wait if it is older than all the conflicting holders, and aborts it
+	// otherwise.
+	DeadlockWaitDie
+	// DeadlockWoundWait wounds the conflicting holders which are younger than the transaction, and lets it wait. A
+	// wounded transaction aborts at its next lock request, or while it waits for a lock.
+	DeadlockWoundWait
+)
+
+// lockRequest is the lock which a transaction waits for.
+type lockRequest struct {
+	blockId file.BlockId
+	mode    LockMode
+}
+
+// LockTable holds the shared and the exclusive locks of the transactions on the blocks. A transaction waits up to
+// maxWaitTime for a lock before it gets ErrLockAbort, and it gets ErrLockAbort earlier if the deadlock policy aborts
+// it. The released channel is closed, and replaced, whenever the waiting transactions may proceed.
+type LockTable struct {
+	policy      DeadlockPolicy
+	maxWaitTime time.Duration
+	holders     map[file.BlockId]map[int]LockMode
+	heldBlocks  map[int]map[file.BlockId]struct{}
+	waiting     map[int]lockRequest
+	aborted     map[int]string
+	released    chan struct{}
+	lock        sync.Mutex
+}
+
+func NewLockTable(policy DeadlockPolicy, maxWaitTime time.Duration) *LockTable {
+	return &LockTable{
+		policy:      policy,
+		maxWaitTime: maxWaitTime,
+		holders:     make(map[file.BlockId]map[int]LockMode),
+		heldBlocks:  make(map[int]map[file.BlockId]struct{}),
+		waiting:     make(map[int]lockRequest),
+		aborted:     make(map[int]string),
+		released:    make(chan struct{}),
+	}
+}
+
+// SLock acquires a shared lock on the block. It holds if the transaction already holds any lock on the block.
+func (lockTable *LockTable) SLock(transactionNumber int, blockId file.BlockId) error {
+	return lockTable.acquire(transactionNumber, lockRequest{blockId: blockId, mode: LockShared})
+}
+
+// XLock acquires an exclusive lock on the block, upgrading the shared lock that the transaction may hold.
+func (lockTable *LockTable) XLock(transactionNumber int, blockId file.BlockId) error {
+	return lockTable.acquire(transactionNumber, lockRequest{blockId: blockId, mode: LockExclusive})
+}
+
+// Unlock releases the lock of the transaction on the block.
+func (lockTable *LockTable) Unlock(transactionNumber int, blockId file.BlockId) {
+	lockTable.lock.Lock()
+	defer lockTable.lock.Unlock()
+
+	lockTable.release(transactionNumber, blockId)
+	lockTable.notifyWaiting()
+}
+
+// UnlockAll releases all the locks of the transaction, and forgets that it was chosen to abort.
+func (lockTable *LockTable) UnlockAll(transactionNumber int) {
+	lockTable.lock.Lock()
+	defer lockTable.lock.Unlock()
+
+	for blockId := range lockTable.heldBlocks[transactionNumber] {
+		lockTable.release(transactionNumber, blockId)
+	}
+	delete(lockTable.aborted, transactionNumber)
+	lockTable.notifyWaiting()
+}
+
+// HeldMode returns the mode of the lock of the transaction on the block, and false if it holds none.
+func (lockTable *LockTable) HeldMode(transactionNumber int, blockId file.BlockId) (LockMode, bool) {
+	lockTable.lock.Lock()
+	defer lockTable.lock.Unlock()
+
+	mode, ok := lockTable.holders[blockId][transactionNumber]
+	return mode, ok
+}
+
+func (lockTable *LockTable) acquire(transactionNumber int, request lockRequest) error {
+	lockTable.lock.Lock()
+	defer lockTable.lock.Unlock()
+	defer delete(lockTable.waiting, transactionNumber)
+
+	deadline := time.Now().Add(lockTable.maxWaitTime)
+	for {
+		if reason, ok := lockTable.aborted[transactionNumber]; ok {
+			return lockTable.abortError(transactionNumber, request, reason)
+		}
+		conflictingHolders := lockTable.conflictingHolders(transactionNumber, request)
+		if len(conflictingHolders) == 0 {
+			lockTable.grant(transactionNumber, request)
+			return nil
+		}
+		lockTable.waiting[transactionNumber] = request
+
+		switch lockTable.policy {
+		case DeadlockWaitDie:
+			for _, holder := range conflictingHolders {
+				if transactionNumber > holder {
+					return lockTable.abortError(transactionNumber, request, fmt.Sprintf("died waiting for the older transaction %d", holder))
+				}
+			}
+		case DeadlockWoundWait:
+			for _, holder := range conflictingHolders {
+				if holder > transactionNumber {
+					lockTable.abort(holder, fmt.Sprintf("wounded by the older transaction %d", transactionNumber))
+				}
+			}
+		default:
+			if cycle := lockTable.waitsForCycleFrom(transactionNumber); len(cycle) > 0 {
+				victim := youngestOf(cycle)
+				reason := fmt.Sprintf("chosen as the victim of the deadlock between the transactions %v", cycle)
+				if victim == transactionNumber {
+					return lockTable.abortError(transactionNumber, request, reason)
+				}
+				lockTable.abort(victim, reason)
+			}
+		}
+		if !lockTable.waitForRelease(deadline) {
+			return lockTable.abortError(transactionNumber, request, fmt.Sprintf("timed out after %v", lockTable.maxWaitTime))
+		}
+	}
+}
+
+// conflictingHolders returns the other transactions whose locks on the block conflict with the request.
+func (lockTable *LockTable) conflictingHolders(transactionNumber int, request lockRequest) []int {
+	var conflictingHolders []int
+	for holder, mode := range lockTable.holders[request.blockId] {
+		if holder != transactionNumber && (request.mode == LockExclusive || mode == LockExclusive) {
+			conflictingHolders = append(conflictingHolders, holder)
+		}
+	}
+	return conflictingHolders
+}
+
+func (lockTable *LockTable) grant(transactionNumber int, request lockRequest) {
+	holders, ok := lockTable.holders[request.blockId]
+	if !ok {
+		holders = make(map[int]LockMode)
+		lockTable.holders[request.blockId] = holders
+	}
+	if mode, held := holders[transactionNumber]; !held || mode < request.mode {
+		holders[transactionNumber] = request.mode
+	}
+	heldBlocks, ok := lockTable.heldBlocks[transactionNumber]
+	if !ok {
+		heldBlocks = make(map[file.BlockId]struct{})
+		lockTable.heldBlocks[transactionNumber] = heldBlocks
+	}
+	heldBlocks[request.blockId] = struct{}{}
+}
+
+func (lockTable *LockTable) release(transactionNumber int, blockId file.BlockId) {
+	if holders, ok := lockTable.holders[blockId]; ok {
+		delete(holders, transactionNumber)
+		if len(holders) == 0 {
+			delete(lockTable.holders, blockId)
+		}
+	}
+	if heldBlocks, ok := lockTable.heldBlocks[transactionNumber]; ok {
+		delete(heldBlocks, blockId)
+		if len(heldBlocks) == 0 {
+			delete(lockTable.heldBlocks, transactionNumber)
+		}
+	}
+}
+
+// waitsForCycleFrom walks the waits-for graph from the transaction, where a waiting transaction waits for the
+// holders whose locks conflict with its request, and returns the transactions of a cycle back to it.
+func (lockTable *LockTable) waitsForCycleFrom(transactionNumber int) []int {
+	visited := make(map[int]bool)
+	var path []int
+
+	var walk func(current int) bool
+	walk = func(current int) bool {
+		request, waiting := lockTable.waiting[current]
+		if !waiting {
+			return false
+		}
+		visited[current] = true
+		path = append(path, current)
+		for _, holder := range lockTable.conflictingHolders(current, request) {
+			if holder == transactionNumber {
+				return true
+			}
+			if !visited[holder] && walk(holder) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if walk(transactionNumber) {
+		return path
+	}
+	return nil
+}
+
+// abort marks the transaction to abort, and wakes it up if it is waiting.
+func (lockTable *LockTable) abort(transactionNumber int, reason string) {
+	if _, ok := lockTable.aborted[transactionNumber]; !ok {
+		lockTable.aborted[transactionNumber] = reason
+		lockTable.notifyWaiting()
+	}
+}
+
+func (lockTable *LockTable) abortError(transactionNumber int, request lockRequest, reason string) error {
+	return fmt.Errorf("%w: transaction %d requesting %v lock on %v:%v, %v",
+		ErrLockAbort, transactionNumber, request.mode.AsString(), request.blockId.FileName(), request.blockId.BlockNumber(), reason,
+	)
+}
+
+func (lockTable *LockTable) notifyWaiting() {
+	close(lockTable.released)
+	lockTable.released = make(chan struct{})
+}
+
+// waitForRelease releases the lock until a lock is released or a transaction is chosen to abort, or the deadline
+// passes, and returns false if the deadline has passed.
+func (lockTable *LockTable) waitForRelease(deadline time.Time) bool {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return false
+	}
+	released := lockTable.released
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+
+	lockTable.lock.Unlock()
+	defer lockTable.lock.Lock()
+
+	select {
+	case <-released:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// youngestOf returns the transaction with the largest transaction number.
+func youngestOf(transactionNumbers []int) int {
+	youngest := transactionNumbers[0]
+	for _, transactionNumber := range transactionNumbers[1:] {
+		youngest = max(youngest, transactionNumber)
+	}
+	return youngest
+}
@@ -0,0 +1,207 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"testing"
+	"time"
+)
+
+func isWaiting(lockTable *LockTable, transactionNumber int) bool {
+	lockTable.lock.Lock()
+	defer lockTable.lock.Unlock()
+
+	_, waiting := lockTable.waiting[transactionNumber]
+	return waiting
+}
+
+func waitUntilWaiting(t *testing.T, lockTable *LockTable, transactionNumber int) {
+	assert.Eventually(t, func() bool {
+		return isWaiting(lockTable, transactionNumber)
+	}, time.Second, time.Millisecond)
+}
+
+// acquireInBackground requests the lock in a goroutine, and returns the channel which receives the result.
+func acquireInBackground(acquire func() error) chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- acquire()
+	}()
+	return result
+}
+
+func TestSharedLocksOfSeveralTransactions(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 10*time.Millisecond)
+	blockId := file.NewBlockId(t.Name(), 0)
+
+	assert.Nil(t, lockTable.SLock(1, blockId))
+	assert.Nil(t, lockTable.SLock(2, blockId))
+
+	mode, ok := lockTable.HeldMode(2, blockId)
+	assert.True(t, ok)
+	assert.Equal(t, LockShared, mode)
+}
+
+func TestAttemptToAcquireAnExclusiveLockWhichTimesOut(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 10*time.Millisecond)
+	blockId := file.NewBlockId(t.Name(), 0)
+
+	assert.Nil(t, lockTable.SLock(1, blockId))
+	assert.ErrorIs(t, lockTable.XLock(2, blockId), ErrLockAbort)
+
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.ErrorIs(t, lockTable.SLock(2, blockId), ErrLockAbort)
+	assert.False(t, isWaiting(lockTable, 2))
+}
+
+func TestUpgradeASharedLock(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 10*time.Millisecond)
+	blockId := file.NewBlockId(t.Name(), 0)
+
+	assert.Nil(t, lockTable.SLock(1, blockId))
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.Nil(t, lockTable.SLock(1, blockId))
+
+	mode, ok := lockTable.HeldMode(1, blockId)
+	assert.True(t, ok)
+	assert.Equal(t, LockExclusive, mode)
+}
+
+func TestWaitForAnExclusiveLockUntilItIsReleased(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+	otherBlockId := file.NewBlockId(t.Name(), 1)
+
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.Nil(t, lockTable.SLock(1, otherBlockId))
+
+	result := acquireInBackground(func() error {
+		return lockTable.SLock(2, blockId)
+	})
+	waitUntilWaiting(t, lockTable, 2)
+
+	lockTable.UnlockAll(1)
+	assert.Nil(t, <-result)
+
+	_, ok := lockTable.HeldMode(1, otherBlockId)
+	assert.False(t, ok)
+}
+
+func TestDetectADeadlockAndAbortTheYoungestTransactionWhichRequestsTheLock(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+	otherBlockId := file.NewBlockId(t.Name(), 1)
+
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.Nil(t, lockTable.XLock(2, otherBlockId))
+
+	result := acquireInBackground(func() error {
+		return lockTable.XLock(1, otherBlockId)
+	})
+	waitUntilWaiting(t, lockTable, 1)
+
+	startTime := time.Now()
+	assert.ErrorIs(t, lockTable.SLock(2, blockId), ErrLockAbort)
+	assert.Less(t, time.Since(startTime), time.Second)
+
+	lockTable.UnlockAll(2)
+	assert.Nil(t, <-result)
+}
+
+func TestDetectADeadlockAndAbortTheYoungestTransactionWhichWaits(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+	otherBlockId := file.NewBlockId(t.Name(), 1)
+
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.Nil(t, lockTable.XLock(2, otherBlockId))
+
+	victimResult := acquireInBackground(func() error {
+		return lockTable.XLock(2, blockId)
+	})
+	waitUntilWaiting(t, lockTable, 2)
+
+	result := acquireInBackground(func() error {
+		return lockTable.XLock(1, otherBlockId)
+	})
+	assert.ErrorIs(t, <-victimResult, ErrLockAbort)
+	lockTable.UnlockAll(2)
+
+	assert.Nil(t, <-result)
+	assert.Nil(t, lockTable.XLock(2, file.NewBlockId(t.Name(), 2)))
+}
+
+func TestDetectADeadlockBetweenTwoUpgrades(t *testing.T) {
+	lockTable := NewLockTable(DeadlockDetection, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+
+	assert.Nil(t, lockTable.SLock(1, blockId))
+	assert.Nil(t, lockTable.SLock(2, blockId))
+
+	result := acquireInBackground(func() error {
+		return lockTable.XLock(1, blockId)
+	})
+	waitUntilWaiting(t, lockTable, 1)
+
+	assert.ErrorIs(t, lockTable.XLock(2, blockId), ErrLockAbort)
+	lockTable.UnlockAll(2)
+	assert.Nil(t, <-result)
+}
+
+func TestWaitDieLetsTheOlderTransactionWaitAndTheYoungerOneDie(t *testing.T) {
+	lockTable := NewLockTable(DeadlockWaitDie, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+	otherBlockId := file.NewBlockId(t.Name(), 1)
+
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.Nil(t, lockTable.XLock(2, otherBlockId))
+
+	startTime := time.Now()
+	assert.ErrorIs(t, lockTable.SLock(2, blockId), ErrLockAbort)
+	assert.Less(t, time.Since(startTime), time.Second)
+
+	result := acquireInBackground(func() error {
+		return lockTable.SLock(1, otherBlockId)
+	})
+	waitUntilWaiting(t, lockTable, 1)
+
+	lockTable.UnlockAll(2)
+	assert.Nil(t, <-result)
+}
+
+func TestWoundWaitWoundsTheYoungerHolder(t *testing.T) {
+	lockTable := NewLockTable(DeadlockWoundWait, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+	otherBlockId := file.NewBlockId(t.Name(), 1)
+
+	assert.Nil(t, lockTable.XLock(1, blockId))
+	assert.Nil(t, lockTable.XLock(2, otherBlockId))
+
+	result := acquireInBackground(func() error {
+		return lockTable.SLock(2, blockId)
+	})
+	waitUntilWaiting(t, lockTable, 2)
+
+	olderResult := acquireInBackground(func() error {
+		return lockTable.XLock(1, otherBlockId)
+	})
+	assert.ErrorIs(t, <-result, ErrLockAbort)
+	lockTable.UnlockAll(2)
+	assert.Nil(t, <-olderResult)
+}
+
+func TestWoundWaitAbortsTheWoundedTransactionAtItsNextLockRequest(t *testing.T) {
+	lockTable := NewLockTable(DeadlockWoundWait, 5*time.Second)
+	blockId := file.NewBlockId(t.Name(), 0)
+
+	assert.Nil(t, lockTable.SLock(2, blockId))
+
+	result := acquireInBackground(func() error {
+		return lockTable.XLock(1, blockId)
+	})
+	waitUntilWaiting(t, lockTable, 1)
+
+	assert.ErrorIs(t, lockTable.SLock(2, file.NewBlockId(t.Name(), 1)), ErrLockAbort)
+	lockTable.UnlockAll(2)
+	assert.Nil(t, <-result)
+}
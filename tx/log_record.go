@@ -0,0 +1,576 @@
+package tx
+
+import (
+	"cmp"
+	"fmt"
+	"gorel"
+	"gorel/buffer"
+	"gorel/file"
+	"maps"
+	"slices"
+	"strings"
+)
+
+type logRecordType uint8
+
+// noTransactionNumber is the transaction number of the log records which do not belong to a transaction.
+const noTransactionNumber = 0
+
+const (
+	logRecordStart                  logRecordType = 1
+	logRecordCommit                 logRecordType = 2
+	logRecordRollback               logRecordType = 3
+	logRecordUpdate                 logRecordType = 4
+	logRecordBeginCheckpoint        logRecordType = 5
+	logRecordCompensation           logRecordType = 6
+	logRecordPrepare                logRecordType = 7
+	logRecordCheckpointTransactions logRecordType = 8
+	logRecordCheckpointDirtyPages   logRecordType = 9
+	logRecordEndCheckpoint          logRecordType = 10
+)
+
+func (recordType logRecordType) AsString() string {
+	switch recordType {
+	case logRecordStart:
+		return "START"
+	case logRecordCommit:
+		return "COMMIT"
+	case logRecordRollback:
+		return "ROLLBACK"
+	case logRecordUpdate:
+		return "UPDATE"
+	case logRecordBeginCheckpoint:
+		return "BEGIN_CKPT"
+	case logRecordCompensation:
+		return "COMPENSATION"
+	case logRecordPrepare:
+		return "PREPARE"
+	case logRecordCheckpointTransactions:
+		return "CKPT_TRANSACTIONS"
+	case logRecordCheckpointDirtyPages:
+		return "CKPT_DIRTY_PAGES"
+	case logRecordEndCheckpoint:
+		return "END_CKPT"
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(recordType))
+}
+
+// logRecord is a record that a transaction appends to the log. Every record begins with its type and the number of
+// its transaction.
+type logRecord interface {
+	recordType() logRecordType
+	transactionNumber() int
+	encode() []byte
+}
+
+type startRecord struct {
+	txNumber int
+}
+
+type commitRecord struct {
+	txNumber int
+}
+
+type rollbackRecord struct {
+	txNumber int
+}
+
+// beginCheckpointRecord begins a non-quiescent checkpoint. The tables of the checkpoint are taken when it is
+// appended, so the recovery analyzes the log records after it on top of them. It holds the last transaction number
+// assigned by then, which outlives the log records that the checkpoint truncates. The records of a checkpoint do
+// not belong to any transaction.
+type beginCheckpointRecord struct {
+	lastTransactionNumber int
+}
+
+// checkpointTransactionsRecord holds a part of the latest log sequence number of each active transaction, taken by
+// the checkpoint which began at beginLogSequenceNumber.
+type checkpointTransactionsRecord struct {
+	beginLogSequenceNumber uint
+	activeTransactions     map[int]uint
+}
+
+// checkpointDirtyPagesRecord holds a part of the dirty page table, taken by the checkpoint which began at
+// beginLogSequenceNumber.
+type checkpointDirtyPagesRecord struct {
+	beginLogSequenceNumber uint
+	dirtyPages             map[file.BlockId]uint
+}
+
+// endCheckpointRecord ends the checkpoint which began at beginLogSequenceNumber, once all its tables are in the
+// log. The recovery ignores a checkpoint which did not end.
+type endCheckpointRecord struct {
+	beginLogSequenceNumber uint
+}
+
+// checkpointTables are the tables of a non-quiescent checkpoint, taken when the checkpoint began at
+// beginLogSequenceNumber. The checkpoint appends them as records which each fit in a log block.
+type checkpointTables struct {
+	beginLogSequenceNumber uint
+	lastTransactionNumber  int
+	activeTransactions     map[int]uint
+	dirtyPages             map[file.BlockId]uint
+}
+
+// fieldImage is the type description of a field and the bytes that its page stores for it, as returned by
+// buffer.Page.TryGetStored: the image of a large value holds the pointer to its overflow chain, rather than the value.
+// The image of a field which does not exist is a tombstone.
+type fieldImage struct {
+	typeDescription buffer.TypeDescription
+	encoded         []byte
+}
+
+// updateRecord holds the before-image and the after-image of a field, and links to the previous log record of its
+// transaction. The before-image of a field which was added by the update is a tombstone.
+type updateRecord struct {
+	txNumber                  int
+	previousLogSequenceNumber uint
+	blockId                   file.BlockId
+	index                     int
+	beforeImage               fieldImage
+	afterImage                fieldImage
+}
+
+// compensationRecord is appended when an update is undone. It holds the image that the undo restored, which is
+// redone like an update but never undone, and links to the log record of the transaction which is to be undone
+// next.
+type compensationRecord struct {
+	txNumber                  int
+	blockId                   file.BlockId
+	index                     int
+	image                     fieldImage
+	undoNextLogSequenceNumber uint
+}
+
+// prepareRecord is appended when a transaction prepares to commit as a participant of a two-phase commit, under the
+// global transaction id that its coordinator knows it by. It links to the previous log record of its transaction,
+// which is undone if the coordinator decides to roll back. A transaction whose latest record is a PREPARE record is
+// in doubt: the recovery neither commits nor rolls it back.
+type prepareRecord struct {
+	txNumber                  int
+	previousLogSequenceNumber uint
+	globalTransactionId       string
+}
+
+func (record startRecord) recordType() logRecordType {
+	return logRecordStart
+}
+
+func (record startRecord) transactionNumber() int {
+	return record.txNumber
+}
+
+func (record startRecord) encode() []byte {
+	return newLogRecordEncoder(logRecordStart, record.txNumber).bytes()
+}
+
+func (record commitRecord) recordType() logRecordType {
+	return logRecordCommit
+}
+
+func (record commitRecord) transactionNumber() int {
+	return record.txNumber
+}
+
+func (record commitRecord) encode() []byte {
+	return newLogRecordEncoder(logRecordCommit, record.txNumber).bytes()
+}
+
+func (record rollbackRecord) recordType() logRecordType {
+	return logRecordRollback
+}
+
+func (record rollbackRecord) transactionNumber() int {
+	return record.txNumber
+}
+
+func (record rollbackRecord) encode() []byte {
+	return newLogRecordEncoder(logRecordRollback, record.txNumber).bytes()
+}
+
+func (record beginCheckpointRecord) recordType() logRecordType {
+	return logRecordBeginCheckpoint
+}
+
+func (record beginCheckpointRecord) transactionNumber() int {
+	return noTransactionNumber
+}
+
+func (record beginCheckpointRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordBeginCheckpoint, noTransactionNumber)
+	encoder.putUint64(uint64(record.lastTransactionNumber))
+	return encoder.bytes()
+}
+
+func (record checkpointTransactionsRecord) recordType() logRecordType {
+	return logRecordCheckpointTransactions
+}
+
+func (record checkpointTransactionsRecord) transactionNumber() int {
+	return noTransactionNumber
+}
+
+func (record checkpointTransactionsRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordCheckpointTransactions, noTransactionNumber)
+	encoder.putUint64(uint64(record.beginLogSequenceNumber))
+	transactionNumbers := make([]int, 0, len(record.activeTransactions))
+	for transactionNumber := range record.activeTransactions {
+		transactionNumbers = append(transactionNumbers, transactionNumber)
+	}
+	slices.Sort(transactionNumbers)
+	encoder.putUint32(uint32(len(transactionNumbers)))
+	for _, transactionNumber := range transactionNumbers {
+		encoder.putUint64(uint64(transactionNumber))
+		encoder.putUint64(uint64(record.activeTransactions[transactionNumber]))
+	}
+	return encoder.bytes()
+}
+
+func (record checkpointDirtyPagesRecord) recordType() logRecordType {
+	return logRecordCheckpointDirtyPages
+}
+
+func (record checkpointDirtyPagesRecord) transactionNumber() int {
+	return noTransactionNumber
+}
+
+func (record checkpointDirtyPagesRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordCheckpointDirtyPages, noTransactionNumber)
+	encoder.putUint64(uint64(record.beginLogSequenceNumber))
+	blockIds := make([]file.BlockId, 0, len(record.dirtyPages))
+	for blockId := range record.dirtyPages {
+		blockIds = append(blockIds, blockId)
+	}
+	slices.SortFunc(blockIds, compareBlockIds)
+	encoder.putUint32(uint32(len(blockIds)))
+	for _, blockId := range blockIds {
+		encoder.putBlockId(blockId)
+		encoder.putUint64(uint64(record.dirtyPages[blockId]))
+	}
+	return encoder.bytes()
+}
+
+func (record endCheckpointRecord) recordType() logRecordType {
+	return logRecordEndCheckpoint
+}
+
+func (record endCheckpointRecord) transactionNumber() int {
+	return noTransactionNumber
+}
+
+func (record endCheckpointRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordEndCheckpoint, noTransactionNumber)
+	encoder.putUint64(uint64(record.beginLogSequenceNumber))
+	return encoder.bytes()
+}
+
+// redoLogSequenceNumber returns the log sequence number which the redo starts at: the earliest modification of the
+// dirty pages which may not have reached the disk, or the first log record after the checkpoint began.
+func (tables checkpointTables) redoLogSequenceNumber() uint {
+	redoLogSequenceNumber := tables.beginLogSequenceNumber + 1
+	for _, recoveryLogSequenceNumber := range tables.dirtyPages {
+		redoLogSequenceNumber = min(redoLogSequenceNumber, recoveryLogSequenceNumber)
+	}
+	return redoLogSequenceNumber
+}
+
+// records splits the tables into the records which the checkpoint appends between its BEGIN_CKPT and END_CKPT
+// records, none of them longer than maxRecordSize: the active transactions and then the dirty pages, each in as few
+// records as they fit in.
+func (tables checkpointTables) records(maxRecordSize int) []logRecord {
+	var records []logRecord
+	uint64Size := int(gorel.BytesNeededForEncodingAnUint64())
+
+	transactions := checkpointTransactionsRecord{beginLogSequenceNumber: tables.beginLogSequenceNumber}
+	size := 0
+	transactionNumbers := make([]int, 0, len(tables.activeTransactions))
+	for transactionNumber := range tables.activeTransactions {
+		transactionNumbers = append(transactionNumbers, transactionNumber)
+	}
+	slices.Sort(transactionNumbers)
+	for _, transactionNumber := range transactionNumbers {
+		if transactions.activeTransactions == nil || size+2*uint64Size > maxRecordSize {
+			if transactions.activeTransactions != nil {
+				records = append(records, transactions)
+			}
+			transactions.activeTransactions = make(map[int]uint)
+			size = len(transactions.encode())
+		}
+		transactions.activeTransactions[transactionNumber] = tables.activeTransactions[transactionNumber]
+		size += 2 * uint64Size
+	}
+	if transactions.activeTransactions != nil {
+		records = append(records, transactions)
+	}
+
+	dirtyPages := checkpointDirtyPagesRecord{beginLogSequenceNumber: tables.beginLogSequenceNumber}
+	blockIds := make([]file.BlockId, 0, len(tables.dirtyPages))
+	for blockId := range tables.dirtyPages {
+		blockIds = append(blockIds, blockId)
+	}
+	slices.SortFunc(blockIds, compareBlockIds)
+	for _, blockId := range blockIds {
+		entrySize := int(gorel.BytesNeededForEncodingAByteSlice([]byte(blockId.FileName()))) + 2*uint64Size
+		if dirtyPages.dirtyPages == nil || size+entrySize > maxRecordSize {
+			if dirtyPages.dirtyPages != nil {
+				records = append(records, dirtyPages)
+			}
+			dirtyPages.dirtyPages = make(map[file.BlockId]uint)
+			size = len(dirtyPages.encode())
+		}
+		dirtyPages.dirtyPages[blockId] = tables.dirtyPages[blockId]
+		size += entrySize
+	}
+	if dirtyPages.dirtyPages != nil {
+		records = append(records, dirtyPages)
+	}
+	return records
+}
+
+// add adds the entries of a record of the checkpoint to the tables.
+func (tables checkpointTables) add(record logRecord) {
+	switch record := record.(type) {
+	case checkpointTransactionsRecord:
+		maps.Copy(tables.activeTransactions, record.activeTransactions)
+	case checkpointDirtyPagesRecord:
+		maps.Copy(tables.dirtyPages, record.dirtyPages)
+	}
+}
+
+func (record updateRecord) recordType() logRecordType {
+	return logRecordUpdate
+}
+
+func (record updateRecord) transactionNumber() int {
+	return record.txNumber
+}
+
+func (record updateRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordUpdate, record.txNumber)
+	encoder.putUint64(uint64(record.previousLogSequenceNumber))
+	encoder.putBlockId(record.blockId)
+	encoder.putUint32(uint32(record.index))
+	encoder.putFieldImage(record.beforeImage)
+	encoder.putFieldImage(record.afterImage)
+	return encoder.bytes()
+}
+
+func (record compensationRecord) recordType() logRecordType {
+	return logRecordCompensation
+}
+
+func (record compensationRecord) transactionNumber() int {
+	return record.txNumber
+}
+
+func (record compensationRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordCompensation, record.txNumber)
+	encoder.putBlockId(record.blockId)
+	encoder.putUint32(uint32(record.index))
+	encoder.putFieldImage(record.image)
+	encoder.putUint64(uint64(record.undoNextLogSequenceNumber))
+	return encoder.bytes()
+}
+
+func (record prepareRecord) recordType() logRecordType {
+	return logRecordPrepare
+}
+
+func (record prepareRecord) transactionNumber() int {
+	return record.txNumber
+}
+
+func (record prepareRecord) encode() []byte {
+	encoder := newLogRecordEncoder(logRecordPrepare, record.txNumber)
+	encoder.putUint64(uint64(record.previousLogSequenceNumber))
+	encoder.putBytes([]byte(record.globalTransactionId))
+	return encoder.bytes()
+}
+
+// applyTo sets the field at the index to the image, and returns false if the page does not have the capacity for
+// it. The image of a field which does not exist is not applied at an index that the page does not have, because
+// the field was never added. No overflow chain is freed, so an image can be applied more than once.
+func (image fieldImage) applyTo(page *buffer.Page, index int) (bool, error) {
+	if image.typeDescription.Equals(buffer.TypeTombstone) && index >= page.NumberOfFields() {
+		return true, nil
+	}
+	return page.TryMutateStored(index, image.typeDescription, image.encoded)
+}
+
+// fitsIn returns whether applyTo would set the field at the index of the page to the image, without modifying the
+// page.
+func (image fieldImage) fitsIn(page *buffer.Page, index int) (bool, error) {
+	if image.typeDescription.Equals(buffer.TypeTombstone) && index >= page.NumberOfFields() {
+		return true, nil
+	}
+	return page.HasCapacityForStored(index, image.typeDescription, image.encoded)
+}
+
+func compareBlockIds(blockId, otherBlockId file.BlockId) int {
+	if comparison := strings.Compare(blockId.FileName(), otherBlockId.FileName()); comparison != 0 {
+		return comparison
+	}
+	return cmp.Compare(blockId.BlockNumber(), otherBlockId.BlockNumber())
+}
+
+func decodeLogRecord(encoded []byte) (logRecord, error) {
+	decoder := &logRecordDecoder{buffer: encoded}
+	recordType := logRecordType(decoder.uint8())
+	transactionNumber := int(decoder.uint64())
+
+	switch recordType {
+	case logRecordStart:
+		return startRecord{txNumber: transactionNumber}, nil
+	case logRecordCommit:
+		return commitRecord{txNumber: transactionNumber}, nil
+	case logRecordRollback:
+		return rollbackRecord{txNumber: transactionNumber}, nil
+	case logRecordBeginCheckpoint:
+		return beginCheckpointRecord{lastTransactionNumber: int(decoder.uint64())}, nil
+	case logRecordCheckpointTransactions:
+		return decoder.checkpointTransactionsRecord(), nil
+	case logRecordCheckpointDirtyPages:
+		return decoder.checkpointDirtyPagesRecord(), nil
+	case logRecordEndCheckpoint:
+		return endCheckpointRecord{beginLogSequenceNumber: uint(decoder.uint64())}, nil
+	case logRecordUpdate:
+		return updateRecord{
+			txNumber:                  transactionNumber,
+			previousLogSequenceNumber: uint(decoder.uint64()),
+			blockId:                   decoder.blockId(),
+			index:                     int(decoder.uint32()),
+			beforeImage:               decoder.fieldImage(),
+			afterImage:                decoder.fieldImage(),
+		}, nil
+	case logRecordCompensation:
+		return compensationRecord{
+			txNumber:                  transactionNumber,
+			blockId:                   decoder.blockId(),
+			index:                     int(decoder.uint32()),
+			image:                     decoder.fieldImage(),
+			undoNextLogSequenceNumber: uint(decoder.uint64()),
+		}, nil
+	case logRecordPrepare:
+		return prepareRecord{
+			txNumber:                  transactionNumber,
+			previousLogSequenceNumber: uint(decoder.uint64()),
+			globalTransactionId:       string(decoder.byteSlice()),
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %v", UnknownLogRecordError, recordType.AsString())
+}
+
+type logRecordEncoder struct {
+	buffer []byte
+}
+
+func newLogRecordEncoder(recordType logRecordType, transactionNumber int) *logRecordEncoder {
+	encoder := &logRecordEncoder{}
+	encoder.putUint8(uint8(recordType))
+	encoder.putUint64(uint64(transactionNumber))
+	return encoder
+}
+
+func (encoder *logRecordEncoder) putUint8(value uint8) {
+	gorel.EncodeUint8(value, encoder.grow(gorel.BytesNeededForEncodingAnUint8()), 0)
+}
+
+func (encoder *logRecordEncoder) putUint32(value uint32) {
+	gorel.EncodeUint32(value, encoder.grow(gorel.BytesNeededForEncodingAnUint32()), 0)
+}
+
+func (encoder *logRecordEncoder) putUint64(value uint64) {
+	gorel.EncodeUint64(value, encoder.grow(gorel.BytesNeededForEncodingAnUint64()), 0)
+}
+
+func (encoder *logRecordEncoder) putBytes(value []byte) {
+	gorel.EncodeByteSlice(value, encoder.grow(gorel.BytesNeededForEncodingAByteSlice(value)), 0)
+}
+
+func (encoder *logRecordEncoder) putBlockId(blockId file.BlockId) {
+	encoder.putBytes([]byte(blockId.FileName()))
+	encoder.putUint64(uint64(blockId.BlockNumber()))
+}
+
+func (encoder *logRecordEncoder) putFieldImage(image fieldImage) {
+	encoder.putUint8(uint8(image.typeDescription))
+	encoder.putBytes(image.encoded)
+}
+
+// grow extends the buffer by bytesNeeded, and returns the extended part.
+func (encoder *logRecordEncoder) grow(bytesNeeded gorel.BytesNeededForEncoding) []byte {
+	length := len(encoder.buffer)
+	encoder.buffer = append(encoder.buffer, make([]byte, bytesNeeded)...)
+	return encoder.buffer[length:]
+}
+
+func (encoder *logRecordEncoder) bytes() []byte {
+	return encoder.buffer
+}
+
+type logRecordDecoder struct {
+	buffer []byte
+	offset uint32
+}
+
+func (decoder *logRecordDecoder) uint8() uint8 {
+	value, endOffset := gorel.DecodeUint8(decoder.buffer, decoder.offset)
+	decoder.offset = endOffset
+	return value
+}
+
+func (decoder *logRecordDecoder) uint32() uint32 {
+	value, endOffset := gorel.DecodeUint32(decoder.buffer, decoder.offset)
+	decoder.offset = endOffset
+	return value
+}
+
+func (decoder *logRecordDecoder) uint64() uint64 {
+	value, endOffset := gorel.DecodeUint64(decoder.buffer, decoder.offset)
+	decoder.offset = endOffset
+	return value
+}
+
+func (decoder *logRecordDecoder) byteSlice() []byte {
+	value, endOffset := gorel.DecodeByteSlice(decoder.buffer, decoder.offset)
+	decoder.offset = endOffset
+	return value
+}
+
+func (decoder *logRecordDecoder) blockId() file.BlockId {
+	fileName := string(decoder.byteSlice())
+	return file.NewBlockId(fileName, uint(decoder.uint64()))
+}
+
+func (decoder *logRecordDecoder) checkpointTransactionsRecord() checkpointTransactionsRecord {
+	record := checkpointTransactionsRecord{
+		beginLogSequenceNumber: uint(decoder.uint64()),
+		activeTransactions:     make(map[int]uint),
+	}
+	for count := decoder.uint32(); count > 0; count-- {
+		transactionNumber := int(decoder.uint64())
+		record.activeTransactions[transactionNumber] = uint(decoder.uint64())
+	}
+	return record
+}
+
+func (decoder *logRecordDecoder) checkpointDirtyPagesRecord() checkpointDirtyPagesRecord {
+	record := checkpointDirtyPagesRecord{
+		beginLogSequenceNumber: uint(decoder.uint64()),
+		dirtyPages:             make(map[file.BlockId]uint),
+	}
+	for count := decoder.uint32(); count > 0; count-- {
+		blockId := decoder.blockId()
+		record.dirtyPages[blockId] = uint(decoder.uint64())
+	}
+	return record
+}
+
+// fieldImage returns nil as the encoded value of a NULL or a deleted field.
+func (decoder *logRecordDecoder) fieldImage() fieldImage {
+	image := fieldImage{typeDescription: buffer.TypeDescription(decoder.uint8())}
+	if encoded := decoder.byteSlice(); len(encoded) > 0 {
+		image.encoded = slices.Clone(encoded)
+	}
+	return image
+}
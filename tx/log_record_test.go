@@ -0,0 +1,197 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/buffer"
+	"gorel/file"
+	"testing"
+)
+
+func TestEncodeAndDecodeAStartRecord(t *testing.T) {
+	record, err := decodeLogRecord(startRecord{txNumber: 10}.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, startRecord{txNumber: 10}, record)
+}
+
+func TestEncodeAndDecodeACommitRecord(t *testing.T) {
+	record, err := decodeLogRecord(commitRecord{txNumber: 10}.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, logRecordCommit, record.recordType())
+	assert.Equal(t, 10, record.transactionNumber())
+}
+
+func TestEncodeAndDecodeARollbackRecord(t *testing.T) {
+	record, err := decodeLogRecord(rollbackRecord{txNumber: 12}.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, rollbackRecord{txNumber: 12}, record)
+}
+
+func TestEncodeAndDecodeAnUpdateRecord(t *testing.T) {
+	record := updateRecord{
+		txNumber:                  15,
+		previousLogSequenceNumber: 20,
+		blockId:                   file.NewBlockId("employees", 3),
+		index:                     4,
+		beforeImage:               fieldImage{typeDescription: buffer.TypeString, encoded: []byte("RocksDB")},
+		afterImage:                fieldImage{typeDescription: buffer.TypeString, encoded: []byte("PebbleDB")},
+	}
+	decoded, err := decodeLogRecord(record.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, record, decoded)
+}
+
+func TestEncodeAndDecodeAnUpdateRecordWithANullBeforeImage(t *testing.T) {
+	record := updateRecord{
+		txNumber:                  15,
+		previousLogSequenceNumber: 20,
+		blockId:                   file.NewBlockId("employees", 3),
+		index:                     4,
+		beforeImage:               fieldImage{typeDescription: buffer.TypeUint64.WithNull()},
+		afterImage:                fieldImage{typeDescription: buffer.TypeUint64, encoded: []byte{0, 0, 0, 0, 0, 0, 0, 1}},
+	}
+	decoded, err := decodeLogRecord(record.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, record, decoded)
+}
+
+func TestEncodeAndDecodeACompensationRecord(t *testing.T) {
+	record := compensationRecord{
+		txNumber:                  15,
+		blockId:                   file.NewBlockId("employees", 3),
+		index:                     4,
+		image:                     fieldImage{typeDescription: buffer.TypeString, encoded: []byte("RocksDB")},
+		undoNextLogSequenceNumber: 20,
+	}
+	decoded, err := decodeLogRecord(record.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, record, decoded)
+	assert.Equal(t, logRecordCompensation, decoded.recordType())
+}
+
+func TestEncodeAndDecodeAPrepareRecord(t *testing.T) {
+	record := prepareRecord{txNumber: 15, previousLogSequenceNumber: 20, globalTransactionId: "transfer-42"}
+	decoded, err := decodeLogRecord(record.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, record, decoded)
+	assert.Equal(t, logRecordPrepare, decoded.recordType())
+}
+
+func TestAttemptToDecodeAnUnknownLogRecord(t *testing.T) {
+	_, err := decodeLogRecord(make([]byte, 9))
+	assert.ErrorIs(t, err, UnknownLogRecordError)
+}
+
+func TestApplyAFieldImage(t *testing.T) {
+	page := buffer.NewPage(blockSize)
+	page.AddString("PebbleDB")
+
+	applied, err := fieldImage{typeDescription: buffer.TypeString, encoded: []byte("RocksDB")}.applyTo(page, 0)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, "RocksDB", page.GetString(0))
+}
+
+func TestApplyAFieldImageAtTheEndOfThePage(t *testing.T) {
+	page := buffer.NewPage(blockSize)
+	page.AddString("PebbleDB")
+
+	applied, err := fieldImage{typeDescription: buffer.TypeString, encoded: []byte("RocksDB")}.applyTo(page, 1)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, "RocksDB", page.GetString(1))
+}
+
+func TestApplyATombstoneImage(t *testing.T) {
+	page := buffer.NewPage(blockSize)
+	page.AddString("PebbleDB")
+
+	applied, err := fieldImage{typeDescription: buffer.TypeTombstone}.applyTo(page, 0)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	assert.True(t, page.IsDeleted(0))
+
+	applied, err = fieldImage{typeDescription: buffer.TypeTombstone}.applyTo(page, 1)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	assert.Equal(t, 1, page.NumberOfFields())
+}
+
+func TestApplyANullImage(t *testing.T) {
+	page := buffer.NewPage(blockSize)
+	page.AddUint64(10)
+
+	applied, err := fieldImage{typeDescription: buffer.TypeUint64.WithNull()}.applyTo(page, 0)
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	assert.True(t, page.IsNull(0))
+}
+
+func TestEncodeAndDecodeTheRecordsOfACheckpoint(t *testing.T) {
+	records := []logRecord{
+		beginCheckpointRecord{lastTransactionNumber: 17},
+		checkpointTransactionsRecord{beginLogSequenceNumber: 40, activeTransactions: map[int]uint{15: 32, 12: 38}},
+		checkpointDirtyPagesRecord{
+			beginLogSequenceNumber: 40,
+			dirtyPages:             map[file.BlockId]uint{file.NewBlockId("employees", 3): 25, file.NewBlockId("departments", 0): 36},
+		},
+		endCheckpointRecord{beginLogSequenceNumber: 40},
+	}
+	for _, checkpointRecord := range records {
+		record, err := decodeLogRecord(checkpointRecord.encode())
+		assert.Nil(t, err)
+		assert.Equal(t, checkpointRecord, record)
+		assert.Equal(t, noTransactionNumber, record.transactionNumber())
+	}
+	assert.Equal(t, "BEGIN_CKPT", records[0].recordType().AsString())
+	assert.Equal(t, "END_CKPT", records[3].recordType().AsString())
+}
+
+func TestEncodeAndDecodeAnEmptyCheckpointRecord(t *testing.T) {
+	checkpointRecord := checkpointDirtyPagesRecord{dirtyPages: map[file.BlockId]uint{}}
+	record, err := decodeLogRecord(checkpointRecord.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, checkpointRecord, record)
+}
+
+func TestSplitTheTablesOfACheckpointIntoRecordsOfTheMaxRecordSize(t *testing.T) {
+	tables := checkpointTables{
+		beginLogSequenceNumber: 40,
+		activeTransactions:     map[int]uint{},
+		dirtyPages:             map[file.BlockId]uint{},
+	}
+	for transactionNumber := 1; transactionNumber <= 10; transactionNumber++ {
+		tables.activeTransactions[transactionNumber] = uint(transactionNumber + 20)
+	}
+	for blockNumber := uint(0); blockNumber < 30; blockNumber++ {
+		tables.dirtyPages[file.NewBlockId("employees", blockNumber)] = blockNumber + 10
+	}
+
+	const maxRecordSize = 100
+	records := tables.records(maxRecordSize)
+	assert.Greater(t, len(records), 2)
+
+	rebuilt := checkpointTables{
+		beginLogSequenceNumber: 40,
+		activeTransactions:     map[int]uint{},
+		dirtyPages:             map[file.BlockId]uint{},
+	}
+	for _, record := range records {
+		assert.LessOrEqual(t, len(record.encode()), maxRecordSize)
+		rebuilt.add(record)
+	}
+	assert.Equal(t, tables, rebuilt)
+}
+
+func TestSplitEmptyTablesOfACheckpoint(t *testing.T) {
+	tables := checkpointTables{activeTransactions: map[int]uint{}, dirtyPages: map[file.BlockId]uint{}}
+	assert.Empty(t, tables.records(100))
+}
+
+func TestRedoLogSequenceNumberOfACheckpoint(t *testing.T) {
+	checkpoint := checkpointTables{beginLogSequenceNumber: 40, dirtyPages: map[file.BlockId]uint{}}
+	assert.Equal(t, uint(41), checkpoint.redoLogSequenceNumber())
+
+	checkpoint.dirtyPages[file.NewBlockId("employees", 3)] = 25
+	checkpoint.dirtyPages[file.NewBlockId("departments", 0)] = 36
+	assert.Equal(t, uint(25), checkpoint.redoLogSequenceNumber())
+}
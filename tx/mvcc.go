@@ -0,0 +1,278 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"gorel"
+	"gorel/buffer"
+	"gorel/file"
+	"slices"
+)
+
+var (
+	RecordNotFoundError = errors.New("record not found")
+	WriteConflictError  = errors.New("transaction must abort, the record was modified by a transaction which committed after its snapshot")
+)
+
+// versionHeaderSize is the size of the key, the begin and the end transaction numbers which precede the value of a
+// recordVersion.
+var versionHeaderSize = 3 * gorel.BytesNeededForEncodingAnUint64()
+
+// recordVersion is a version of the record of a key, stored as a byte slice field of a page. It is created by the
+// begin transaction, and ended by the end transaction which modifies or deletes the record; the end is
+// noTransactionNumber while the version is the live one. The versions of a key are added in the order in which they
+// are created, so the latest version of a key is the one with the highest index.
+type recordVersion struct {
+	key   uint64
+	begin int
+	end   int
+	value []byte
+}
+
+func (version recordVersion) encode() []byte {
+	encoded := make([]byte, versionHeaderSize+uint(len(version.value)))
+	offset := gorel.EncodeUint64(version.key, encoded, 0)
+	offset += gorel.EncodeUint64(uint64(version.begin), encoded, offset)
+	offset += gorel.EncodeUint64(uint64(version.end), encoded, offset)
+	copy(encoded[offset:], version.value)
+	return encoded
+}
+
+func decodeRecordVersion(encoded []byte) recordVersion {
+	key, offset := gorel.DecodeUint64(encoded, 0)
+	begin, offset := gorel.DecodeUint64(encoded, offset)
+	end, offset := gorel.DecodeUint64(encoded, offset)
+	return recordVersion{key: key, begin: int(begin), end: int(end), value: encoded[offset:]}
+}
+
+func (version recordVersion) isLive() bool {
+	return version.end == noTransactionNumber
+}
+
+// ReadRecord returns the value of the record of the key in the pinned block, as of the snapshot of the transaction:
+// the version created by a visible transaction and not ended by one. It takes no lock, and latches the buffer only
+// while it reads the page. It returns RecordNotFoundError if no such version exists.
+func (transaction *Transaction) ReadRecord(blockId file.BlockId, key uint64) ([]byte, error) {
+	pinnedBuffer, err := transaction.pinnedBufferFor(blockId)
+	if err != nil {
+		return nil, err
+	}
+	pinnedBuffer.LatchShared()
+	defer pinnedBuffer.UnlatchShared()
+
+	versions, err := versionsOf(pinnedBuffer.Page(), key)
+	if err != nil {
+		return nil, err
+	}
+	for _, indexedVersion := range versions {
+		version := indexedVersion.version
+		if transaction.sees(version.begin) && (version.isLive() || !transaction.sees(version.end)) {
+			return slices.Clone(version.value), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: key %d in block %v:%v", RecordNotFoundError, key, blockId.FileName(), blockId.BlockNumber())
+}
+
+// WriteRecord creates a new version of the record of the key in the pinned block, and ends the live version if there
+// is one. The transaction takes an exclusive lock on the block, so a transaction which writes a record that a
+// concurrent transaction has written waits for it to finish. It gets WriteConflictError, and must roll back, if the
+// latest version of the record was created or ended by a transaction which committed after its snapshot: the first
+// committer wins.
+func (transaction *Transaction) WriteRecord(blockId file.BlockId, key uint64, value []byte) error {
+	return transaction.modifyRecord(blockId, key, func(pinnedBuffer *buffer.Buffer, latest *indexedVersion) error {
+		if latest != nil && latest.version.isLive() {
+			if err := transaction.endVersion(pinnedBuffer, *latest); err != nil {
+				return err
+			}
+		}
+		newVersion := recordVersion{key: key, begin: transaction.transactionNumber, end: noTransactionNumber, value: value}
+		return transaction.update(
+			pinnedBuffer,
+			pinnedBuffer.Page().NumberOfFields(),
+			fieldImage{typeDescription: buffer.TypeByteSlice, encoded: newVersion.encode()},
+		)
+	})
+}
+
+// DeleteRecord ends the live version of the record of the key in the pinned block, like WriteRecord, and returns
+// RecordNotFoundError if the record has no live version.
+func (transaction *Transaction) DeleteRecord(blockId file.BlockId, key uint64) error {
+	return transaction.modifyRecord(blockId, key, func(pinnedBuffer *buffer.Buffer, latest *indexedVersion) error {
+		if latest == nil || !latest.version.isLive() {
+			return fmt.Errorf("%w: key %d in block %v:%v", RecordNotFoundError, key, blockId.FileName(), blockId.BlockNumber())
+		}
+		return transaction.endVersion(pinnedBuffer, *latest)
+	})
+}
+
+// modifyRecord takes an exclusive lock on the block and latches its buffer, checks the latest version of the record
+// of the key for a write conflict, and runs the modification with the latest version, which is nil if the key has
+// no version.
+func (transaction *Transaction) modifyRecord(
+	blockId file.BlockId,
+	key uint64,
+	modify func(pinnedBuffer *buffer.Buffer, latest *indexedVersion) error,
+) error {
+	pinnedBuffer, err := transaction.pinnedBufferFor(blockId)
+	if err != nil {
+		return err
+	}
+	if err := transaction.lockTable.XLock(transaction.transactionNumber, blockId); err != nil {
+		return err
+	}
+	pinnedBuffer.Latch()
+	defer pinnedBuffer.Unlatch()
+
+	versions, err := versionsOf(pinnedBuffer.Page(), key)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return modify(pinnedBuffer, nil)
+	}
+	latest := versions[len(versions)-1]
+	if !transaction.sees(latest.version.begin) || (!latest.version.isLive() && !transaction.sees(latest.version.end)) {
+		return fmt.Errorf("%w: key %d in block %v:%v", WriteConflictError, key, blockId.FileName(), blockId.BlockNumber())
+	}
+	return modify(pinnedBuffer, &latest)
+}
+
+// endVersion stamps the transaction as the end of the version.
+func (transaction *Transaction) endVersion(pinnedBuffer *buffer.Buffer, ended indexedVersion) error {
+	ended.version.end = transaction.transactionNumber
+	return transaction.update(
+		pinnedBuffer,
+		ended.index,
+		fieldImage{typeDescription: buffer.TypeByteSlice, encoded: ended.version.encode()},
+	)
+}
+
+// sees returns true if the modifications of the writer are visible in the snapshot of the transaction.
+func (transaction *Transaction) sees(writer int) bool {
+	return transaction.transactionManager.isVisible(writer, transaction)
+}
+
+// Vacuum removes the dead versions from the blocks of the file: the versions ended by a transaction which committed
+// before the snapshots of all the active transactions, and which therefore no transaction reads again. It removes
+// them in a transaction of its own, and returns the number of versions it removed.
+func (transactionManager *TransactionManager) Vacuum(fileName string) (int, error) {
+	transaction, err := transactionManager.NewTransaction()
+	if err != nil {
+		return 0, err
+	}
+	removed, err := transaction.vacuum(fileName)
+	if err != nil {
+		return 0, errors.Join(err, transaction.Rollback())
+	}
+	return removed, transaction.Commit()
+}
+
+func (transaction *Transaction) vacuum(fileName string) (int, error) {
+	numberOfBlocks, err := transaction.Size(fileName)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for blockNumber := uint(0); blockNumber < numberOfBlocks; blockNumber++ {
+		blockId := file.NewBlockId(fileName, blockNumber)
+		if err := transaction.Pin(blockId); err != nil {
+			return removed, err
+		}
+		removedFromBlock, err := transaction.vacuumBlock(blockId)
+		removed += removedFromBlock
+		if err != nil {
+			return removed, err
+		}
+		transaction.Unpin(blockId)
+	}
+	return removed, nil
+}
+
+func (transaction *Transaction) vacuumBlock(blockId file.BlockId) (int, error) {
+	pinnedBuffer, err := transaction.pinnedBufferFor(blockId)
+	if err != nil {
+		return 0, err
+	}
+	if err := transaction.lockTable.XLock(transaction.transactionNumber, blockId); err != nil {
+		return 0, err
+	}
+	pinnedBuffer.Latch()
+	defer pinnedBuffer.Unlatch()
+
+	page := pinnedBuffer.Page()
+	removed := 0
+	for index := 0; index < page.NumberOfFields(); index++ {
+		if page.IsDeleted(index) {
+			continue
+		}
+		version, err := versionAt(page, index)
+		if err != nil {
+			return removed, err
+		}
+		if version.isLive() || !transaction.transactionManager.isDead(version) {
+			continue
+		}
+		if err := transaction.update(pinnedBuffer, index, fieldImage{typeDescription: buffer.TypeTombstone}); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// isDead returns true if the version was ended by a transaction which committed at or before the snapshots of all
+// the active transactions, and forgets the commit timestamps which precede all the snapshots.
+func (transactionManager *TransactionManager) isDead(version recordVersion) bool {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	oldestSnapshotTimestamp := transactionManager.lastCommitTimestamp
+	for _, transaction := range transactionManager.activeTransactions {
+		oldestSnapshotTimestamp = min(oldestSnapshotTimestamp, transaction.snapshotTimestamp)
+	}
+	for transactionNumber, commitTimestamp := range transactionManager.commitTimestamps {
+		if commitTimestamp <= oldestSnapshotTimestamp {
+			delete(transactionManager.commitTimestamps, transactionNumber)
+		}
+	}
+	if _, active := transactionManager.activeTransactions[version.end]; active {
+		return false
+	}
+	_, ok := transactionManager.commitTimestamps[version.end]
+	return !ok
+}
+
+// indexedVersion is a recordVersion along with the index of its field in the page.
+type indexedVersion struct {
+	index   int
+	version recordVersion
+}
+
+// versionsOf returns the versions of the key in the page, in the order in which they were created.
+func versionsOf(page *buffer.Page, key uint64) ([]indexedVersion, error) {
+	var versions []indexedVersion
+	for index := 0; index < page.NumberOfFields(); index++ {
+		if page.IsDeleted(index) {
+			continue
+		}
+		version, err := versionAt(page, index)
+		if err != nil {
+			return nil, err
+		}
+		if version.key == key {
+			versions = append(versions, indexedVersion{index: index, version: version})
+		}
+	}
+	return versions, nil
+}
+
+func versionAt(page *buffer.Page, index int) (recordVersion, error) {
+	encoded, err := page.TryGetBytes(index)
+	if err != nil {
+		return recordVersion{}, err
+	}
+	if uint(len(encoded)) < versionHeaderSize {
+		return recordVersion{}, fmt.Errorf("%w, the field at index %d is not a record version", buffer.ErrTypeMismatch, index)
+	}
+	return decodeRecordVersion(encoded), nil
+}
@@ -0,0 +1,273 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"testing"
+)
+
+// writeRecordsAndCommit writes the records in a new block of a transaction which commits.
+func writeRecordsAndCommit(t *testing.T, database database, records map[uint64]string) file.BlockId {
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	for key, value := range records {
+		assert.Nil(t, transaction.WriteRecord(blockId, key, []byte(value)))
+	}
+	assert.Nil(t, transaction.Commit())
+	return blockId
+}
+
+func assertRecord(t *testing.T, transaction *Transaction, blockId file.BlockId, key uint64, expected string) {
+	value, err := transaction.ReadRecord(blockId, key)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(value))
+}
+
+func TestReadRecordsInTheSnapshotOfTheTransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB", 2: "etcd"})
+
+	reader := database.newTransaction(t)
+	writer := database.newTransaction(t)
+	assert.Nil(t, reader.Pin(blockId))
+	assert.Nil(t, writer.Pin(blockId))
+
+	assert.Nil(t, writer.WriteRecord(blockId, 1, []byte("RocksDB")))
+	assert.Nil(t, writer.WriteRecord(blockId, 3, []byte("Pebble")))
+	assertRecord(t, writer, blockId, 1, "RocksDB")
+	assertRecord(t, reader, blockId, 1, "BoltDB")
+
+	assert.Nil(t, writer.Commit())
+	assertRecord(t, reader, blockId, 1, "BoltDB")
+	assertRecord(t, reader, blockId, 2, "etcd")
+	_, err = reader.ReadRecord(blockId, 3)
+	assert.ErrorIs(t, err, RecordNotFoundError)
+	assert.Nil(t, reader.Commit())
+
+	laterReader := database.newTransaction(t)
+	assert.Nil(t, laterReader.Pin(blockId))
+	assertRecord(t, laterReader, blockId, 1, "RocksDB")
+	assertRecord(t, laterReader, blockId, 3, "Pebble")
+	assert.Nil(t, laterReader.Commit())
+}
+
+func TestAttemptToWriteARecordWrittenByATransactionWhichCommittedAfterTheSnapshot(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB"})
+
+	transaction := database.newTransaction(t)
+	otherTransaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, otherTransaction.Pin(blockId))
+
+	assert.Nil(t, otherTransaction.DeleteRecord(blockId, 1))
+	assert.Nil(t, otherTransaction.Commit())
+
+	assert.ErrorIs(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")), WriteConflictError)
+	assertRecord(t, transaction, blockId, 1, "BoltDB")
+	assert.Nil(t, transaction.Rollback())
+}
+
+func TestTheFirstCommitterWinsAmongConcurrentWriters(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB"})
+
+	transaction := database.newTransaction(t)
+	otherTransaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, otherTransaction.Pin(blockId))
+
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	result := acquireInBackground(func() error {
+		return otherTransaction.WriteRecord(blockId, 1, []byte("Pebble"))
+	})
+	waitUntilWaiting(t, database.transactionManager.lockTable, otherTransaction.TransactionNumber())
+
+	assert.Nil(t, transaction.Commit())
+	assert.ErrorIs(t, <-result, WriteConflictError)
+	assert.Nil(t, otherTransaction.Rollback())
+}
+
+func TestWriteARecordAfterTheConcurrentWriterRollsBack(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB"})
+
+	transaction := database.newTransaction(t)
+	otherTransaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, otherTransaction.Pin(blockId))
+
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	result := acquireInBackground(func() error {
+		return otherTransaction.WriteRecord(blockId, 1, []byte("Pebble"))
+	})
+	waitUntilWaiting(t, database.transactionManager.lockTable, otherTransaction.TransactionNumber())
+
+	assert.Nil(t, transaction.Rollback())
+	assert.Nil(t, <-result)
+	assertRecord(t, otherTransaction, blockId, 1, "Pebble")
+	assert.Nil(t, otherTransaction.Commit())
+}
+
+func TestDeleteARecordAndWriteItAgain(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB"})
+
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.DeleteRecord(blockId, 1))
+	_, err = transaction.ReadRecord(blockId, 1)
+	assert.ErrorIs(t, err, RecordNotFoundError)
+	assert.ErrorIs(t, transaction.DeleteRecord(blockId, 1), RecordNotFoundError)
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	assertRecord(t, transaction, blockId, 1, "RocksDB")
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestRollbackRemovesTheVersionsOfTheTransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB"})
+
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	assert.Nil(t, transaction.WriteRecord(blockId, 2, []byte("Pebble")))
+	assert.Nil(t, transaction.Rollback())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assertRecord(t, transaction, blockId, 1, "BoltDB")
+	_, err = transaction.ReadRecord(blockId, 2)
+	assert.ErrorIs(t, err, RecordNotFoundError)
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("etcd")))
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestVacuumRemovesTheVersionsWhichNoSnapshotReads(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB", 2: "etcd"})
+
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	assert.Nil(t, transaction.Commit())
+
+	reader := database.newTransaction(t)
+	assert.Nil(t, reader.Pin(blockId))
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.DeleteRecord(blockId, 2))
+	assert.Nil(t, transaction.Commit())
+
+	removed, err := database.transactionManager.Vacuum(t.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, removed)
+	assertRecord(t, reader, blockId, 1, "RocksDB")
+	assertRecord(t, reader, blockId, 2, "etcd")
+	assert.Nil(t, reader.Commit())
+
+	removed, err = database.transactionManager.Vacuum(t.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, removed)
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assertRecord(t, transaction, blockId, 1, "RocksDB")
+	_, err = transaction.ReadRecord(blockId, 2)
+	assert.ErrorIs(t, err, RecordNotFoundError)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestRecoverAfterACrashRemovesTheVersionsOfTheTransactionsWhichDidNotCommit(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB"})
+
+	uncommittedTransaction := database.newTransaction(t)
+	assert.Nil(t, uncommittedTransaction.Pin(blockId))
+	assert.Nil(t, uncommittedTransaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	assert.Nil(t, database.bufferManager.FlushAll(uncommittedTransaction.TransactionNumber()))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	transaction := restartedDatabase.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assertRecord(t, transaction, blockId, 1, "BoltDB")
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("Pebble")))
+	assertRecord(t, transaction, blockId, 1, "Pebble")
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestReadTheRecordsCommittedBeforeARestartWhileNewTransactionsAreActive(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := writeRecordsAndCommit(t, database, map[uint64]string{1: "BoltDB", 2: "etcd"})
+
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.WriteRecord(blockId, 1, []byte("RocksDB")))
+	assert.Nil(t, transaction.Commit())
+	lastTransactionNumber := transaction.TransactionNumber()
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	for restart := 0; restart < 2; restart++ {
+		restartedDatabase := newDatabase(t, fileManager)
+		assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+		writer := restartedDatabase.newTransaction(t)
+		reader := restartedDatabase.newTransaction(t)
+		assert.Greater(t, writer.TransactionNumber(), lastTransactionNumber)
+		assert.Nil(t, writer.Pin(blockId))
+		assert.Nil(t, reader.Pin(blockId))
+
+		assert.Nil(t, writer.WriteRecord(blockId, 2, []byte("Pebble")))
+		assertRecord(t, reader, blockId, 1, "RocksDB")
+		assertRecord(t, reader, blockId, 2, "etcd")
+		assertRecord(t, writer, blockId, 1, "RocksDB")
+		assert.Nil(t, writer.Rollback())
+		assert.Nil(t, reader.Commit())
+		assert.Nil(t, restartedDatabase.bufferManager.FlushAllModified())
+		lastTransactionNumber = reader.TransactionNumber()
+	}
+}
@@ -0,0 +1,519 @@
+package tx
+
+import (
+	"fmt"
+	"gorel/buffer"
+	"gorel/file"
+	"gorel/log"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// recoveryManager logs the modifications of a transaction, and recovers from a crash the ARIES way. The modified
+// buffers are not written when a transaction commits, so the recovery repeats the history by redoing the logged
+// modifications that did not reach the disk, and then undoes the transactions which did not finish. Every undone
+// update is compensated by a compensation log record, so an update is never undone twice.
+//
+// The lock is held while a log record of the transaction is appended and applied to its page, so a checkpoint sees
+// either both or neither.
+type recoveryManager struct {
+	transactionNumber      int
+	firstLogSequenceNumber uint
+	lastLogSequenceNumber  uint
+	finished               bool
+	logManager             *log.BlockLogManager
+	bufferManager          *buffer.BufferManager
+	lock                   sync.Mutex
+}
+
+// inDoubtTransaction is a transaction which prepared, and neither committed nor rolled back before a crash. The
+// recovery finds its START record and the blocks it modified, so that it is restored with exclusive locks on them.
+type inDoubtTransaction struct {
+	transactionNumber      int
+	globalTransactionId    string
+	firstLogSequenceNumber uint
+	lastLogSequenceNumber  uint
+	modifiedBlocks         map[file.BlockId]struct{}
+}
+
+// loggedRecord is a log record along with its log sequence number.
+type loggedRecord struct {
+	logSequenceNumber uint
+	record            logRecord
+}
+
+// startRecoveryManager appends a START record for the transaction.
+func startRecoveryManager(
+	transactionNumber int,
+	logManager *log.BlockLogManager,
+	bufferManager *buffer.BufferManager,
+) (*recoveryManager, error) {
+	logSequenceNumber, err := logManager.Append(startRecord{txNumber: transactionNumber}.encode())
+	if err != nil {
+		return nil, err
+	}
+	return newRecoveryManager(transactionNumber, logSequenceNumber, logSequenceNumber, logManager, bufferManager), nil
+}
+
+// newRecoveryManager creates the recovery manager of a transaction whose log records span from its START record at
+// firstLogSequenceNumber to its latest record at lastLogSequenceNumber.
+func newRecoveryManager(
+	transactionNumber int,
+	firstLogSequenceNumber uint,
+	lastLogSequenceNumber uint,
+	logManager *log.BlockLogManager,
+	bufferManager *buffer.BufferManager,
+) *recoveryManager {
+	return &recoveryManager{
+		transactionNumber:      transactionNumber,
+		firstLogSequenceNumber: firstLogSequenceNumber,
+		lastLogSequenceNumber:  lastLogSequenceNumber,
+		logManager:             logManager,
+		bufferManager:          bufferManager,
+	}
+}
+
+// logUpdate links the update record to the previous log record of the transaction, appends it, and applies it by
+// running apply with its log sequence number.
+func (recoveryManager *recoveryManager) logUpdate(record updateRecord, apply func(logSequenceNumber uint) error) error {
+	recoveryManager.lock.Lock()
+	defer recoveryManager.lock.Unlock()
+
+	record.previousLogSequenceNumber = recoveryManager.lastLogSequenceNumber
+	logSequenceNumber, err := recoveryManager.logManager.Append(record.encode())
+	if err != nil {
+		return err
+	}
+	recoveryManager.lastLogSequenceNumber = logSequenceNumber
+	return apply(logSequenceNumber)
+}
+
+// commit appends a COMMIT record and flushes the log. The buffers modified by the transaction are written later,
+// when they are chosen for replacement.
+func (recoveryManager *recoveryManager) commit() error {
+	logSequenceNumber, err := recoveryManager.appendFinalRecord(commitRecord{txNumber: recoveryManager.transactionNumber})
+	if err != nil {
+		return err
+	}
+	return recoveryManager.logManager.Flush(logSequenceNumber)
+}
+
+// prepare appends a PREPARE record and flushes the log, so that the transaction can still be committed or rolled back
+// after a crash.
+func (recoveryManager *recoveryManager) prepare(globalTransactionId string) error {
+	recoveryManager.lock.Lock()
+	defer recoveryManager.lock.Unlock()
+
+	logSequenceNumber, err := recoveryManager.logManager.Append(prepareRecord{
+		txNumber:                  recoveryManager.transactionNumber,
+		previousLogSequenceNumber: recoveryManager.lastLogSequenceNumber,
+		globalTransactionId:       globalTransactionId,
+	}.encode())
+	if err != nil {
+		return err
+	}
+	recoveryManager.lastLogSequenceNumber = logSequenceNumber
+	return recoveryManager.logManager.Flush(logSequenceNumber)
+}
+
+// rollback undoes the updates of the transaction, following the chain of its log records back to its START record,
+// and appends a ROLLBACK record.
+func (recoveryManager *recoveryManager) rollback() error {
+	return recoveryManager.undo(map[int]uint{recoveryManager.transactionNumber: recoveryManager.lastLogSequenceNumber}, buffer.NoLogSequenceNumber)
+}
+
+// savepoint returns the log sequence number of the latest log record of the transaction, which rollbackTo rolls
+// back to.
+func (recoveryManager *recoveryManager) savepoint() uint {
+	return recoveryManager.lastLogSequenceNumber
+}
+
+// rollbackTo undoes the updates of the transaction which follow the log record at the log sequence number of a
+// savepoint. The transaction does not finish, and its later log records are linked to the last compensation record,
+// whose undo-next log sequence number skips the undone updates if the transaction rolls back or a crash interrupts
+// it.
+func (recoveryManager *recoveryManager) rollbackTo(savepointLogSequenceNumber uint) error {
+	return recoveryManager.undo(map[int]uint{recoveryManager.transactionNumber: recoveryManager.lastLogSequenceNumber}, savepointLogSequenceNumber)
+}
+
+// recover runs the analysis, the redo and the undo passes over the log records from the latest checkpoint, and
+// writes all the modified buffers. The transactions in doubt are not undone, and are returned to be restored. It
+// must run before any other transaction starts.
+func (recoveryManager *recoveryManager) recover() ([]*inDoubtTransaction, error) {
+	checkpoint, records, err := recoveryManager.recordsFromTheLatestCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	activeTransactions, dirtyPages := recoveryManager.analyze(checkpoint, records)
+	if err := recoveryManager.redo(records, dirtyPages); err != nil {
+		return nil, err
+	}
+	inDoubtTransactions, err := recoveryManager.findInDoubt(activeTransactions)
+	if err != nil {
+		return nil, err
+	}
+	for _, inDoubt := range inDoubtTransactions {
+		delete(activeTransactions, inDoubt.transactionNumber)
+	}
+	if err := recoveryManager.undo(activeTransactions, buffer.NoLogSequenceNumber); err != nil {
+		return nil, err
+	}
+	return inDoubtTransactions, recoveryManager.bufferManager.FlushAllModified()
+}
+
+// findInDoubt walks the log backward, and returns the unfinished transactions whose latest log record is a PREPARE
+// record, along with the blocks they modified and the log sequence numbers of their START records.
+func (recoveryManager *recoveryManager) findInDoubt(activeTransactions map[int]uint) ([]*inDoubtTransaction, error) {
+	if len(activeTransactions) == 0 {
+		return nil, nil
+	}
+	var earliestLastLogSequenceNumber uint
+	for _, lastLogSequenceNumber := range activeTransactions {
+		if earliestLastLogSequenceNumber == buffer.NoLogSequenceNumber || lastLogSequenceNumber < earliestLastLogSequenceNumber {
+			earliestLastLogSequenceNumber = lastLogSequenceNumber
+		}
+	}
+	inDoubtTransactions := make(map[int]*inDoubtTransaction)
+	withoutStart := 0
+
+	if err := recoveryManager.forEachLogRecordBackward(func(logSequenceNumber uint, record logRecord) (bool, error) {
+		transactionNumber := record.transactionNumber()
+		if inDoubt, ok := inDoubtTransactions[transactionNumber]; ok {
+			switch record := record.(type) {
+			case startRecord:
+				inDoubt.firstLogSequenceNumber = logSequenceNumber
+				withoutStart--
+			case updateRecord:
+				inDoubt.modifiedBlocks[record.blockId] = struct{}{}
+			case compensationRecord:
+				inDoubt.modifiedBlocks[record.blockId] = struct{}{}
+			}
+		} else if prepare, ok := record.(prepareRecord); ok && activeTransactions[transactionNumber] == logSequenceNumber {
+			inDoubtTransactions[transactionNumber] = &inDoubtTransaction{
+				transactionNumber:     transactionNumber,
+				globalTransactionId:   prepare.globalTransactionId,
+				lastLogSequenceNumber: logSequenceNumber,
+				modifiedBlocks:        make(map[file.BlockId]struct{}),
+			}
+			withoutStart++
+		}
+		return logSequenceNumber > earliestLastLogSequenceNumber || withoutStart > 0, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	transactionNumbers := make([]int, 0, len(inDoubtTransactions))
+	for transactionNumber := range inDoubtTransactions {
+		transactionNumbers = append(transactionNumbers, transactionNumber)
+	}
+	slices.Sort(transactionNumbers)
+	result := make([]*inDoubtTransaction, 0, len(transactionNumbers))
+	for _, transactionNumber := range transactionNumbers {
+		result = append(result, inDoubtTransactions[transactionNumber])
+	}
+	return result, nil
+}
+
+// lastTransactionNumberInTheLog walks the log backward to the latest BEGIN_CKPT record, and returns the highest
+// transaction number of the checkpoint and of the log records after it.
+func lastTransactionNumberInTheLog(logManager *log.BlockLogManager) (int, error) {
+	lastTransactionNumber := noTransactionNumber
+	recoveryManager := &recoveryManager{logManager: logManager}
+	err := recoveryManager.forEachLogRecordBackward(func(_ uint, record logRecord) (bool, error) {
+		lastTransactionNumber = max(lastTransactionNumber, record.transactionNumber())
+		if checkpoint, ok := record.(beginCheckpointRecord); ok {
+			lastTransactionNumber = max(lastTransactionNumber, checkpoint.lastTransactionNumber)
+			return false, nil
+		}
+		return true, nil
+	})
+	return lastTransactionNumber, err
+}
+
+// recordsFromTheLatestCheckpoint walks the log backward to the latest checkpoint which ended, rebuilding its tables
+// from its records, and further back to the log sequence number which the redo starts at. It returns the tables,
+// which are empty if the log has no checkpoint, and the log records from the earliest to the latest.
+func (recoveryManager *recoveryManager) recordsFromTheLatestCheckpoint() (checkpointTables, []loggedRecord, error) {
+	var records []loggedRecord
+	tables := checkpointTables{activeTransactions: make(map[int]uint), dirtyPages: make(map[file.BlockId]uint)}
+	ended, found := false, false
+
+	if err := recoveryManager.forEachLogRecordBackward(func(logSequenceNumber uint, record logRecord) (bool, error) {
+		if found && logSequenceNumber < tables.redoLogSequenceNumber() {
+			return false, nil
+		}
+		switch record := record.(type) {
+		case endCheckpointRecord:
+			if !ended {
+				tables.beginLogSequenceNumber, ended = record.beginLogSequenceNumber, true
+			}
+		case checkpointTransactionsRecord:
+			if ended && !found && record.beginLogSequenceNumber == tables.beginLogSequenceNumber {
+				tables.add(record)
+			}
+		case checkpointDirtyPagesRecord:
+			if ended && !found && record.beginLogSequenceNumber == tables.beginLogSequenceNumber {
+				tables.add(record)
+			}
+		case beginCheckpointRecord:
+			if ended && !found && logSequenceNumber == tables.beginLogSequenceNumber {
+				tables.lastTransactionNumber, found = record.lastTransactionNumber, true
+			}
+		}
+		records = append(records, loggedRecord{logSequenceNumber: logSequenceNumber, record: record})
+		return true, nil
+	}); err != nil {
+		return checkpointTables{}, nil, err
+	}
+	if !found {
+		tables = checkpointTables{}
+	}
+	slices.Reverse(records)
+	return tables, records, nil
+}
+
+// analyze returns the latest log sequence number of each transaction which did not finish, and the dirty page
+// table: the log sequence number of the earliest update of each block which may not have reached the disk. It starts
+// from the tables of the checkpoint, and analyzes the log records after the checkpoint began.
+func (recoveryManager *recoveryManager) analyze(
+	checkpoint checkpointTables,
+	records []loggedRecord,
+) (map[int]uint, map[file.BlockId]uint) {
+	activeTransactions := make(map[int]uint)
+	maps.Copy(activeTransactions, checkpoint.activeTransactions)
+	dirtyPages := make(map[file.BlockId]uint)
+	maps.Copy(dirtyPages, checkpoint.dirtyPages)
+
+	markDirty := func(blockId file.BlockId, logSequenceNumber uint) {
+		if _, ok := dirtyPages[blockId]; !ok {
+			dirtyPages[blockId] = logSequenceNumber
+		}
+	}
+	for _, logged := range records {
+		if logged.logSequenceNumber <= checkpoint.beginLogSequenceNumber {
+			continue
+		}
+		transactionNumber := logged.record.transactionNumber()
+		switch record := logged.record.(type) {
+		case startRecord:
+			activeTransactions[transactionNumber] = logged.logSequenceNumber
+		case commitRecord, rollbackRecord:
+			delete(activeTransactions, transactionNumber)
+		case updateRecord:
+			activeTransactions[transactionNumber] = logged.logSequenceNumber
+			markDirty(record.blockId, logged.logSequenceNumber)
+		case compensationRecord:
+			activeTransactions[transactionNumber] = logged.logSequenceNumber
+			markDirty(record.blockId, logged.logSequenceNumber)
+		case prepareRecord:
+			activeTransactions[transactionNumber] = logged.logSequenceNumber
+		}
+	}
+	delete(activeTransactions, recoveryManager.transactionNumber)
+	return activeTransactions, dirtyPages
+}
+
+// redo repeats the history: it applies the after-image of every update and the image of every compensation to the
+// page, unless the page already has it. Only the updates which fit in their page are logged, so an image which does
+// not fit means that the page diverged from the log, and fails the redo. The image of a large value is the pointer to
+// its overflow chain, which replaces the field of a stale page without freeing the chain that the field points to:
+// that chain was freed when the value was replaced before the crash, and may have been reused since.
+func (recoveryManager *recoveryManager) redo(records []loggedRecord, dirtyPages map[file.BlockId]uint) error {
+	for _, logged := range records {
+		var err error
+		switch record := logged.record.(type) {
+		case updateRecord:
+			err = recoveryManager.redoImage(logged.logSequenceNumber, record.txNumber, record.blockId, record.index, record.afterImage, dirtyPages)
+		case compensationRecord:
+			err = recoveryManager.redoImage(logged.logSequenceNumber, record.txNumber, record.blockId, record.index, record.image, dirtyPages)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (recoveryManager *recoveryManager) redoImage(
+	logSequenceNumber uint,
+	transactionNumber int,
+	blockId file.BlockId,
+	index int,
+	image fieldImage,
+	dirtyPages map[file.BlockId]uint,
+) error {
+	if recoveryLogSequenceNumber, dirty := dirtyPages[blockId]; !dirty || logSequenceNumber < recoveryLogSequenceNumber {
+		return nil
+	}
+	redoneBuffer, err := recoveryManager.bufferManager.Pin(blockId)
+	if err != nil {
+		return err
+	}
+	defer recoveryManager.bufferManager.Unpin(redoneBuffer)
+
+	if redoneBuffer.Page().LogSequenceNumber() >= logSequenceNumber {
+		return nil
+	}
+	redone, err := image.applyTo(redoneBuffer.Page(), index)
+	if err != nil {
+		return err
+	}
+	if !redone {
+		return fmt.Errorf("%w: could not redo the log record %d on the field at index %d of block %v:%v",
+			InsufficientCapacityError, logSequenceNumber, index, blockId.FileName(), blockId.BlockNumber(),
+		)
+	}
+	redoneBuffer.SetModified(transactionNumber, logSequenceNumber)
+	return nil
+}
+
+// undo walks the log backward, and undoes the transactions, starting at the log sequence numbers in nextToUndo and
+// following the chains of their log records. It appends a compensation record for each undone update, and a
+// ROLLBACK record for each transaction whose START record is reached. A chain is undone only down to the log record
+// at undoneTo, which is NoLogSequenceNumber unless a savepoint is rolled back to.
+func (recoveryManager *recoveryManager) undo(nextToUndo map[int]uint, undoneTo uint) error {
+	nextToUndo = maps.Clone(nextToUndo)
+	maps.DeleteFunc(nextToUndo, func(_ int, next uint) bool {
+		return next <= undoneTo
+	})
+	if len(nextToUndo) == 0 {
+		return nil
+	}
+	continueUndo := func(transactionNumber int, next uint) {
+		if next <= undoneTo {
+			delete(nextToUndo, transactionNumber)
+			return
+		}
+		nextToUndo[transactionNumber] = next
+	}
+	latestLogSequenceNumber := buffer.NoLogSequenceNumber
+	if err := recoveryManager.forEachLogRecordBackward(func(logSequenceNumber uint, record logRecord) (bool, error) {
+		transactionNumber := record.transactionNumber()
+		if next, ok := nextToUndo[transactionNumber]; !ok || next != logSequenceNumber {
+			return true, nil
+		}
+		var err error
+		switch record := record.(type) {
+		case startRecord:
+			delete(nextToUndo, transactionNumber)
+			if latestLogSequenceNumber, err = recoveryManager.appendFinalRecord(rollbackRecord{txNumber: transactionNumber}); err != nil {
+				return false, err
+			}
+		case updateRecord:
+			if latestLogSequenceNumber, err = recoveryManager.compensate(record); err != nil {
+				return false, err
+			}
+			continueUndo(transactionNumber, record.previousLogSequenceNumber)
+		case compensationRecord:
+			continueUndo(transactionNumber, record.undoNextLogSequenceNumber)
+		case prepareRecord:
+			continueUndo(transactionNumber, record.previousLogSequenceNumber)
+		default:
+			return false, fmt.Errorf("unexpected %v record in the chain of transaction %d", record.recordType().AsString(), transactionNumber)
+		}
+		return len(nextToUndo) > 0, nil
+	}); err != nil {
+		return err
+	}
+	if len(nextToUndo) > 0 {
+		return fmt.Errorf("%w: next log sequence numbers %v", UnfinishedUndoError, nextToUndo)
+	}
+	return recoveryManager.logManager.Flush(latestLogSequenceNumber)
+}
+
+// compensate appends a compensation record for the update, and restores the before-image of the field, pinning the
+// block for the duration of the undo. The buffer is latched before the lock of the recovery manager is taken, the
+// order in which the multi-version records modify their pages. It returns the log sequence number of the
+// compensation record.
+func (recoveryManager *recoveryManager) compensate(record updateRecord) (uint, error) {
+	undoneBuffer, err := recoveryManager.bufferManager.Pin(record.blockId)
+	if err != nil {
+		return buffer.NoLogSequenceNumber, err
+	}
+	defer recoveryManager.bufferManager.Unpin(undoneBuffer)
+
+	undoneBuffer.Latch()
+	defer undoneBuffer.Unlatch()
+
+	fits, err := record.beforeImage.fitsIn(undoneBuffer.Page(), record.index)
+	if err != nil {
+		return buffer.NoLogSequenceNumber, err
+	}
+	if !fits {
+		return buffer.NoLogSequenceNumber, fmt.Errorf("%w: could not restore the field at index %d of block %v:%v",
+			InsufficientCapacityError, record.index, record.blockId.FileName(), record.blockId.BlockNumber(),
+		)
+	}
+
+	recoveryManager.lock.Lock()
+	defer recoveryManager.lock.Unlock()
+
+	logSequenceNumber, err := recoveryManager.logManager.Append(compensationRecord{
+		txNumber:                  record.txNumber,
+		blockId:                   record.blockId,
+		index:                     record.index,
+		image:                     record.beforeImage,
+		undoNextLogSequenceNumber: record.previousLogSequenceNumber,
+	}.encode())
+	if err != nil {
+		return buffer.NoLogSequenceNumber, err
+	}
+	if record.txNumber == recoveryManager.transactionNumber {
+		recoveryManager.lastLogSequenceNumber = logSequenceNumber
+	}
+
+	restored, err := record.beforeImage.applyTo(undoneBuffer.Page(), record.index)
+	if err != nil {
+		return buffer.NoLogSequenceNumber, err
+	}
+	if !restored {
+		return buffer.NoLogSequenceNumber, fmt.Errorf("%w: could not restore the field at index %d of block %v:%v",
+			InsufficientCapacityError, record.index, record.blockId.FileName(), record.blockId.BlockNumber(),
+		)
+	}
+	undoneBuffer.SetModified(record.txNumber, logSequenceNumber)
+	return logSequenceNumber, nil
+}
+
+// appendFinalRecord appends the COMMIT or the ROLLBACK record of a transaction. The transaction is finished once
+// its own final record is appended, and the checkpoints no longer consider it active.
+func (recoveryManager *recoveryManager) appendFinalRecord(record logRecord) (uint, error) {
+	recoveryManager.lock.Lock()
+	defer recoveryManager.lock.Unlock()
+
+	logSequenceNumber, err := recoveryManager.logManager.Append(record.encode())
+	if err != nil {
+		return buffer.NoLogSequenceNumber, err
+	}
+	if record.transactionNumber() == recoveryManager.transactionNumber {
+		recoveryManager.finished = true
+	}
+	return logSequenceNumber, nil
+}
+
+// forEachLogRecordBackward decodes the log records from the latest to the earliest, until the block returns false.
+func (recoveryManager *recoveryManager) forEachLogRecordBackward(
+	block func(logSequenceNumber uint, record logRecord) (bool, error),
+) error {
+	iterator, err := recoveryManager.logManager.BackwardIterator()
+	if err != nil {
+		return err
+	}
+	defer iterator.Close()
+
+	for iterator.IsValid() {
+		record, err := decodeLogRecord(iterator.Record())
+		if err != nil {
+			return err
+		}
+		proceed, err := block(iterator.LogSequenceNumber(), record)
+		if err != nil || !proceed {
+			return err
+		}
+		if err := iterator.Previous(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
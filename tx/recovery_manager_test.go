@@ -0,0 +1,454 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/buffer"
+	"gorel/file"
+	"strings"
+	"testing"
+)
+
+func readPage(t *testing.T, fileManager *file.BlockFileManager, blockId file.BlockId) *buffer.Page {
+	page := buffer.NewPage(blockSize)
+	assert.Nil(t, fileManager.ReadInto(blockId, page))
+	return page
+}
+
+func TestRollbackUndoesOnlyTheUpdatesOfTheTransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.SetString(otherBlockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	otherTransaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, otherTransaction.Pin(otherBlockId))
+
+	assert.Nil(t, transaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, otherTransaction.SetString(otherBlockId, 0, "PebbleDB"))
+	assert.Nil(t, transaction.SetUint32(blockId, 1, 32))
+	assert.Nil(t, otherTransaction.SetUint32(otherBlockId, 1, 64))
+
+	assert.Nil(t, transaction.Rollback())
+	assert.Nil(t, otherTransaction.Commit())
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	page := readPage(t, fileManager, blockId)
+	assert.Equal(t, "BoltDB", page.GetString(0))
+	assert.True(t, page.IsDeleted(1))
+
+	otherPage := readPage(t, fileManager, otherBlockId)
+	assert.Equal(t, "PebbleDB", otherPage.GetString(0))
+	assert.Equal(t, uint32(64), otherPage.GetUint32(1))
+}
+
+func TestRecoverAfterACrashUndoesTheTransactionsWhichDidNotCommit(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetInt64(blockId, 0, 100))
+	assert.Nil(t, transaction.SetString(blockId, 1, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+
+	committedTransaction := database.newTransaction(t)
+	uncommittedTransaction := database.newTransaction(t)
+	assert.Nil(t, committedTransaction.Pin(otherBlockId))
+	assert.Nil(t, uncommittedTransaction.Pin(blockId))
+
+	assert.Nil(t, uncommittedTransaction.SetInt64(blockId, 0, 200))
+	assert.Nil(t, committedTransaction.SetUint8(otherBlockId, 0, 8))
+	assert.Nil(t, uncommittedTransaction.SetString(blockId, 1, "RocksDB"))
+	assert.Nil(t, uncommittedTransaction.SetBool(blockId, 2, true))
+	assert.Nil(t, committedTransaction.Commit())
+
+	assert.Nil(t, database.bufferManager.FlushAll(uncommittedTransaction.TransactionNumber()))
+	page := readPage(t, fileManager, blockId)
+	assert.Equal(t, int64(200), page.GetInt64(0))
+	assert.Equal(t, "RocksDB", page.GetString(1))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	page = readPage(t, fileManager, blockId)
+	assert.Equal(t, int64(100), page.GetInt64(0))
+	assert.Equal(t, "BoltDB", page.GetString(1))
+	assert.True(t, page.IsDeleted(2))
+	assert.Equal(t, uint8(8), readPage(t, fileManager, otherBlockId).GetUint8(0))
+}
+
+func TestRecoverAfterARestartUndoesTheTransactionWithTheFirstTransactionNumber(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	uncommittedTransaction := database.newTransaction(t)
+	blockId, err := uncommittedTransaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, uncommittedTransaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, database.bufferManager.FlushAll(uncommittedTransaction.TransactionNumber()))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	recoveringTransaction := restartedDatabase.newTransaction(t)
+	assert.Greater(t, recoveringTransaction.TransactionNumber(), uncommittedTransaction.TransactionNumber())
+	assert.Nil(t, recoveringTransaction.Recover())
+	assert.True(t, readPage(t, fileManager, blockId).IsDeleted(0))
+}
+
+func TestRecoverAfterACrashDoesNotUndoTheRolledBackTransactions(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint16(blockId, 0, 16))
+	assert.Nil(t, transaction.Commit())
+
+	rolledBackTransaction := database.newTransaction(t)
+	assert.Nil(t, rolledBackTransaction.Pin(blockId))
+	assert.Nil(t, rolledBackTransaction.SetUint16(blockId, 0, 32))
+	assert.Nil(t, rolledBackTransaction.Rollback())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetUint16(blockId, 0, 64))
+	assert.Nil(t, transaction.Commit())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, uint16(64), readPage(t, fileManager, blockId).GetUint16(0))
+}
+
+func TestRecoverAfterACrashStartsAtTheLatestCheckpoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+
+	uncommittedTransaction := database.newTransaction(t)
+	assert.Nil(t, uncommittedTransaction.Pin(blockId))
+	assert.Nil(t, uncommittedTransaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, database.bufferManager.FlushAll(uncommittedTransaction.TransactionNumber()))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, "BoltDB", readPage(t, fileManager, blockId).GetString(0))
+
+	transaction = restartedDatabase.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetString(blockId, 0, "PebbleDB"))
+	assert.Nil(t, transaction.Commit())
+
+	restartedDatabase = newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, "PebbleDB", readPage(t, fileManager, blockId).GetString(0))
+}
+
+func TestRecoverAfterACrashRedoesTheCommittedTransactionsWhoseBuffersWereNotWritten(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.SetUint32(blockId, 1, 32))
+	assert.Nil(t, transaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, transaction.Commit())
+	assert.Equal(t, 0, readPage(t, fileManager, blockId).NumberOfFields())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	page := readPage(t, fileManager, blockId)
+	assert.Equal(t, "RocksDB", page.GetString(0))
+	assert.Equal(t, uint32(32), page.GetUint32(1))
+}
+
+func TestRecoverAfterACrashDuringARollbackDoesNotUndoAnUpdateTwice(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 1))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 2))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 3))
+
+	var lastUpdate updateRecord
+	assert.Nil(t, transaction.recoveryManager.forEachLogRecordBackward(func(_ uint, record logRecord) (bool, error) {
+		lastUpdate = record.(updateRecord)
+		return false, nil
+	}))
+	_, err = transaction.recoveryManager.compensate(lastUpdate)
+	assert.Nil(t, err)
+	assert.Nil(t, database.logManager.Flush(transaction.recoveryManager.lastLogSequenceNumber))
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+	assert.Equal(t, uint64(2), readPage(t, fileManager, blockId).GetUint64(0))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, uint64(1), readPage(t, fileManager, blockId).GetUint64(0))
+
+	compensations := 0
+	recoveryManager := &recoveryManager{logManager: restartedDatabase.logManager}
+	assert.Nil(t, recoveryManager.forEachLogRecordBackward(func(_ uint, record logRecord) (bool, error) {
+		if _, ok := record.(compensationRecord); ok {
+			compensations++
+		}
+		return true, nil
+	}))
+	assert.Equal(t, 2, compensations)
+}
+
+func TestRecoverAfterACrashDoesNotRedoTheUpdatesWhichReachedTheDisk(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.SetString(blockId, 1, "RocksDB"))
+	assert.Nil(t, transaction.Commit())
+
+	deletedBuffer, err := database.bufferManager.Pin(blockId)
+	assert.Nil(t, err)
+	logSequenceNumber := deletedBuffer.Page().LogSequenceNumber()
+	deletedBuffer.Page().Delete(1)
+	deletedBuffer.SetModified(noTransactionNumber, buffer.NoLogSequenceNumber)
+	database.bufferManager.Unpin(deletedBuffer)
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	page := readPage(t, fileManager, blockId)
+	assert.Equal(t, logSequenceNumber, page.LogSequenceNumber())
+	assert.True(t, page.IsDeleted(1))
+}
+
+func TestRecoverAfterACrashFollowingARollbackToASavepoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	transaction.Savepoint("savepoint")
+	assert.Nil(t, transaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, transaction.RollbackTo("savepoint"))
+	assert.Nil(t, transaction.SetUint32(blockId, 1, 32))
+	assert.Nil(t, transaction.Commit())
+
+	uncommittedTransaction := database.newTransaction(t)
+	assert.Nil(t, uncommittedTransaction.Pin(otherBlockId))
+	assert.Nil(t, uncommittedTransaction.SetUint8(otherBlockId, 0, 8))
+	uncommittedTransaction.Savepoint("savepoint")
+	assert.Nil(t, uncommittedTransaction.SetUint8(otherBlockId, 0, 16))
+	assert.Nil(t, uncommittedTransaction.RollbackTo("savepoint"))
+	assert.Nil(t, uncommittedTransaction.SetUint8(otherBlockId, 0, 32))
+	assert.Nil(t, database.bufferManager.FlushAll(uncommittedTransaction.TransactionNumber()))
+	assert.Equal(t, 0, readPage(t, fileManager, blockId).NumberOfFields())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	page := readPage(t, fileManager, blockId)
+	assert.Equal(t, "BoltDB", page.GetString(0))
+	assert.Equal(t, uint32(32), page.GetUint32(1))
+	assert.True(t, readPage(t, fileManager, otherBlockId).IsDeleted(0))
+}
+
+func TestRecoverAfterACrashRedoesTheUpdatesOfLargeValuesWithoutFreeingTheirOverflowChainsAgain(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, strings.Repeat("BoltDB", blockSize)))
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetString(blockId, 0, strings.Repeat("RocksDB", blockSize)))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(otherBlockId, 0, strings.Repeat("PebbleDB", blockSize)))
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, database.bufferManager.FlushAll(transaction.TransactionNumber()))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	transaction = restartedDatabase.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.Pin(otherBlockId))
+	assert.Nil(t, transaction.SetString(blockId, 1, strings.Repeat("LevelDB", blockSize)))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = restartedDatabase.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.Pin(otherBlockId))
+	for _, expected := range []struct {
+		blockId file.BlockId
+		index   int
+		value   string
+	}{
+		{blockId: blockId, index: 0, value: strings.Repeat("RocksDB", blockSize)},
+		{blockId: blockId, index: 1, value: strings.Repeat("LevelDB", blockSize)},
+		{blockId: otherBlockId, index: 0, value: strings.Repeat("PebbleDB", blockSize)},
+	} {
+		value, err := transaction.GetString(expected.blockId, expected.index)
+		assert.Nil(t, err)
+		assert.Equal(t, expected.value, value)
+	}
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestRecoverFailsWhenAnUpdateDoesNotFitInItsPageDuringTheRedo(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	numberOfFields := 0
+	for transaction.SetUint64(blockId, numberOfFields, uint64(numberOfFields)) == nil {
+		numberOfFields++
+	}
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	divergingTransactionNumber := transaction.TransactionNumber() + 1
+	for _, record := range []logRecord{
+		startRecord{txNumber: divergingTransactionNumber},
+		updateRecord{
+			txNumber:    divergingTransactionNumber,
+			blockId:     blockId,
+			index:       numberOfFields,
+			beforeImage: fieldImage{typeDescription: buffer.TypeTombstone},
+			afterImage:  fieldImage{typeDescription: buffer.TypeUint64, encoded: make([]byte, 8)},
+		},
+		commitRecord{txNumber: divergingTransactionNumber},
+	} {
+		logSequenceNumber, err := database.logManager.Append(record.encode())
+		assert.Nil(t, err)
+		assert.Nil(t, database.logManager.Flush(logSequenceNumber))
+	}
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.ErrorIs(t, restartedDatabase.newTransaction(t).Recover(), InsufficientCapacityError)
+}
+
+func TestCheckpointDoesNotTruncateTheLogWhileItIsWalkedBackward(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 256)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 0))
+	assert.Nil(t, transaction.Commit())
+	for value := uint64(1); value <= 32; value++ {
+		transaction = database.newTransaction(t)
+		assert.Nil(t, transaction.Pin(blockId))
+		assert.Nil(t, transaction.SetUint64(blockId, 0, value))
+		assert.Nil(t, transaction.Commit())
+	}
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+	numberOfBlocks, err := fileManager.NumberOfBlocks(logFileName(t))
+	assert.Nil(t, err)
+
+	latestLogSequenceNumber := database.logManager.LatestLogSequenceNumber()
+	expectedLogSequenceNumber := latestLogSequenceNumber
+	recoveryManager := &recoveryManager{logManager: database.logManager}
+	assert.Nil(t, recoveryManager.forEachLogRecordBackward(func(logSequenceNumber uint, _ logRecord) (bool, error) {
+		if logSequenceNumber == latestLogSequenceNumber {
+			assert.Nil(t, database.transactionManager.Checkpoint())
+		}
+		assert.Equal(t, expectedLogSequenceNumber, logSequenceNumber)
+		expectedLogSequenceNumber--
+		return true, nil
+	}))
+	assert.Equal(t, uint(0), expectedLogSequenceNumber)
+
+	numberOfBlocksAfterCheckpoint, err := fileManager.NumberOfBlocks(logFileName(t))
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, numberOfBlocksAfterCheckpoint, numberOfBlocks)
+
+	assert.Nil(t, database.transactionManager.Checkpoint())
+	numberOfBlocksAfterCheckpoint, err = fileManager.NumberOfBlocks(logFileName(t))
+	assert.Nil(t, err)
+	assert.Less(t, numberOfBlocksAfterCheckpoint, numberOfBlocks)
+}
+
+func TestUndoFailsWhenTheLogEndsBeforeTheStartOfTheTransactions(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+
+	recoveryManager := &recoveryManager{logManager: database.logManager, bufferManager: database.bufferManager}
+	err = recoveryManager.undo(map[int]uint{transaction.TransactionNumber() + 1: 100}, 0)
+	assert.ErrorIs(t, err, UnfinishedUndoError)
+}
@@ -0,0 +1,502 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"gorel"
+	"gorel/buffer"
+	"gorel/file"
+	"slices"
+	"time"
+)
+
+var (
+	BlockNotPinnedError       = errors.New("block is not pinned by the transaction")
+	SavepointNotFoundError    = errors.New("savepoint not found")
+	TransactionPreparedError  = errors.New("transaction is prepared, it is finished by CommitPrepared or RollbackPrepared")
+	InsufficientCapacityError = errors.New("page does not have the capacity for the value")
+	UnknownLogRecordError     = errors.New("unknown log record")
+	UnfinishedUndoError       = errors.New("log ended before the undo reached the start of the transactions")
+)
+
+// Transaction reads and modifies the fields of the pages of the blocks it pins. Every modification is logged with
+// the before-image of the field, and it is undone if the transaction rolls back. A Transaction takes an exclusive lock
+// on a block before modifying it, and holds it until it commits or rolls back; the shared locks which it takes before
+// reading a block depend on its IsolationLevel. A transaction which gets ErrLockAbort must roll back. A Transaction is
+// not safe for concurrent use.
+type Transaction struct {
+	transactionNumber  int
+	isolationLevel     IsolationLevel
+	snapshotTimestamp  uint
+	transactionManager *TransactionManager
+	fileManager        *file.BlockFileManager
+	bufferManager      *buffer.BufferManager
+	recoveryManager    *recoveryManager
+	lockTable          *LockTable
+	buffers            *bufferList
+	savepoints         []savepoint
+	// globalTransactionId is the id under which the transaction is prepared, empty if it is not.
+	globalTransactionId string
+	// allocatedChains are the overflow chains written by the updates of the transaction, which are freed if the
+	// updates are undone. supersededChains are the overflow chains of the values which the updates replaced, which
+	// are freed once the transaction commits, because an undo may restore them before that.
+	allocatedChains  []overflowChain
+	supersededChains []overflowChain
+}
+
+// savepoint is a named point in the log records of a transaction, which the transaction can roll back to, along with
+// the number of overflow chains that the transaction allocated and superseded by then.
+type savepoint struct {
+	name              string
+	logSequenceNumber uint
+	allocatedChains   int
+	supersededChains  int
+}
+
+// overflowChain is the image of a field of a file which points to an overflow chain.
+type overflowChain struct {
+	fileName string
+	image    fieldImage
+}
+
+func (transaction *Transaction) TransactionNumber() int {
+	return transaction.transactionNumber
+}
+
+func (transaction *Transaction) IsolationLevel() IsolationLevel {
+	return transaction.isolationLevel
+}
+
+// Commit appends a COMMIT record and flushes the log, and then releases the locks and unpins all the buffers of the
+// transaction. The modified buffers are written later, and the recovery redoes the modifications which did not
+// reach the disk. A prepared transaction returns TransactionPreparedError.
+func (transaction *Transaction) Commit() error {
+	if transaction.isPrepared() {
+		return fmt.Errorf("%w: %v", TransactionPreparedError, transaction.globalTransactionId)
+	}
+	return transaction.commit()
+}
+
+func (transaction *Transaction) commit() error {
+	if err := transaction.recoveryManager.commit(); err != nil {
+		return err
+	}
+	transaction.freeOverflowChains(transaction.supersededChains)
+	transaction.transactionManager.finish(transaction.transactionNumber, true)
+	transaction.lockTable.UnlockAll(transaction.transactionNumber)
+	transaction.buffers.unpinAll()
+	return nil
+}
+
+// Rollback walks the log backward to undo the modifications of the transaction, appending a compensation record
+// for each of them, appends a ROLLBACK record and flushes the log, and then releases the locks and unpins all the
+// buffers of the transaction. A prepared transaction returns TransactionPreparedError.
+func (transaction *Transaction) Rollback() error {
+	if transaction.isPrepared() {
+		return fmt.Errorf("%w: %v", TransactionPreparedError, transaction.globalTransactionId)
+	}
+	return transaction.rollback()
+}
+
+func (transaction *Transaction) rollback() error {
+	if err := transaction.recoveryManager.rollback(); err != nil {
+		return err
+	}
+	transaction.freeOverflowChains(transaction.allocatedChains)
+	transaction.transactionManager.finish(transaction.transactionNumber, false)
+	transaction.lockTable.UnlockAll(transaction.transactionNumber)
+	transaction.buffers.unpinAll()
+	return nil
+}
+
+// Prepare makes the transaction a prepared participant of a two-phase commit under the global transaction id of its
+// coordinator: it appends a PREPARE record, flushes the log and unpins all the buffers of the transaction, which keeps
+// its locks until the coordinator decides to commit it with CommitPrepared or to roll it back with RollbackPrepared.
+// A transaction which is prepared when the database crashes is restored in doubt by the recovery.
+func (transaction *Transaction) Prepare(globalTransactionId string) error {
+	if transaction.isPrepared() {
+		return fmt.Errorf("%w: %v", TransactionPreparedError, transaction.globalTransactionId)
+	}
+	if err := transaction.transactionManager.prepare(transaction, globalTransactionId); err != nil {
+		return err
+	}
+	transaction.buffers.unpinAll()
+	return nil
+}
+
+func (transaction *Transaction) isPrepared() bool {
+	return transaction.globalTransactionId != ""
+}
+
+// Savepoint marks the current point of the transaction with the name. A savepoint with the same name is replaced.
+func (transaction *Transaction) Savepoint(name string) {
+	if index, ok := transaction.savepointIndex(name); ok {
+		transaction.savepoints = slices.Delete(transaction.savepoints, index, index+1)
+	}
+	transaction.savepoints = append(transaction.savepoints, savepoint{
+		name:              name,
+		logSequenceNumber: transaction.recoveryManager.savepoint(),
+		allocatedChains:   len(transaction.allocatedChains),
+		supersededChains:  len(transaction.supersededChains),
+	})
+}
+
+// RollbackTo undoes the modifications made since the savepoint, appending a compensation record for each of them,
+// and releases the savepoints marked after it. The savepoint itself remains, and the transaction keeps its locks
+// and its pinned buffers.
+func (transaction *Transaction) RollbackTo(name string) error {
+	index, ok := transaction.savepointIndex(name)
+	if !ok {
+		return fmt.Errorf("%w: %v", SavepointNotFoundError, name)
+	}
+	rolledBackTo := transaction.savepoints[index]
+	if err := transaction.recoveryManager.rollbackTo(rolledBackTo.logSequenceNumber); err != nil {
+		return err
+	}
+	transaction.freeOverflowChains(transaction.allocatedChains[rolledBackTo.allocatedChains:])
+	transaction.allocatedChains = transaction.allocatedChains[:rolledBackTo.allocatedChains]
+	transaction.supersededChains = transaction.supersededChains[:rolledBackTo.supersededChains]
+	transaction.savepoints = transaction.savepoints[:index+1]
+	return nil
+}
+
+// Release removes the savepoint and the savepoints marked after it, keeping the modifications made since.
+func (transaction *Transaction) Release(name string) error {
+	index, ok := transaction.savepointIndex(name)
+	if !ok {
+		return fmt.Errorf("%w: %v", SavepointNotFoundError, name)
+	}
+	transaction.savepoints = transaction.savepoints[:index]
+	return nil
+}
+
+func (transaction *Transaction) savepointIndex(name string) (int, bool) {
+	index := slices.IndexFunc(transaction.savepoints, func(savepoint savepoint) bool {
+		return savepoint.name == name
+	})
+	return index, index >= 0
+}
+
+// Recover redoes the modifications which did not reach the disk before a crash, undoes the transactions which were
+// neither committed nor rolled back, restores the prepared transactions in doubt along with the exclusive locks on
+// the blocks they modified, and takes a checkpoint. It is run by a transaction at startup, before any other
+// transaction starts, and it commits the transaction before taking the checkpoint, so that the checkpoint does not
+// keep the log records of the transaction from being truncated.
+func (transaction *Transaction) Recover() error {
+	inDoubtTransactions, err := transaction.recoveryManager.recover()
+	if err != nil {
+		return err
+	}
+	for _, inDoubt := range inDoubtTransactions {
+		if err := transaction.transactionManager.restoreInDoubt(inDoubt); err != nil {
+			return err
+		}
+	}
+	if err := transaction.commit(); err != nil {
+		return err
+	}
+	return transaction.transactionManager.Checkpoint()
+}
+
+func (transaction *Transaction) Pin(blockId file.BlockId) error {
+	return transaction.buffers.pin(blockId)
+}
+
+func (transaction *Transaction) Unpin(blockId file.BlockId) {
+	transaction.buffers.unpin(blockId)
+}
+
+// Size returns the number of blocks in the file, after locking its end-of-file marker for a read.
+func (transaction *Transaction) Size(fileName string) (uint, error) {
+	release, err := transaction.lockForRead(endOfFileMarker(fileName), transaction.isolationLevel == IsolationSerializable)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	numberOfBlocks, err := transaction.fileManager.NumberOfBlocks(fileName)
+	if err != nil {
+		return 0, err
+	}
+	return uint(numberOfBlocks), nil
+}
+
+// Append appends a new block to the file and pins it, after taking an exclusive lock on the end-of-file marker of
+// the file. The block is not removed if the transaction rolls back.
+func (transaction *Transaction) Append(fileName string) (file.BlockId, error) {
+	if err := transaction.lockTable.XLock(transaction.transactionNumber, endOfFileMarker(fileName)); err != nil {
+		return file.MissingBlockId, err
+	}
+	return transaction.buffers.pinNew(fileName)
+}
+
+func (transaction *Transaction) GetUint8(blockId file.BlockId, index int) (uint8, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetUint8)
+}
+
+func (transaction *Transaction) GetUint16(blockId file.BlockId, index int) (uint16, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetUint16)
+}
+
+func (transaction *Transaction) GetUint32(blockId file.BlockId, index int) (uint32, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetUint32)
+}
+
+func (transaction *Transaction) GetUint64(blockId file.BlockId, index int) (uint64, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetUint64)
+}
+
+func (transaction *Transaction) GetString(blockId file.BlockId, index int) (string, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetString)
+}
+
+// GetBytes returns a copy of the value, which remains valid after the block is unpinned.
+func (transaction *Transaction) GetBytes(blockId file.BlockId, index int) ([]byte, error) {
+	value, err := get(transaction, blockId, index, (*buffer.Page).TryGetBytes)
+	return slices.Clone(value), err
+}
+
+func (transaction *Transaction) GetInt8(blockId file.BlockId, index int) (int8, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetInt8)
+}
+
+func (transaction *Transaction) GetInt16(blockId file.BlockId, index int) (int16, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetInt16)
+}
+
+func (transaction *Transaction) GetInt32(blockId file.BlockId, index int) (int32, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetInt32)
+}
+
+func (transaction *Transaction) GetInt64(blockId file.BlockId, index int) (int64, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetInt64)
+}
+
+func (transaction *Transaction) GetFloat32(blockId file.BlockId, index int) (float32, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetFloat32)
+}
+
+func (transaction *Transaction) GetFloat64(blockId file.BlockId, index int) (float64, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetFloat64)
+}
+
+func (transaction *Transaction) GetBool(blockId file.BlockId, index int) (bool, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetBool)
+}
+
+func (transaction *Transaction) GetDate(blockId file.BlockId, index int) (time.Time, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetDate)
+}
+
+func (transaction *Transaction) GetTimestamp(blockId file.BlockId, index int) (time.Time, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetTimestamp)
+}
+
+func (transaction *Transaction) GetDecimal(blockId file.BlockId, index int) (gorel.Decimal, error) {
+	return get(transaction, blockId, index, (*buffer.Page).TryGetDecimal)
+}
+
+// SetUint8 sets the field at the index of the pinned block, or adds the field if the index is equal to the number
+// of fields in the page. It returns InsufficientCapacityError if the page does not have the capacity for the value;
+// the same holds for all the Set methods.
+func (transaction *Transaction) SetUint8(blockId file.BlockId, index int, value uint8) error {
+	return transaction.set(blockId, index, buffer.TypeUint8, encode(value, gorel.BytesNeededForEncodingAnUint8(), gorel.EncodeUint8))
+}
+
+func (transaction *Transaction) SetUint16(blockId file.BlockId, index int, value uint16) error {
+	return transaction.set(blockId, index, buffer.TypeUint16, encode(value, gorel.BytesNeededForEncodingAnUint16(), gorel.EncodeUint16))
+}
+
+func (transaction *Transaction) SetUint32(blockId file.BlockId, index int, value uint32) error {
+	return transaction.set(blockId, index, buffer.TypeUint32, encode(value, gorel.BytesNeededForEncodingAnUint32(), gorel.EncodeUint32))
+}
+
+func (transaction *Transaction) SetUint64(blockId file.BlockId, index int, value uint64) error {
+	return transaction.set(blockId, index, buffer.TypeUint64, encode(value, gorel.BytesNeededForEncodingAnUint64(), gorel.EncodeUint64))
+}
+
+func (transaction *Transaction) SetString(blockId file.BlockId, index int, value string) error {
+	return transaction.set(blockId, index, buffer.TypeString, []byte(value))
+}
+
+func (transaction *Transaction) SetBytes(blockId file.BlockId, index int, value []byte) error {
+	return transaction.set(blockId, index, buffer.TypeByteSlice, value)
+}
+
+func (transaction *Transaction) SetInt8(blockId file.BlockId, index int, value int8) error {
+	return transaction.set(blockId, index, buffer.TypeInt8, encode(value, gorel.BytesNeededForEncodingAnInt8(), gorel.EncodeInt8))
+}
+
+func (transaction *Transaction) SetInt16(blockId file.BlockId, index int, value int16) error {
+	return transaction.set(blockId, index, buffer.TypeInt16, encode(value, gorel.BytesNeededForEncodingAnInt16(), gorel.EncodeInt16))
+}
+
+func (transaction *Transaction) SetInt32(blockId file.BlockId, index int, value int32) error {
+	return transaction.set(blockId, index, buffer.TypeInt32, encode(value, gorel.BytesNeededForEncodingAnInt32(), gorel.EncodeInt32))
+}
+
+func (transaction *Transaction) SetInt64(blockId file.BlockId, index int, value int64) error {
+	return transaction.set(blockId, index, buffer.TypeInt64, encode(value, gorel.BytesNeededForEncodingAnInt64(), gorel.EncodeInt64))
+}
+
+func (transaction *Transaction) SetFloat32(blockId file.BlockId, index int, value float32) error {
+	return transaction.set(blockId, index, buffer.TypeFloat32, encode(value, gorel.BytesNeededForEncodingAFloat32(), gorel.EncodeFloat32))
+}
+
+func (transaction *Transaction) SetFloat64(blockId file.BlockId, index int, value float64) error {
+	return transaction.set(blockId, index, buffer.TypeFloat64, encode(value, gorel.BytesNeededForEncodingAFloat64(), gorel.EncodeFloat64))
+}
+
+func (transaction *Transaction) SetBool(blockId file.BlockId, index int, value bool) error {
+	return transaction.set(blockId, index, buffer.TypeBool, encode(value, gorel.BytesNeededForEncodingABool(), gorel.EncodeBool))
+}
+
+func (transaction *Transaction) SetDate(blockId file.BlockId, index int, value time.Time) error {
+	return transaction.set(blockId, index, buffer.TypeDate, encode(value, gorel.BytesNeededForEncodingADate(), gorel.EncodeDate))
+}
+
+func (transaction *Transaction) SetTimestamp(blockId file.BlockId, index int, value time.Time) error {
+	return transaction.set(blockId, index, buffer.TypeTimestamp, encode(value, gorel.BytesNeededForEncodingATimestamp(), gorel.EncodeTimestamp))
+}
+
+func (transaction *Transaction) SetDecimal(blockId file.BlockId, index int, value gorel.Decimal) error {
+	return transaction.set(blockId, index, buffer.TypeDecimal, encode(value, gorel.BytesNeededForEncodingADecimal(), gorel.EncodeDecimal))
+}
+
+// SetNull makes the field at the index NULL, or adds a NULL field of the type if the index is equal to the number of
+// fields in the page.
+func (transaction *Transaction) SetNull(blockId file.BlockId, index int, typeDescription buffer.TypeDescription) error {
+	return transaction.set(blockId, index, typeDescription.WithNull(), nil)
+}
+
+// set modifies the field after taking an exclusive lock on the block, and latches the buffer against the
+// transactions which read it without a shared lock.
+func (transaction *Transaction) set(blockId file.BlockId, index int, typeDescription buffer.TypeDescription, encoded []byte) error {
+	pinnedBuffer, err := transaction.pinnedBufferFor(blockId)
+	if err != nil {
+		return err
+	}
+	if err := transaction.lockTable.XLock(transaction.transactionNumber, blockId); err != nil {
+		return err
+	}
+	pinnedBuffer.Latch()
+	defer pinnedBuffer.Unlatch()
+
+	return transaction.update(pinnedBuffer, index, fieldImage{typeDescription: typeDescription, encoded: encoded})
+}
+
+// update logs the before-image and the after-image of the field before modifying it, and marks the buffer as
+// modified by the log record. The type of the field must match the type of the after-image, unless the after-image
+// is a tombstone which deletes the field. A large value is written to its overflow chain before it is logged, so
+// that the log record holds the pointer to the chain rather than the value. An update of a page without the capacity
+// for the after-image fails before it is logged, so that the log holds no record of it.
+func (transaction *Transaction) update(pinnedBuffer *buffer.Buffer, index int, afterImage fieldImage) error {
+	page := pinnedBuffer.Page()
+	blockId := pinnedBuffer.BlockId()
+	record := updateRecord{
+		txNumber: transaction.transactionNumber,
+		blockId:  blockId,
+		index:    index,
+	}
+	if index == page.NumberOfFields() {
+		record.beforeImage = fieldImage{typeDescription: buffer.TypeTombstone}
+	} else {
+		var err error
+		if record.beforeImage.typeDescription, record.beforeImage.encoded, err = page.TryGetStored(index); err != nil {
+			return err
+		}
+		typeDescription := afterImage.typeDescription.WithoutNull()
+		beforeTypeDescription := record.beforeImage.typeDescription.WithoutNull().WithoutOverflow()
+		if !typeDescription.Equals(buffer.TypeTombstone) && !beforeTypeDescription.Equals(typeDescription) {
+			return fmt.Errorf(
+				"%w, expected type %s actual type %s",
+				buffer.ErrTypeMismatch,
+				typeDescription.AsString(),
+				record.beforeImage.typeDescription.AsString(),
+			)
+		}
+	}
+	var err error
+	if record.afterImage.typeDescription, record.afterImage.encoded, err = page.TryStore(afterImage.typeDescription, afterImage.encoded); err != nil {
+		return err
+	}
+	allocated := overflowChain{fileName: blockId.FileName(), image: record.afterImage}
+	fits, err := record.afterImage.fitsIn(page, index)
+	if err == nil && !fits {
+		err = fmt.Errorf("%w: field at index %d of block %v:%v", InsufficientCapacityError, index, blockId.FileName(), blockId.BlockNumber())
+	}
+	if err != nil {
+		transaction.freeOverflowChains([]overflowChain{allocated})
+		return err
+	}
+	if err := transaction.recoveryManager.logUpdate(record, func(logSequenceNumber uint) error {
+		mutated, err := record.afterImage.applyTo(page, index)
+		if err != nil {
+			return err
+		}
+		if !mutated {
+			return fmt.Errorf("%w: field at index %d of block %v:%v", InsufficientCapacityError, index, blockId.FileName(), blockId.BlockNumber())
+		}
+		pinnedBuffer.SetModified(transaction.transactionNumber, logSequenceNumber)
+		return nil
+	}); err != nil {
+		transaction.freeOverflowChains([]overflowChain{allocated})
+		return err
+	}
+	if record.afterImage.typeDescription.IsOverflow() {
+		transaction.allocatedChains = append(transaction.allocatedChains, allocated)
+	}
+	if record.beforeImage.typeDescription.IsOverflow() {
+		transaction.supersededChains = append(transaction.supersededChains, overflowChain{fileName: blockId.FileName(), image: record.beforeImage})
+	}
+	return nil
+}
+
+// freeOverflowChains frees the overflow chains that no field points to any longer. A chain which cannot be freed is
+// leaked, like the chains of a transaction which does not finish before a crash.
+func (transaction *Transaction) freeOverflowChains(chains []overflowChain) {
+	for _, chain := range chains {
+		_ = transaction.bufferManager.FreeOverflowChain(chain.fileName, chain.image.typeDescription, chain.image.encoded)
+	}
+}
+
+func (transaction *Transaction) pinnedBufferFor(blockId file.BlockId) (*buffer.Buffer, error) {
+	pinnedBuffer, ok := transaction.buffers.bufferFor(blockId)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v:%v", BlockNotPinnedError, blockId.FileName(), blockId.BlockNumber())
+	}
+	return pinnedBuffer, nil
+}
+
+func get[T any](
+	transaction *Transaction,
+	blockId file.BlockId,
+	index int,
+	getFn func(page *buffer.Page, index int) (T, error),
+) (T, error) {
+	var zero T
+	pinnedBuffer, err := transaction.pinnedBufferFor(blockId)
+	if err != nil {
+		return zero, err
+	}
+	release, err := transaction.lockForRead(blockId, transaction.isolationLevel >= IsolationRepeatableRead)
+	if err != nil {
+		return zero, err
+	}
+	defer release()
+
+	pinnedBuffer.LatchShared()
+	defer pinnedBuffer.UnlatchShared()
+	return getFn(pinnedBuffer.Page(), index)
+}
+
+// encode encodes the value of a fixed size type, as the page encodes it.
+func encode[T any](
+	value T,
+	bytesNeeded gorel.BytesNeededForEncoding,
+	encodeFn func(source T, destination []byte, destinationStartingOffset uint) gorel.BytesNeededForEncoding,
+) []byte {
+	encoded := make([]byte, bytesNeeded)
+	encodeFn(value, encoded, 0)
+	return encoded
+}
@@ -0,0 +1,311 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"gorel/buffer"
+	"gorel/file"
+	"gorel/log"
+	"slices"
+	"sync"
+	"time"
+)
+
+var PreparedTransactionNotFoundError = errors.New("prepared transaction not found")
+
+// defaultMaxLockWaitTime is the time that a transaction waits for a lock in the LockTable of NewTransactionManager.
+const defaultMaxLockWaitTime = 10 * time.Second
+
+// TransactionManager starts the transactions of a database, and keeps track of the active ones for the
+// checkpoints. The transactions lock the blocks in its LockTable. The prepared transactions remain active until they
+// are committed or rolled back by their global transaction id.
+//
+// The transaction numbers follow the last transaction number in the log, which the first transaction restores, so
+// that a transaction number is never reused after a restart.
+//
+// Every commit gets the next commit timestamp, and every transaction takes the latest commit timestamp as its
+// snapshot when it starts: the multi-version records which a transaction reads are those of the transactions
+// committed at or before its snapshot. The commit timestamps are kept until Vacuum finds that no active snapshot
+// precedes them, and a transaction with no commit timestamp which is not active committed before all the snapshots,
+// since the modifications of the transactions which roll back are undone.
+type TransactionManager struct {
+	fileManager   *file.BlockFileManager
+	logManager    *log.BlockLogManager
+	bufferManager *buffer.BufferManager
+	lockTable     *LockTable
+	// lastTransactionNumber starts at 0, which the buffers use for the modifications made outside any transaction.
+	lastTransactionNumber         int
+	lastTransactionNumberRestored bool
+	activeTransactions            map[int]*Transaction
+	prepared                      map[string]*Transaction
+	lastCommitTimestamp           uint
+	commitTimestamps              map[int]uint
+	lock                          sync.Mutex
+}
+
+// NewTransactionManager creates a TransactionManager whose transactions detect the deadlocks, and wait up to
+// defaultMaxLockWaitTime for a lock.
+func NewTransactionManager(
+	fileManager *file.BlockFileManager,
+	logManager *log.BlockLogManager,
+	bufferManager *buffer.BufferManager,
+) *TransactionManager {
+	return NewTransactionManagerWithLockTable(
+		fileManager,
+		logManager,
+		bufferManager,
+		NewLockTable(DeadlockDetection, defaultMaxLockWaitTime),
+	)
+}
+
+// NewTransactionManagerWithLockTable creates a TransactionManager whose transactions lock the blocks in the
+// lockTable.
+func NewTransactionManagerWithLockTable(
+	fileManager *file.BlockFileManager,
+	logManager *log.BlockLogManager,
+	bufferManager *buffer.BufferManager,
+	lockTable *LockTable,
+) *TransactionManager {
+	return &TransactionManager{
+		fileManager:        fileManager,
+		logManager:         logManager,
+		bufferManager:      bufferManager,
+		lockTable:          lockTable,
+		activeTransactions: make(map[int]*Transaction),
+		prepared:           make(map[string]*Transaction),
+		commitTimestamps:   make(map[int]uint),
+	}
+}
+
+// NewTransaction starts a SERIALIZABLE transaction.
+func (transactionManager *TransactionManager) NewTransaction() (*Transaction, error) {
+	return transactionManager.NewTransactionWithIsolationLevel(IsolationSerializable)
+}
+
+// NewTransactionWithIsolationLevel assigns the next transaction number, takes the snapshot of the transaction, and
+// appends a START record to the log. The first transaction restores the last transaction number from the log.
+func (transactionManager *TransactionManager) NewTransactionWithIsolationLevel(isolationLevel IsolationLevel) (*Transaction, error) {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	if !transactionManager.lastTransactionNumberRestored {
+		lastTransactionNumber, err := lastTransactionNumberInTheLog(transactionManager.logManager)
+		if err != nil {
+			return nil, err
+		}
+		transactionManager.lastTransactionNumber = max(transactionManager.lastTransactionNumber, lastTransactionNumber)
+		transactionManager.lastTransactionNumberRestored = true
+	}
+	transactionManager.lastTransactionNumber++
+	transactionNumber := transactionManager.lastTransactionNumber
+	recoveryManager, err := startRecoveryManager(transactionNumber, transactionManager.logManager, transactionManager.bufferManager)
+	if err != nil {
+		return nil, err
+	}
+	transaction := &Transaction{
+		transactionNumber:  transactionNumber,
+		isolationLevel:     isolationLevel,
+		snapshotTimestamp:  transactionManager.lastCommitTimestamp,
+		transactionManager: transactionManager,
+		fileManager:        transactionManager.fileManager,
+		bufferManager:      transactionManager.bufferManager,
+		recoveryManager:    recoveryManager,
+		lockTable:          transactionManager.lockTable,
+		buffers:            newBufferList(transactionManager.bufferManager),
+	}
+	transactionManager.activeTransactions[transactionNumber] = transaction
+	return transaction, nil
+}
+
+// CommitPrepared commits the transaction prepared under the global transaction id. It returns
+// PreparedTransactionNotFoundError if no transaction is prepared under the id.
+func (transactionManager *TransactionManager) CommitPrepared(globalTransactionId string) error {
+	return transactionManager.finishPrepared(globalTransactionId, (*Transaction).commit)
+}
+
+// RollbackPrepared rolls back the transaction prepared under the global transaction id, like CommitPrepared.
+func (transactionManager *TransactionManager) RollbackPrepared(globalTransactionId string) error {
+	return transactionManager.finishPrepared(globalTransactionId, (*Transaction).rollback)
+}
+
+// PreparedTransactions returns the sorted global transaction ids of the prepared transactions, including those
+// restored in doubt by the recovery, which wait for the decision of their coordinator.
+func (transactionManager *TransactionManager) PreparedTransactions() []string {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	globalTransactionIds := make([]string, 0, len(transactionManager.prepared))
+	for globalTransactionId := range transactionManager.prepared {
+		globalTransactionIds = append(globalTransactionIds, globalTransactionId)
+	}
+	slices.Sort(globalTransactionIds)
+	return globalTransactionIds
+}
+
+// Checkpoint appends a non-quiescent checkpoint without waiting for the active transactions to finish, and then
+// truncates the log blocks which the recovery no longer needs: those before the earliest record of the active
+// transactions, the earliest modification of the dirty pages and the start of the checkpoint. The checkpoint is a
+// BEGIN_CKPT record, the records of its tables, which may span many log blocks, and an END_CKPT record.
+func (transactionManager *TransactionManager) Checkpoint() error {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	tables, truncationLogSequenceNumber, err := transactionManager.beginCheckpoint()
+	if err != nil {
+		return err
+	}
+	for _, record := range tables.records(transactionManager.logManager.MaxRecordSize()) {
+		if _, err := transactionManager.logManager.Append(record.encode()); err != nil {
+			return err
+		}
+	}
+	logSequenceNumber, err := transactionManager.logManager.Append(
+		endCheckpointRecord{beginLogSequenceNumber: tables.beginLogSequenceNumber}.encode(),
+	)
+	if err != nil {
+		return err
+	}
+	if err := transactionManager.logManager.Flush(logSequenceNumber); err != nil {
+		return err
+	}
+	return transactionManager.logManager.Truncate(truncationLogSequenceNumber)
+}
+
+// beginCheckpoint appends the BEGIN_CKPT record, and takes the table of the active transactions and the dirty page
+// table while holding the locks of all the active transactions, so that no log record is appended without being
+// applied to its page. It returns the tables, and the earliest log sequence number which the recovery may need.
+func (transactionManager *TransactionManager) beginCheckpoint() (checkpointTables, uint, error) {
+	for _, transaction := range transactionManager.activeTransactions {
+		transaction.recoveryManager.lock.Lock()
+		defer transaction.recoveryManager.lock.Unlock()
+	}
+
+	beginLogSequenceNumber, err := transactionManager.logManager.Append(
+		beginCheckpointRecord{lastTransactionNumber: transactionManager.lastTransactionNumber}.encode(),
+	)
+	if err != nil {
+		return checkpointTables{}, 0, err
+	}
+	tables := checkpointTables{
+		beginLogSequenceNumber: beginLogSequenceNumber,
+		lastTransactionNumber:  transactionManager.lastTransactionNumber,
+		activeTransactions:     make(map[int]uint),
+		dirtyPages:             transactionManager.bufferManager.DirtyPageTable(),
+	}
+	truncationLogSequenceNumber := min(beginLogSequenceNumber, tables.redoLogSequenceNumber())
+	for transactionNumber, transaction := range transactionManager.activeTransactions {
+		recoveryManager := transaction.recoveryManager
+		if recoveryManager.finished {
+			continue
+		}
+		tables.activeTransactions[transactionNumber] = recoveryManager.lastLogSequenceNumber
+		truncationLogSequenceNumber = min(truncationLogSequenceNumber, recoveryManager.firstLogSequenceNumber)
+	}
+	return tables, truncationLogSequenceNumber, nil
+}
+
+// finish removes the transaction from the active transactions once it has committed or rolled back, and assigns the
+// next commit timestamp to a committed transaction.
+func (transactionManager *TransactionManager) finish(transactionNumber int, committed bool) {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	delete(transactionManager.activeTransactions, transactionNumber)
+	if committed {
+		transactionManager.lastCommitTimestamp++
+		transactionManager.commitTimestamps[transactionNumber] = transactionManager.lastCommitTimestamp
+	}
+}
+
+// prepare logs the PREPARE record of the transaction, and registers it under the global transaction id, which must
+// not be empty nor used by another prepared transaction.
+func (transactionManager *TransactionManager) prepare(transaction *Transaction, globalTransactionId string) error {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	if globalTransactionId == "" {
+		return fmt.Errorf("%w: the global transaction id is empty", TransactionPreparedError)
+	}
+	if other, ok := transactionManager.prepared[globalTransactionId]; ok {
+		return fmt.Errorf("%w: %v by the transaction %d", TransactionPreparedError, globalTransactionId, other.transactionNumber)
+	}
+	if err := transaction.recoveryManager.prepare(globalTransactionId); err != nil {
+		return err
+	}
+	transaction.globalTransactionId = globalTransactionId
+	transactionManager.prepared[globalTransactionId] = transaction
+	return nil
+}
+
+// finishPrepared unregisters the transaction prepared under the global transaction id, and finishes it, registering
+// it again if it could not finish.
+func (transactionManager *TransactionManager) finishPrepared(globalTransactionId string, finish func(*Transaction) error) error {
+	transactionManager.lock.Lock()
+	transaction, ok := transactionManager.prepared[globalTransactionId]
+	delete(transactionManager.prepared, globalTransactionId)
+	transactionManager.lock.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %v", PreparedTransactionNotFoundError, globalTransactionId)
+	}
+	if err := finish(transaction); err != nil {
+		transactionManager.lock.Lock()
+		transactionManager.prepared[globalTransactionId] = transaction
+		transactionManager.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// restoreInDoubt registers a transaction in doubt as an active prepared transaction, and locks the blocks it
+// modified.
+func (transactionManager *TransactionManager) restoreInDoubt(inDoubt *inDoubtTransaction) error {
+	transaction := &Transaction{
+		transactionNumber:  inDoubt.transactionNumber,
+		isolationLevel:     IsolationSerializable,
+		transactionManager: transactionManager,
+		fileManager:        transactionManager.fileManager,
+		bufferManager:      transactionManager.bufferManager,
+		recoveryManager: newRecoveryManager(
+			inDoubt.transactionNumber,
+			inDoubt.firstLogSequenceNumber,
+			inDoubt.lastLogSequenceNumber,
+			transactionManager.logManager,
+			transactionManager.bufferManager,
+		),
+		lockTable:           transactionManager.lockTable,
+		buffers:             newBufferList(transactionManager.bufferManager),
+		globalTransactionId: inDoubt.globalTransactionId,
+	}
+	for blockId := range inDoubt.modifiedBlocks {
+		if err := transactionManager.lockTable.XLock(inDoubt.transactionNumber, blockId); err != nil {
+			return err
+		}
+	}
+
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	transaction.snapshotTimestamp = transactionManager.lastCommitTimestamp
+	transactionManager.activeTransactions[inDoubt.transactionNumber] = transaction
+	transactionManager.prepared[inDoubt.globalTransactionId] = transaction
+	return nil
+}
+
+// isVisible returns true if the modifications of the writer are visible in the snapshot of the transaction: those of
+// the transaction itself, and those of the transactions committed at or before its snapshot. A writer which is
+// neither active nor has a commit timestamp committed before the latest restart, since the transaction numbers
+// restored from the log are never assigned again.
+func (transactionManager *TransactionManager) isVisible(writer int, transaction *Transaction) bool {
+	transactionManager.lock.Lock()
+	defer transactionManager.lock.Unlock()
+
+	if writer == transaction.transactionNumber {
+		return true
+	}
+	if _, active := transactionManager.activeTransactions[writer]; active {
+		return false
+	}
+	commitTimestamp, ok := transactionManager.commitTimestamps[writer]
+	return !ok || commitTimestamp <= transaction.snapshotTimestamp
+}
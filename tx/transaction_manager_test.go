@@ -0,0 +1,317 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/buffer"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"testing"
+)
+
+func TestCheckpointWhileTransactionsAreActive(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.SetString(otherBlockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+
+	uncommittedTransaction := database.newTransaction(t)
+	assert.Nil(t, uncommittedTransaction.Pin(blockId))
+	assert.Nil(t, uncommittedTransaction.SetUint32(blockId, 1, 32))
+
+	assert.Nil(t, database.transactionManager.Checkpoint())
+
+	assert.Nil(t, uncommittedTransaction.SetString(blockId, 0, "RocksDB"))
+	committedTransaction := database.newTransaction(t)
+	assert.Nil(t, committedTransaction.Pin(otherBlockId))
+	assert.Nil(t, committedTransaction.SetString(otherBlockId, 0, "PebbleDB"))
+	assert.Nil(t, committedTransaction.Commit())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+
+	page := readPage(t, fileManager, blockId)
+	assert.Equal(t, "BoltDB", page.GetString(0))
+	assert.True(t, page.IsDeleted(1))
+	assert.Equal(t, "PebbleDB", readPage(t, fileManager, otherBlockId).GetString(0))
+}
+
+func TestCheckpointRecordsTheActiveTransactionsAndTheDirtyPages(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+
+	activeTransaction := database.newTransaction(t)
+	assert.Nil(t, activeTransaction.Pin(blockId))
+	assert.Nil(t, activeTransaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, database.transactionManager.Checkpoint())
+
+	recoveryManager := &recoveryManager{logManager: database.logManager}
+	checkpoint, _, err := recoveryManager.recordsFromTheLatestCheckpoint()
+	assert.Nil(t, err)
+	assert.Equal(t, checkpointTables{
+		beginLogSequenceNumber: 6,
+		lastTransactionNumber:  activeTransaction.TransactionNumber(),
+		activeTransactions:     map[int]uint{activeTransaction.TransactionNumber(): 5},
+		dirtyPages:             map[file.BlockId]uint{blockId: 2},
+	}, checkpoint)
+}
+
+func TestCheckpointWithMoreDirtyPagesThanFitInALogBlock(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 4096)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName(t))
+	assert.Nil(t, err)
+	bufferManager := buffer.NewBufferManager(256, fileManager, logManager)
+	transactionManager := NewTransactionManager(fileManager, logManager, bufferManager)
+
+	transaction, err := transactionManager.NewTransaction()
+	assert.Nil(t, err)
+	const numberOfBlocks = 200
+	blockIds := make([]file.BlockId, 0, numberOfBlocks)
+	for value := uint64(0); value < numberOfBlocks; value++ {
+		blockId, err := transaction.Append(t.Name())
+		assert.Nil(t, err)
+		assert.Nil(t, transaction.SetUint64(blockId, 0, value))
+		blockIds = append(blockIds, blockId)
+	}
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, transactionManager.Checkpoint())
+
+	recoveryManager := &recoveryManager{logManager: logManager}
+	checkpoint, _, err := recoveryManager.recordsFromTheLatestCheckpoint()
+	assert.Nil(t, err)
+	assert.Len(t, checkpoint.dirtyPages, numberOfBlocks)
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	for value, blockId := range blockIds {
+		assert.Equal(t, uint64(value), readPage(t, fileManager, blockId).GetUint64(0))
+	}
+}
+
+func TestRecoverIgnoresACheckpointWhichDidNotEnd(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	assert.Nil(t, database.transactionManager.Checkpoint())
+	beginLogSequenceNumber, err := database.logManager.Append(beginCheckpointRecord{}.encode())
+	assert.Nil(t, err)
+	_, err = database.logManager.Append(checkpointDirtyPagesRecord{
+		beginLogSequenceNumber: beginLogSequenceNumber,
+		dirtyPages:             map[file.BlockId]uint{file.NewBlockId(t.Name(), 0): 1},
+	}.encode())
+	assert.Nil(t, err)
+
+	recoveryManager := &recoveryManager{logManager: database.logManager}
+	checkpoint, _, err := recoveryManager.recordsFromTheLatestCheckpoint()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), checkpoint.beginLogSequenceNumber)
+	assert.Empty(t, checkpoint.dirtyPages)
+}
+
+func TestRecoverStartsTheRedoAtTheEarliestDirtyPageOfTheCheckpoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, database.transactionManager.Checkpoint())
+
+	recoveryManager := &recoveryManager{logManager: database.logManager}
+	checkpoint, records, err := recoveryManager.recordsFromTheLatestCheckpoint()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(5), checkpoint.redoLogSequenceNumber())
+	assert.Equal(t, uint(5), records[0].logSequenceNumber)
+	assert.Equal(t, endCheckpointRecord{beginLogSequenceNumber: 7}, records[len(records)-1].record)
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, "RocksDB", readPage(t, fileManager, blockId).GetString(0))
+}
+
+func TestCheckpointTruncatesTheLog(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 256)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 0))
+	assert.Nil(t, transaction.Commit())
+
+	for value := uint64(1); value <= 64; value++ {
+		transaction = database.newTransaction(t)
+		assert.Nil(t, transaction.Pin(blockId))
+		assert.Nil(t, transaction.SetUint64(blockId, 0, value))
+		assert.Nil(t, transaction.Commit())
+	}
+	numberOfBlocks, err := fileManager.NumberOfBlocks(logFileName(t))
+	assert.Nil(t, err)
+
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+	assert.Nil(t, database.transactionManager.Checkpoint())
+
+	numberOfBlocksAfterCheckpoint, err := fileManager.NumberOfBlocks(logFileName(t))
+	assert.Nil(t, err)
+	assert.Less(t, numberOfBlocksAfterCheckpoint, numberOfBlocks)
+	assert.LessOrEqual(t, numberOfBlocksAfterCheckpoint, int64(2))
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 128))
+	assert.Nil(t, transaction.Commit())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, uint64(128), readPage(t, fileManager, blockId).GetUint64(0))
+}
+
+func TestRecoverCommitsItsTransactionSoThatTheLaterCheckpointsTruncateTheLog(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 256)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 0))
+	assert.Nil(t, transaction.Commit())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	recoveringTransaction := restartedDatabase.newTransaction(t)
+	assert.Nil(t, recoveringTransaction.Recover())
+	assert.NotContains(t, restartedDatabase.transactionManager.activeTransactions, recoveringTransaction.TransactionNumber())
+
+	for value := uint64(1); value <= 64; value++ {
+		transaction = restartedDatabase.newTransaction(t)
+		assert.Nil(t, transaction.Pin(blockId))
+		assert.Nil(t, transaction.SetUint64(blockId, 0, value))
+		assert.Nil(t, transaction.Commit())
+	}
+	assert.Nil(t, restartedDatabase.bufferManager.FlushAllModified())
+	assert.Nil(t, restartedDatabase.transactionManager.Checkpoint())
+
+	recoveryManager := &recoveryManager{logManager: restartedDatabase.logManager}
+	checkpoint, _, err := recoveryManager.recordsFromTheLatestCheckpoint()
+	assert.Nil(t, err)
+	assert.Empty(t, checkpoint.activeTransactions)
+
+	numberOfBlocks, err := fileManager.NumberOfBlocks(logFileName(t))
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, numberOfBlocks, int64(2))
+}
+
+func TestCheckpointKeepsTheLogOfTheActiveTransactions(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 256)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 0))
+	assert.Nil(t, transaction.SetUint64(otherBlockId, 0, 0))
+	assert.Nil(t, transaction.Commit())
+
+	activeTransaction := database.newTransaction(t)
+	assert.Nil(t, activeTransaction.Pin(otherBlockId))
+	assert.Nil(t, activeTransaction.SetUint64(otherBlockId, 0, 1024))
+
+	for value := uint64(1); value <= 64; value++ {
+		transaction = database.newTransaction(t)
+		assert.Nil(t, transaction.Pin(blockId))
+		assert.Nil(t, transaction.SetUint64(blockId, 0, value))
+		assert.Nil(t, transaction.Commit())
+	}
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+	assert.Nil(t, database.transactionManager.Checkpoint())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, uint64(64), readPage(t, fileManager, blockId).GetUint64(0))
+	assert.Equal(t, uint64(0), readPage(t, fileManager, otherBlockId).GetUint64(0))
+}
+
+func TestRestoreTheLastTransactionNumberFromTheLatestCheckpoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	_, err = database.logManager.Append(startRecord{txNumber: 50}.encode())
+	assert.Nil(t, err)
+	_, err = database.logManager.Append(beginCheckpointRecord{lastTransactionNumber: 40}.encode())
+	assert.Nil(t, err)
+	_, err = database.logManager.Append(commitRecord{txNumber: 30}.encode())
+	assert.Nil(t, err)
+
+	assert.Equal(t, 41, database.newTransaction(t).TransactionNumber())
+	assert.Equal(t, 42, database.newTransaction(t).TransactionNumber())
+	assert.Nil(t, database.transactionManager.Checkpoint())
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Equal(t, 43, restartedDatabase.newTransaction(t).TransactionNumber())
+}
+
+func TestTransactionManagersOfDifferentLogsAssignTheirOwnTransactionNumbers(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	otherLogFileName := logFileName(t) + "_other"
+	defer func() {
+		removeFiles(t, fileManager)
+		_ = os.Remove(otherLogFileName)
+	}()
+
+	database := newDatabase(t, fileManager)
+	otherLogManager, err := log.NewBlockLogManager(fileManager, otherLogFileName)
+	assert.Nil(t, err)
+	otherTransactionManager := NewTransactionManager(
+		fileManager,
+		otherLogManager,
+		buffer.NewBufferManager(4, fileManager, otherLogManager),
+	)
+
+	assert.Equal(t, 1, database.newTransaction(t).TransactionNumber())
+	otherTransaction, err := otherTransactionManager.NewTransaction()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, otherTransaction.TransactionNumber())
+	assert.Equal(t, 2, database.newTransaction(t).TransactionNumber())
+}
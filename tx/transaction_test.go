@@ -0,0 +1,581 @@
+package tx
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"gorel"
+	"gorel/buffer"
+	"gorel/file"
+	"gorel/log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const blockSize = 4096
+
+type database struct {
+	fileManager        *file.BlockFileManager
+	logManager         *log.BlockLogManager
+	bufferManager      *buffer.BufferManager
+	transactionManager *TransactionManager
+}
+
+func newDatabase(t *testing.T, fileManager *file.BlockFileManager) database {
+	logManager, err := log.NewBlockLogManager(fileManager, logFileName(t))
+	assert.Nil(t, err)
+
+	bufferManager := buffer.NewBufferManager(4, fileManager, logManager)
+	return database{
+		fileManager:        fileManager,
+		logManager:         logManager,
+		bufferManager:      bufferManager,
+		transactionManager: NewTransactionManager(fileManager, logManager, bufferManager),
+	}
+}
+
+func (database database) newTransaction(t *testing.T) *Transaction {
+	transaction, err := database.transactionManager.NewTransaction()
+	assert.Nil(t, err)
+	return transaction
+}
+
+func logFileName(t *testing.T) string {
+	return fmt.Sprintf("%v_%v", t.Name(), "log")
+}
+
+func removeFiles(t *testing.T, fileManager *file.BlockFileManager) {
+	fileManager.Close()
+	_ = os.Remove(t.Name())
+	_ = os.Remove(t.Name() + ".overflow")
+	_ = os.Remove(logFileName(t))
+}
+
+func TestTransactionsGetUniqueTransactionNumbers(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+
+	var wg sync.WaitGroup
+	transactionNumbers := make([]int, 16)
+	for index := range transactionNumbers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			transaction, err := database.transactionManager.NewTransaction()
+			assert.Nil(t, err)
+			transactionNumbers[index] = transaction.TransactionNumber()
+		}()
+	}
+	wg.Wait()
+
+	unique := make(map[int]struct{})
+	for _, transactionNumber := range transactionNumbers {
+		assert.Greater(t, transactionNumber, 0)
+		unique[transactionNumber] = struct{}{}
+	}
+	assert.Equal(t, len(transactionNumbers), len(unique))
+}
+
+func TestAppendABlockSetFieldsAndGetThem(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	transaction := newDatabase(t, fileManager).newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+
+	assert.Nil(t, transaction.SetUint32(blockId, 0, 32))
+	assert.Nil(t, transaction.SetString(blockId, 1, "RocksDB"))
+	assert.Nil(t, transaction.SetDecimal(blockId, 2, gorel.NewDecimal(12345, 2)))
+	assert.Nil(t, transaction.SetNull(blockId, 3, buffer.TypeInt64))
+	assert.Nil(t, transaction.SetString(blockId, 1, "PebbleDB"))
+
+	value, err := transaction.GetUint32(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(32), value)
+
+	str, err := transaction.GetString(blockId, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, "PebbleDB", str)
+
+	decimal, err := transaction.GetDecimal(blockId, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, gorel.NewDecimal(12345, 2), decimal)
+
+	_, err = transaction.GetInt64(blockId, 3)
+	assert.ErrorIs(t, err, buffer.ErrNullField)
+
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestCommitATransactionAndReadItsModificationsInAnotherTransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetInt64(blockId, 0, -64))
+	assert.Nil(t, transaction.SetBool(blockId, 1, true))
+	assert.Nil(t, transaction.Commit())
+	assert.Nil(t, database.bufferManager.AssertNoPins())
+
+	otherTransaction := database.newTransaction(t)
+	assert.Nil(t, otherTransaction.Pin(blockId))
+
+	value, err := otherTransaction.GetInt64(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-64), value)
+
+	boolValue, err := otherTransaction.GetBool(blockId, 1)
+	assert.Nil(t, err)
+	assert.True(t, boolValue)
+	assert.Nil(t, otherTransaction.Commit())
+}
+
+func TestRollbackATransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint16(blockId, 0, 16))
+	assert.Nil(t, transaction.SetString(blockId, 1, "BoltDB"))
+	assert.Nil(t, transaction.SetNull(blockId, 2, buffer.TypeFloat64))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetUint16(blockId, 0, 32))
+	assert.Nil(t, transaction.SetString(blockId, 1, "RocksDB is an LSM-based storage engine"))
+	assert.Nil(t, transaction.SetFloat64(blockId, 2, 6.4))
+	assert.Nil(t, transaction.SetUint8(blockId, 3, 8))
+	assert.Nil(t, transaction.SetUint16(blockId, 0, 64))
+	assert.Nil(t, transaction.Rollback())
+	assert.Nil(t, database.bufferManager.AssertNoPins())
+	assert.Nil(t, database.bufferManager.FlushAllModified())
+
+	page := buffer.NewPage(blockSize)
+	assert.Nil(t, fileManager.ReadInto(blockId, page))
+	assert.Equal(t, uint16(16), page.GetUint16(0))
+	assert.Equal(t, "BoltDB", page.GetString(1))
+	assert.True(t, page.IsNull(2))
+	assert.True(t, page.IsDeleted(3))
+}
+
+func TestLogTheModificationsOfACommittedTransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 64))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 128))
+	assert.Nil(t, transaction.Commit())
+
+	iterator, err := database.logManager.BackwardIterator()
+	assert.Nil(t, err)
+	defer iterator.Close()
+
+	var records []logRecord
+	for ; iterator.IsValid(); assert.Nil(t, iterator.Previous()) {
+		record, err := decodeLogRecord(iterator.Record())
+		assert.Nil(t, err)
+		records = append(records, record)
+	}
+	assert.Equal(t, []logRecord{
+		commitRecord{txNumber: transaction.TransactionNumber()},
+		updateRecord{
+			txNumber:                  transaction.TransactionNumber(),
+			previousLogSequenceNumber: 2,
+			blockId:                   blockId,
+			index:                     0,
+			beforeImage:               fieldImage{typeDescription: buffer.TypeUint64, encoded: []byte{64, 0, 0, 0, 0, 0, 0, 0}},
+			afterImage:                fieldImage{typeDescription: buffer.TypeUint64, encoded: []byte{128, 0, 0, 0, 0, 0, 0, 0}},
+		},
+		updateRecord{
+			txNumber:                  transaction.TransactionNumber(),
+			previousLogSequenceNumber: 1,
+			blockId:                   blockId,
+			index:                     0,
+			beforeImage:               fieldImage{typeDescription: buffer.TypeTombstone},
+			afterImage:                fieldImage{typeDescription: buffer.TypeUint64, encoded: []byte{64, 0, 0, 0, 0, 0, 0, 0}},
+		},
+		startRecord{txNumber: transaction.TransactionNumber()},
+	}, records)
+}
+
+func TestAttemptToGetAndSetAFieldOfABlockWhichIsNotPinned(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	transaction := newDatabase(t, fileManager).newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint8(blockId, 0, 8))
+	transaction.Unpin(blockId)
+
+	_, err = transaction.GetUint8(blockId, 0)
+	assert.ErrorIs(t, err, BlockNotPinnedError)
+	assert.ErrorIs(t, transaction.SetUint8(blockId, 0, 16), BlockNotPinnedError)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestAttemptToSetAFieldWithATypeMismatch(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	transaction := newDatabase(t, fileManager).newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint8(blockId, 0, 8))
+
+	assert.ErrorIs(t, transaction.SetString(blockId, 0, "RocksDB"), buffer.ErrTypeMismatch)
+	assert.ErrorIs(t, transaction.SetNull(blockId, 0, buffer.TypeString), buffer.ErrTypeMismatch)
+	assert.ErrorIs(t, transaction.SetUint8(blockId, 2, 8), buffer.ErrIndexOutOfBounds)
+	assert.Nil(t, transaction.Rollback())
+}
+
+func TestAttemptToSetAFieldInAFullPage(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", 128)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+
+	for index := 0; index < 8; index++ {
+		assert.Nil(t, transaction.SetUint64(blockId, index, uint64(index)))
+	}
+	latestLogSequenceNumber := database.logManager.LatestLogSequenceNumber()
+	assert.ErrorIs(t, transaction.SetUint64(blockId, 8, 8), InsufficientCapacityError)
+	assert.Equal(t, latestLogSequenceNumber, database.logManager.LatestLogSequenceNumber())
+	assert.Nil(t, transaction.Rollback())
+}
+
+func TestSizeOfAFile(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	transaction := newDatabase(t, fileManager).newTransaction(t)
+	_, err = transaction.Append(t.Name())
+	assert.Nil(t, err)
+	_, err = transaction.Append(t.Name())
+	assert.Nil(t, err)
+
+	size, err := transaction.Size(t.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, uint(2), size)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestPinABlockTwiceAndUnpinItOnce(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.Pin(blockId))
+
+	transaction.Unpin(blockId)
+	assert.Nil(t, transaction.SetUint8(blockId, 0, 8))
+
+	transaction.Unpin(blockId)
+	assert.ErrorIs(t, transaction.SetUint8(blockId, 0, 16), BlockNotPinnedError)
+	assert.Nil(t, database.bufferManager.AssertNoPins())
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestReadABlockModifiedByAnotherTransactionAfterItCommits(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, "BoltDB"))
+
+	reader := database.newTransaction(t)
+	assert.Nil(t, reader.Pin(blockId))
+	result := make(chan string, 1)
+	go func() {
+		value, err := reader.GetString(blockId, 0)
+		assert.Nil(t, err)
+		result <- value
+	}()
+	waitUntilWaiting(t, database.transactionManager.lockTable, reader.TransactionNumber())
+
+	assert.Nil(t, transaction.SetString(blockId, 0, "RocksDB"))
+	assert.Nil(t, transaction.Commit())
+	assert.Equal(t, "RocksDB", <-result)
+	assert.Nil(t, reader.Commit())
+}
+
+func TestRollbackATransactionAbortedByADeadlock(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	otherBlockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint32(blockId, 0, 0))
+	assert.Nil(t, transaction.SetUint32(otherBlockId, 0, 0))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	youngerTransaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.Pin(otherBlockId))
+	assert.Nil(t, youngerTransaction.Pin(blockId))
+	assert.Nil(t, youngerTransaction.Pin(otherBlockId))
+
+	assert.Nil(t, transaction.SetUint32(blockId, 0, 32))
+	assert.Nil(t, youngerTransaction.SetUint32(otherBlockId, 0, 64))
+
+	result := make(chan error, 1)
+	go func() {
+		result <- transaction.SetUint32(otherBlockId, 0, 32)
+	}()
+	waitUntilWaiting(t, database.transactionManager.lockTable, transaction.TransactionNumber())
+
+	_, err = youngerTransaction.GetUint32(blockId, 0)
+	assert.ErrorIs(t, err, ErrLockAbort)
+	assert.Nil(t, youngerTransaction.Rollback())
+
+	assert.Nil(t, <-result)
+	value, err := transaction.GetUint32(otherBlockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(32), value)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestRollbackToASavepoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 100))
+	transaction.Savepoint("first")
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 200))
+	assert.Nil(t, transaction.SetString(blockId, 1, "BoltDB"))
+	transaction.Savepoint("second")
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 300))
+
+	assert.Nil(t, transaction.RollbackTo("second"))
+	value, err := transaction.GetUint64(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(200), value)
+
+	assert.Nil(t, transaction.RollbackTo("first"))
+	value, err = transaction.GetUint64(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100), value)
+	_, err = transaction.GetString(blockId, 1)
+	assert.ErrorIs(t, err, buffer.ErrTypeMismatch)
+	assert.ErrorIs(t, transaction.RollbackTo("second"), SavepointNotFoundError)
+
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 400))
+	assert.Nil(t, transaction.RollbackTo("first"))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 500))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	value, err = transaction.GetUint64(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(500), value)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestRollbackATransactionAfterRollingBackToASavepoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 100))
+	assert.Nil(t, transaction.Commit())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 200))
+	transaction.Savepoint("savepoint")
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 300))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 400))
+	assert.Nil(t, transaction.RollbackTo("savepoint"))
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 500))
+	assert.Nil(t, transaction.Rollback())
+
+	var compensations []uint64
+	assert.Nil(t, transaction.recoveryManager.forEachLogRecordBackward(func(_ uint, record logRecord) (bool, error) {
+		if compensation, ok := record.(compensationRecord); ok {
+			value, _ := gorel.DecodeUint64(compensation.image.encoded, 0)
+			compensations = append(compensations, value)
+		}
+		return true, nil
+	}))
+	assert.Equal(t, []uint64{100, 200, 200, 300}, compensations)
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	value, err := transaction.GetUint64(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(100), value)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestReleaseASavepoint(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	transaction.Savepoint("first")
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 100))
+	transaction.Savepoint("second")
+	assert.Nil(t, transaction.SetUint64(blockId, 0, 200))
+	transaction.Savepoint("third")
+
+	assert.Nil(t, transaction.Release("second"))
+	assert.ErrorIs(t, transaction.RollbackTo("second"), SavepointNotFoundError)
+	assert.ErrorIs(t, transaction.Release("third"), SavepointNotFoundError)
+
+	value, err := transaction.GetUint64(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(200), value)
+
+	assert.Nil(t, transaction.RollbackTo("first"))
+	_, err = transaction.GetUint64(blockId, 0)
+	assert.ErrorIs(t, err, buffer.ErrTypeMismatch)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestSetLargeValuesWhichOverflowAndRollThemBack(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	largeString := strings.Repeat("BoltDB", blockSize)
+	largeBytes := bytes.Repeat([]byte{0xB0}, blockSize/2)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, largeString))
+	assert.Nil(t, transaction.SetBytes(blockId, 1, largeBytes))
+	assert.Nil(t, transaction.Commit())
+
+	var lastUpdate updateRecord
+	assert.Nil(t, transaction.recoveryManager.forEachLogRecordBackward(func(_ uint, record logRecord) (bool, error) {
+		update, ok := record.(updateRecord)
+		lastUpdate = update
+		return !ok, nil
+	}))
+	assert.True(t, lastUpdate.afterImage.typeDescription.IsOverflow())
+	assert.Equal(t, buffer.TypeByteSlice, lastUpdate.afterImage.typeDescription.WithoutOverflow())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetString(blockId, 0, strings.Repeat("RocksDB", blockSize)))
+	assert.Nil(t, transaction.SetBytes(blockId, 1, []byte("RocksDB")))
+	transaction.Savepoint("savepoint")
+	assert.Nil(t, transaction.SetBytes(blockId, 1, bytes.Repeat([]byte{0xDB}, blockSize)))
+	assert.Nil(t, transaction.SetNull(blockId, 0, buffer.TypeString))
+	assert.Nil(t, transaction.RollbackTo("savepoint"))
+
+	value, err := transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("RocksDB", blockSize), value)
+	assert.Nil(t, transaction.Rollback())
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	value, err = transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, largeString, value)
+
+	bytesValue, err := transaction.GetBytes(blockId, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, largeBytes, bytesValue)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestReuseTheOverflowBlocksOfTheLargeValuesWhichAreReplacedOrRolledBack(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	transaction := database.newTransaction(t)
+	blockId, err := transaction.Append(t.Name())
+	assert.Nil(t, err)
+	assert.Nil(t, transaction.SetString(blockId, 0, strings.Repeat("a", blockSize)))
+	assert.Nil(t, transaction.Commit())
+
+	setAndFinish := func(value string, commit bool) {
+		transaction := database.newTransaction(t)
+		assert.Nil(t, transaction.Pin(blockId))
+		assert.Nil(t, transaction.SetString(blockId, 0, value))
+		if commit {
+			assert.Nil(t, transaction.Commit())
+		} else {
+			assert.Nil(t, transaction.Rollback())
+		}
+	}
+	setAndFinish(strings.Repeat("b", blockSize), true)
+	numberOfBlocks, err := fileManager.NumberOfBlocks(t.Name() + ".overflow")
+	assert.Nil(t, err)
+
+	for _, letter := range []string{"c", "d", "e", "f"} {
+		setAndFinish(strings.Repeat(letter, blockSize), letter != "e")
+	}
+	numberOfBlocksAfterwards, err := fileManager.NumberOfBlocks(t.Name() + ".overflow")
+	assert.Nil(t, err)
+	assert.Equal(t, numberOfBlocks, numberOfBlocksAfterwards)
+
+	transaction = database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	value, err := transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("f", blockSize), value)
+	assert.Nil(t, transaction.Commit())
+}
@@ -0,0 +1,171 @@
+package tx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gorel/file"
+	"testing"
+)
+
+// prepareAStringModification sets the string in a new transaction which prepares under the global transaction id.
+func prepareAStringModification(
+	t *testing.T,
+	database database,
+	blockId file.BlockId,
+	value string,
+	globalTransactionId string,
+) *Transaction {
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	assert.Nil(t, transaction.SetString(blockId, 0, value))
+	assert.Nil(t, transaction.Prepare(globalTransactionId))
+	return transaction
+}
+
+func assertString(t *testing.T, database database, blockId file.BlockId, expected string) {
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(blockId))
+	value, err := transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, value)
+	assert.Nil(t, transaction.Commit())
+}
+
+func TestAPreparedTransactionHoldsItsLocksUntilItIsCommitted(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	prepareAStringModification(t, database, blockId, "RocksDB", "global-1")
+	assert.Equal(t, []string{"global-1"}, database.transactionManager.PreparedTransactions())
+
+	reader := database.newTransaction(t)
+	assert.Nil(t, reader.Pin(blockId))
+	result := make(chan string, 1)
+	go func() {
+		value, err := reader.GetString(blockId, 0)
+		assert.Nil(t, err)
+		result <- value
+	}()
+	waitUntilWaiting(t, database.transactionManager.lockTable, reader.TransactionNumber())
+
+	assert.Nil(t, database.transactionManager.CommitPrepared("global-1"))
+	assert.Equal(t, "RocksDB", <-result)
+	assert.Nil(t, reader.Commit())
+	assert.Empty(t, database.transactionManager.PreparedTransactions())
+}
+
+func TestRollbackAPreparedTransaction(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	prepareAStringModification(t, database, blockId, "RocksDB", "global-1")
+
+	assert.Nil(t, database.transactionManager.RollbackPrepared("global-1"))
+	assertString(t, database, blockId, "BoltDB")
+	assert.ErrorIs(t, database.transactionManager.RollbackPrepared("global-1"), PreparedTransactionNotFoundError)
+}
+
+func TestAttemptToFinishAPreparedTransactionOutsideOfTheTwoPhaseCommit(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	transaction := prepareAStringModification(t, database, blockId, "RocksDB", "global-1")
+
+	assert.ErrorIs(t, transaction.Commit(), TransactionPreparedError)
+	assert.ErrorIs(t, transaction.Rollback(), TransactionPreparedError)
+	assert.ErrorIs(t, transaction.Prepare("global-2"), TransactionPreparedError)
+	assert.Nil(t, database.transactionManager.CommitPrepared("global-1"))
+}
+
+func TestAttemptToPrepareUnderAGlobalTransactionIdWhichIsInUse(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	otherBlockId := appendBlockWithAStringAndCommit(t, database, "etcd")
+	prepareAStringModification(t, database, blockId, "RocksDB", "global-1")
+
+	transaction := database.newTransaction(t)
+	assert.Nil(t, transaction.Pin(otherBlockId))
+	assert.Nil(t, transaction.SetString(otherBlockId, 0, "Pebble"))
+	assert.ErrorIs(t, transaction.Prepare("global-1"), TransactionPreparedError)
+	assert.ErrorIs(t, transaction.Prepare(""), TransactionPreparedError)
+	assert.Nil(t, transaction.Prepare("global-2"))
+
+	assert.Equal(t, []string{"global-1", "global-2"}, database.transactionManager.PreparedTransactions())
+	assert.Nil(t, database.transactionManager.CommitPrepared("global-1"))
+	assert.Nil(t, database.transactionManager.CommitPrepared("global-2"))
+	assertString(t, database, otherBlockId, "Pebble")
+}
+
+func TestRecoverAPreparedTransactionInDoubtAndCommitIt(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	otherBlockId := appendBlockWithAStringAndCommit(t, database, "etcd")
+	prepared := prepareAStringModification(t, database, blockId, "RocksDB", "global-1")
+
+	uncommittedTransaction := database.newTransaction(t)
+	assert.Nil(t, uncommittedTransaction.Pin(otherBlockId))
+	assert.Nil(t, uncommittedTransaction.SetString(otherBlockId, 0, "Pebble"))
+	assert.Nil(t, database.bufferManager.FlushAll(uncommittedTransaction.TransactionNumber()))
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, []string{"global-1"}, restartedDatabase.transactionManager.PreparedTransactions())
+	assertString(t, restartedDatabase, otherBlockId, "etcd")
+
+	mode, held := restartedDatabase.transactionManager.lockTable.HeldMode(prepared.TransactionNumber(), blockId)
+	assert.True(t, held)
+	assert.Equal(t, LockExclusive, mode)
+
+	transaction := restartedDatabase.newTransactionWithIsolationLevel(t, IsolationReadUncommitted)
+	assert.Greater(t, transaction.TransactionNumber(), prepared.TransactionNumber())
+	assert.Nil(t, transaction.Pin(blockId))
+	value, err := transaction.GetString(blockId, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "RocksDB", value)
+	assert.Nil(t, transaction.Commit())
+
+	assert.Nil(t, restartedDatabase.transactionManager.CommitPrepared("global-1"))
+	assertString(t, restartedDatabase, blockId, "RocksDB")
+}
+
+func TestRecoverAPreparedTransactionInDoubtTwiceAndRollItBack(t *testing.T) {
+	fileManager, err := file.NewBlockFileManager(".", blockSize)
+	assert.Nil(t, err)
+	defer removeFiles(t, fileManager)
+
+	database := newDatabase(t, fileManager)
+	blockId := appendBlockWithAStringAndCommit(t, database, "BoltDB")
+	prepareAStringModification(t, database, blockId, "RocksDB", "global-1")
+
+	restartedDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedDatabase.newTransaction(t).Recover())
+	assert.Equal(t, []string{"global-1"}, restartedDatabase.transactionManager.PreparedTransactions())
+
+	restartedAgainDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedAgainDatabase.newTransaction(t).Recover())
+	assert.Equal(t, []string{"global-1"}, restartedAgainDatabase.transactionManager.PreparedTransactions())
+
+	assert.Nil(t, restartedAgainDatabase.transactionManager.RollbackPrepared("global-1"))
+	assertString(t, restartedAgainDatabase, blockId, "BoltDB")
+
+	restartedAfterTheRollbackDatabase := newDatabase(t, fileManager)
+	assert.Nil(t, restartedAfterTheRollbackDatabase.newTransaction(t).Recover())
+	assert.Empty(t, restartedAfterTheRollbackDatabase.transactionManager.PreparedTransactions())
+	assertString(t, restartedAfterTheRollbackDatabase, blockId, "BoltDB")
+}